@@ -7,6 +7,7 @@ import (
 	"github.com/donbarrigon/new-project/config"
 	"github.com/donbarrigon/new-project/internal/app"
 	"github.com/donbarrigon/new-project/internal/orm"
+	"github.com/donbarrigon/new-project/internal/pkg/user"
 )
 
 func main() {
@@ -17,6 +18,9 @@ func main() {
 	// Conecta con la base de datos
 	orm.Connect()
 
+	// Registra los modelos para poder cargar sus relaciones anidadas
+	orm.Register(user.NewModel())
+
 	// Configura el logger
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
 
@@ -31,4 +35,9 @@ func main() {
 
 	// Maneja el shutdown graceful
 	app.GracefulShutdown(server)
+
+	// Cierra las conexiones con la base de datos
+	if err := orm.Close(); err != nil {
+		log.Printf("Error al cerrar las conexiones: %v", err)
+	}
 }
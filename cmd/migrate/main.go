@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/donbarrigon/new-project/config"
+	"github.com/donbarrigon/new-project/internal/cache"
+	"github.com/donbarrigon/new-project/internal/database/migration"
+	"github.com/donbarrigon/new-project/internal/database/migration/tables"
+	"github.com/donbarrigon/new-project/internal/orm"
+	"github.com/donbarrigon/new-project/internal/pkg/tenant"
+)
+
+// crea las tablas de la migracion que no existen
+// con tenancy por base de datos o schema las tablas centrales van en la conexion default y las demas en cada tenant
+//
+//	go run ./cmd/migrate              migra la base de datos central
+//	go run ./cmd/migrate -tenants     migra ademas todos los tenants registrados
+//	go run ./cmd/migrate -tenant=acme migra solo el tenant acme
+func main() {
+	allTenants := flag.Bool("tenants", false, "migra todos los tenants registrados")
+	only := flag.String("tenant", "", "migra solo este tenant")
+	flag.Parse()
+
+	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
+
+	// Carga las variables del archivo .env
+	config.Load()
+
+	// Conecta con la base de datos
+	orm.Connect()
+	defer orm.Close()
+
+	// Registra el schema de la migracion
+	tables.NewMigration()
+
+	central, tenantTables := splitTables(cache.GetSchema().Tables)
+	ctx := context.Background()
+
+	if *only == "" {
+		if err := orm.Migrate(ctx, central); err != nil {
+			log.Fatalf("Error al migrar la base de datos central: %v", err)
+		}
+		log.Println("Base de datos central migrada")
+	}
+
+	ids := []string{}
+	switch {
+	case *only != "":
+		ids = append(ids, *only)
+	case *allTenants:
+		var err error
+		if ids, err = tenant.IDs(ctx); err != nil {
+			log.Fatalf("Error al consultar los tenants: %v", err)
+		}
+	}
+
+	// en modo column las tablas de tenant se crean con las centrales
+	if len(tenantTables) == 0 {
+		return
+	}
+	for _, id := range ids {
+		if err := orm.CreateTenantDatabase(ctx, id); err != nil {
+			log.Fatalf("Error al crear la base de datos del tenant %s: %v", id, err)
+		}
+		if err := orm.Migrate(orm.WithTenant(ctx, id), tenantTables); err != nil {
+			log.Fatalf("Error al migrar el tenant %s: %v", id, err)
+		}
+		log.Printf("Tenant %s migrado", id)
+	}
+}
+
+// splitTables separa las tablas de la base de datos central y las de cada tenant
+// sin tenancy o en modo column todas las tablas van en la base de datos central
+func splitTables(all []migration.Table) (central, tenants []migration.Table) {
+	mode := orm.TenancyMode()
+	if mode != orm.TenancyDatabase && mode != orm.TenancySchema {
+		return all, nil
+	}
+	for _, table := range all {
+		if table.Central {
+			central = append(central, table)
+		} else {
+			tenants = append(tenants, table)
+		}
+	}
+	return central, tenants
+}
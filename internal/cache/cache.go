@@ -1,30 +1,98 @@
 package cache
 
+import (
+	"sync"
+	"time"
+)
+
 type CacheData struct {
-	Value any
-	Expires  int32
+	Value   any
+	Expires int64    // fecha unix en segundos en que expira, 0 no expira
+	Tags    []string // etiquetas para eliminar grupos de entradas con Flush
 }
 
-var cache map[string]CacheData
+var (
+	mu    sync.RWMutex
+	cache = make(map[string]CacheData)
+	tags  = make(map[string]map[string]struct{}) // keys de cada etiqueta
+)
 
 func Init() {
-	cache = make(map[string]CacheData, 0)
+	mu.Lock()
+	defer mu.Unlock()
+	cache = make(map[string]CacheData)
+	tags = make(map[string]map[string]struct{})
 }
 
+// Set guarda el valor, expires son los segundos que dura en cache, 0 no expira
 func Set(key string, value any, expires int32) {
-	cache[key] = CacheData{
-		Value: value,
-		Expires: expires,
+	SetTagged(key, value, time.Duration(expires)*time.Second)
+}
+
+// SetTagged guarda el valor por ttl con las etiquetas, ttl 0 no expira
+// las entradas se eliminan juntas con Flush(etiqueta)
+func SetTagged(key string, value any, ttl time.Duration, tagNames ...string) {
+	data := CacheData{Value: value, Tags: tagNames}
+	if ttl > 0 {
+		data.Expires = time.Now().Add(ttl).Unix()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	deleteKey(key)
+	cache[key] = data
+	for _, tag := range tagNames {
+		if tags[tag] == nil {
+			tags[tag] = make(map[string]struct{})
+		}
+		tags[tag][key] = struct{}{}
 	}
 }
 
+// Get retorna el valor si existe y no ha expirado
 func Get(key string) (any, bool) {
+	mu.RLock()
 	data, ok := cache[key]
-	return data.Value, ok
+	mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	if data.Expires > 0 && time.Now().Unix() >= data.Expires {
+		Delete(key)
+		return nil, false
+	}
+	return data.Value, true
 }
 
 func Delete(key string) {
-	delete(cache, key)
+	mu.Lock()
+	defer mu.Unlock()
+	deleteKey(key)
 }
 
+// Flush elimina todas las entradas con alguna de las etiquetas
+func Flush(tagNames ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, tag := range tagNames {
+		for key := range tags[tag] {
+			deleteKey(key)
+		}
+		delete(tags, tag)
+	}
+}
 
+// deleteKey elimina la entrada y sus referencias en las etiquetas, se debe llamar con el lock
+func deleteKey(key string) {
+	data, ok := cache[key]
+	if !ok {
+		return
+	}
+	delete(cache, key)
+	for _, tag := range data.Tags {
+		delete(tags[tag], key)
+		if len(tags[tag]) == 0 {
+			delete(tags, tag)
+		}
+	}
+}
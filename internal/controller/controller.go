@@ -1,6 +1,9 @@
 package controller
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -117,6 +120,77 @@ func (c *Context) queryParam(key string) string {
 	return ""
 }
 
+// limites de la paginacion
+const (
+	DefaultPerPage = 15  // registros por pagina si no se envia per_page
+	MaxPerPage     = 100 // maximo de registros por pagina que puede pedir el cliente
+)
+
+// PageParams retorna page y per_page de la url para usar en Paginate
+// si no vienen o no son validos se usan page 1 y DefaultPerPage, per_page no puede ser mayor a MaxPerPage
+func (c *Context) PageParams() (page int, perPage int) {
+	page, err := strconv.Atoi(c.queryParam("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	return page, c.perPage()
+}
+
+// CursorParams retorna per_page y cursor de la url para usar en CursorPaginate
+func (c *Context) CursorParams() (perPage int, cursor string) {
+	return c.perPage(), c.queryParam("cursor")
+}
+
+// perPage retorna per_page de la url dentro de los limites
+func (c *Context) perPage() int {
+	perPage, err := strconv.Atoi(c.queryParam("per_page"))
+	if err != nil || perPage < 1 {
+		return DefaultPerPage
+	}
+	return min(perPage, MaxPerPage)
+}
+
+// Context retorna el contexto del request, se cancela cuando el cliente cierra la conexion
+// se pasa a los modelos para que sus consultas se cancelen con el request: users.WithContext(ctx.Context())
+func (c *Context) Context() context.Context {
+	if c.Request == nil {
+		return context.Background()
+	}
+	return c.Request.Context()
+}
+
+// StatusCode retorna el codigo http que corresponde al error
+// orm.ErrStaleModel es 409 porque el registro cambio desde que el cliente lo leyo
+// orm.TimeoutError es 504 porque la consulta supero su tiempo limite
+// orm.ErrNoTenant es 404 porque el request no corresponde a ningun tenant
+// orm.ErrInvalidCursor es 400 porque el cursor lo envia el cliente
+func StatusCode(err error) int {
+	var timeout *orm.TimeoutError
+	switch {
+	case errors.Is(err, orm.ErrStaleModel):
+		return http.StatusConflict
+	case errors.Is(err, orm.ErrNoTenant):
+		return http.StatusNotFound
+	case errors.Is(err, orm.ErrInvalidCursor):
+		return http.StatusBadRequest
+	case errors.As(err, &timeout):
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+// Error responde el error en json con el codigo http que le corresponde segun StatusCode
+func (c *Context) Error(err error) {
+	status := StatusCode(err)
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.WriteHeader(status)
+	json.NewEncoder(c.Writer).Encode(ErrorResponse{
+		Status:     http.StatusText(status),
+		Message:    err.Error(),
+		StatusCode: status,
+	})
+}
+
 // Validate valida los datos del request y los guarda en c.Body
 // forma de uso if err := ctx.Validate(&FormRequest{}) err != nil { return err }
 // func (c *Context) Validate(req *request.FormRequest) ValidationError {
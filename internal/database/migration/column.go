@@ -342,6 +342,35 @@ func DeletedAt(options ...string) *Column {
 	return column
 }
 
+// Version retorna una columna UNSIGNED INT que se utiliza para el bloqueo optimista del orm, empieza en 1
+func Version(options ...string) *Column {
+	defaultValue := "1"
+	column := &Column{
+		Name:        "version",
+		Type:        "uint32",
+		Required:    true,
+		Default:     &defaultValue,
+		Constraints: make(map[string]string),
+	}
+	processOptions(column, options...)
+	return column
+}
+
+// TenantID retorna la columna VARCHAR(64) con el tenant del registro que usa el orm en tenancy por columna
+func TenantID(options ...string) *Column {
+	length := 64
+	column := &Column{
+		Name:        "tenant_id",
+		Type:        "varchar",
+		Precision:   &length,
+		Required:    true,
+		Index:       true,
+		Constraints: make(map[string]string),
+	}
+	processOptions(column, options...)
+	return column
+}
+
 // crea una variable de tipo ENUM en mysql en postgreSQL la simula con un VARCHAR con CHECK
 func Enum(name string, values []string, options ...string) *Column {
 	column := &Column{
@@ -512,15 +541,9 @@ func wrapValues(values []string) []string {
 // geometry
 // ipAddress
 // macAddress
-// morphs
-// nullableMorphs
 // nullableTimestamps
-// nullableUlidMorphs
-// nullableUuidMorphs
 // rememberToken
 // set
-// ulidMorphs
-// uuidMorphs
 // ulid
 // uuid
 // vector
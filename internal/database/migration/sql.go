@@ -0,0 +1,181 @@
+package migration
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// columnTypeAliases son los tipos de las columnas que se guardan con un nombre distinto al de ColumnTypesMap
+var columnTypeAliases = map[string]string{
+	"varchar": "string",
+	"boolean": "bool",
+}
+
+// CreateSQL retorna las sentencias para crear la tabla si no existe en mysql o postgresql
+// en mysql los indices van dentro del CREATE TABLE, en postgresql se crean despues con CREATE INDEX IF NOT EXISTS
+func (t *Table) CreateSQL(driver string) ([]string, error) {
+	if driver != "mysql" && driver != "postgresql" {
+		return nil, fmt.Errorf("las migraciones sql no son compatibles con el driver '%s'", driver)
+	}
+
+	definitions := make([]string, 0, len(t.Columns)+len(t.PrimaryKeys)+len(t.Indexes))
+	for _, col := range t.Columns {
+		definition, err := col.definition(driver)
+		if err != nil {
+			return nil, fmt.Errorf("tabla '%s': %w", t.Name, err)
+		}
+		definitions = append(definitions, definition)
+	}
+	if len(t.PrimaryKeys) > 0 {
+		definitions = append(definitions, "PRIMARY KEY ("+strings.Join(t.PrimaryKeys, ", ")+")")
+	}
+	for _, col := range t.Columns {
+		if fk := col.ForeignKey; fk.Table != "" {
+			constraint := fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s (%s)", fk.Column, fk.Table, fk.Reference)
+			if fk.OnDelete != "" {
+				constraint += " ON DELETE " + strings.ToUpper(fk.OnDelete)
+			}
+			if fk.OnUpdate != "" {
+				constraint += " ON UPDATE " + strings.ToUpper(fk.OnUpdate)
+			}
+			definitions = append(definitions, constraint)
+		}
+	}
+
+	// indices simples de las columnas e indices compuestos de la tabla
+	indexes := make([]Index, 0, len(t.Indexes))
+	for _, col := range t.Columns {
+		if col.Index {
+			indexes = append(indexes, Index{Columns: []string{col.Name}, Name: t.Name + "_" + col.Name + "_index"})
+		}
+	}
+	indexes = append(indexes, t.Indexes...)
+
+	statements := make([]string, 0, 1+len(indexes))
+	if driver == "mysql" {
+		for _, index := range indexes {
+			kind := "INDEX"
+			if index.Unique {
+				kind = "UNIQUE INDEX"
+			}
+			definitions = append(definitions, fmt.Sprintf("%s %s (%s)", kind, index.Name, strings.Join(index.Columns, ", ")))
+		}
+	}
+
+	create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n\t%s\n)", t.Name, strings.Join(definitions, ",\n\t"))
+	if driver == "mysql" {
+		if t.Engine != "" {
+			create += " ENGINE=" + t.Engine
+		}
+		if t.Charset != "" {
+			create += " DEFAULT CHARSET=" + t.Charset
+		}
+		if t.Collation != "" {
+			create += " COLLATE=" + t.Collation
+		}
+		if t.Comment != "" {
+			create += " COMMENT=" + quote(t.Comment)
+		}
+	}
+	statements = append(statements, create)
+
+	if driver == "postgresql" {
+		for _, index := range indexes {
+			kind := "INDEX"
+			if index.Unique {
+				kind = "UNIQUE INDEX"
+			}
+			statements = append(statements, fmt.Sprintf("CREATE %s IF NOT EXISTS %s ON %s (%s)", kind, index.Name, t.Name, strings.Join(index.Columns, ", ")))
+		}
+	}
+	return statements, nil
+}
+
+// definition retorna la definicion de la columna para el CREATE TABLE
+func (c *Column) definition(driver string) (string, error) {
+	typ, err := c.sqlType(driver)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(c.Name + " " + typ)
+	if c.Required || c.PrimaryKey {
+		sb.WriteString(" NOT NULL")
+	}
+	if c.AutoIncrement {
+		if driver == "mysql" {
+			sb.WriteString(" AUTO_INCREMENT")
+		} else {
+			sb.WriteString(" GENERATED BY DEFAULT AS IDENTITY")
+		}
+	}
+	if c.Default != nil {
+		sb.WriteString(" DEFAULT " + defaultValue(*c.Default))
+	}
+	if c.OnUpdate != nil && driver == "mysql" {
+		sb.WriteString(" ON UPDATE " + *c.OnUpdate)
+	}
+	if c.Unique {
+		sb.WriteString(" UNIQUE")
+	}
+	if c.Check != nil {
+		sb.WriteString(" CHECK (" + *c.Check + ")")
+	}
+	if enum, ok := c.Constraints["enum"]; ok && driver == "postgresql" {
+		sb.WriteString(" CHECK (" + c.Name + " IN (" + enum + "))")
+	}
+	if c.Comment != nil && driver == "mysql" {
+		sb.WriteString(" COMMENT " + quote(*c.Comment))
+	}
+	return sb.String(), nil
+}
+
+// sqlType retorna el tipo de la columna en el driver con su longitud o precision
+func (c *Column) sqlType(driver string) (string, error) {
+	t := c.Type
+	if alias, ok := columnTypeAliases[t]; ok {
+		t = alias
+	}
+	typ, ok := ColumnTypesMap[driver][t]
+	if !ok {
+		return "", fmt.Errorf("el tipo '%s' de la columna '%s' no es compatible con %s", c.Type, c.Name, driver)
+	}
+
+	switch {
+	case t == "enum" && driver == "mysql":
+		return "ENUM(" + c.Constraints["enum"] + ")", nil
+	case t == "enum":
+		return typ + "(255)", nil
+	case t == "decimal" && c.Precision != nil && c.Scale != nil:
+		return fmt.Sprintf("%s(%d, %d)", typ, *c.Precision, *c.Scale), nil
+	case typ == "BYTEA":
+		// postgresql no tiene longitud en los binarios
+		return typ, nil
+	case c.Precision != nil:
+		return fmt.Sprintf("%s(%d)", typ, *c.Precision), nil
+	}
+	return typ, nil
+}
+
+// defaultValue retorna el valor por defecto para el sql
+// los numeros, booleanos, NULL y las expresiones como CURRENT_TIMESTAMP van sin comillas
+func defaultValue(value string) string {
+	upper := strings.ToUpper(value)
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return value
+	}
+	switch {
+	case upper == "TRUE", upper == "FALSE", upper == "NULL", upper == "CURRENT_TIMESTAMP":
+		return upper
+	case strings.Contains(value, "("), strings.HasPrefix(value, "'"):
+		return value
+	}
+	return quote(value)
+}
+
+// quote retorna el texto entre comillas simples para el sql
+func quote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -197,10 +197,12 @@ type Table struct {
 	Charset            string            // Conjunto de caracteres (utf8mb4, etc.)
 	Collation          string            // Collation de la tabla
 	PrimaryKeys        []string          // ColumnNames como claves primarias
+	Indexes            []Index           // Indices compuestos de la tabla
 	Constraints        map[string]string // Restricciones
 	AutoIncrementStart int               // Valor inicial del auto_increment
 	Temporary          bool              // Indica si es una tabla temporal
 	Comment            string            // Comentario de la tabla
+	Central            bool              // en tenancy por base de datos o schema la tabla solo se crea en la base de datos central
 }
 
 type Schema struct {
@@ -127,3 +127,73 @@ func NewTable(name string, columns ...*Column) *Table {
 
 	return table
 }
+
+// AddColumns agrega columnas a la tabla
+func (t *Table) AddColumns(columns ...*Column) {
+	for _, col := range columns {
+		if col != nil {
+			t.Columns = append(t.Columns, *col)
+			if col.PrimaryKey {
+				t.PrimaryKeys = append(t.PrimaryKeys, col.Name)
+			}
+		}
+	}
+}
+
+// AddIndex agrega un indice compuesto a la tabla
+// si no se especifica el nombre se usa tabla_columnas_index
+func (t *Table) AddIndex(columns []string, unique bool, name ...string) {
+	index := Index{
+		Columns: columns,
+		Unique:  unique,
+		Name:    t.Name + "_" + strings.Join(columns, "_") + "_index",
+	}
+	if len(name) > 0 && name[0] != "" {
+		index.Name = name[0]
+	}
+	t.Indexes = append(t.Indexes, index)
+}
+
+// Morphs agrega las columnas <name>_type y <name>_id para relaciones polimorficas con un indice compuesto
+// table.Morphs("commentable") crea commentable_type VARCHAR(255) y commentable_id BIGINT UNSIGNED
+func (t *Table) Morphs(name string, options ...string) {
+	t.addMorphs(name, UInt64(name+"_id", append([]string{"required"}, options...)...), true, options...)
+}
+
+// NullableMorphs igual que Morphs pero las columnas permiten valores nulos
+func (t *Table) NullableMorphs(name string, options ...string) {
+	t.addMorphs(name, UInt64(name+"_id", append([]string{"nullable"}, options...)...), false, options...)
+}
+
+// UuidMorphs igual que Morphs pero <name>_id es de tipo CHAR(36) para modelos con uuid
+func (t *Table) UuidMorphs(name string, options ...string) {
+	t.addMorphs(name, Char(name+"_id", append([]string{"36", "required"}, options...)...), true, options...)
+}
+
+// NullableUuidMorphs igual que UuidMorphs pero las columnas permiten valores nulos
+func (t *Table) NullableUuidMorphs(name string, options ...string) {
+	t.addMorphs(name, Char(name+"_id", append([]string{"36", "nullable"}, options...)...), false, options...)
+}
+
+// UlidMorphs igual que Morphs pero <name>_id es de tipo CHAR(26) para modelos con ulid
+func (t *Table) UlidMorphs(name string, options ...string) {
+	t.addMorphs(name, Char(name+"_id", append([]string{"26", "required"}, options...)...), true, options...)
+}
+
+// NullableUlidMorphs igual que UlidMorphs pero las columnas permiten valores nulos
+func (t *Table) NullableUlidMorphs(name string, options ...string) {
+	t.addMorphs(name, Char(name+"_id", append([]string{"26", "nullable"}, options...)...), false, options...)
+}
+
+// addMorphs agrega la columna <name>_type, la columna id recibida y el indice compuesto
+// no usar para desarrollar esta es una funcion axiliar
+func (t *Table) addMorphs(name string, idColumn *Column, required bool, options ...string) {
+	name = formatter.ToSnakeCase(name)
+	nullability := "nullable"
+	if required {
+		nullability = "required"
+	}
+	typeColumn := String(name+"_type", append([]string{nullability}, options...)...)
+	t.AddColumns(typeColumn, idColumn)
+	t.AddIndex([]string{typeColumn.Name, idColumn.Name}, false)
+}
@@ -15,6 +15,8 @@ func create_user_table() *Table {
 		CreatedAt(),
 		UpdatedAt(),
 		DeletedAt(),
+		// nullable para que la tabla tambien sirva sin tenancy por columna
+		TenantID("nullable"),
 	)
 	return table
 }
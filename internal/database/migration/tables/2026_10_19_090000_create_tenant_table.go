@@ -0,0 +1,19 @@
+package tables
+
+import (
+	. "github.com/donbarrigon/new-project/internal/database/migration"
+)
+
+func create_tenant_table() *Table {
+
+	table := NewTable(
+		"tenant",
+		String("id", "64", "primary_key", "required"),
+		String("name"),
+		CreatedAt(),
+		UpdatedAt(),
+	)
+	// los tenants se guardan en la base de datos central
+	table.Central = true
+	return table
+}
@@ -0,0 +1,28 @@
+package tables
+
+import (
+	. "github.com/donbarrigon/new-project/internal/database/migration"
+)
+
+func create_audits_table() *Table {
+
+	table := NewTable(
+		"audit",
+		BigIncrements(),
+		String("auditable_type", "required"),
+		String("auditable_id", "required"),
+		String("event", "32", "required"),
+		Json("old_values", "nullable"),
+		Json("new_values", "nullable"),
+		String("user_id", "nullable", "index"),
+		String("ip_address", "45", "nullable"),
+		Text("user_agent", "nullable"),
+		String("request_id", "64", "nullable", "index"),
+		CreatedAt(),
+		// nullable para que la tabla tambien sirva sin tenancy por columna
+		TenantID("nullable"),
+	)
+	// el historial de un registro se consulta por la tabla y el id
+	table.AddIndex([]string{"auditable_type", "auditable_id"}, false)
+	return table
+}
@@ -13,7 +13,9 @@ func NewMigration() {
 	schema := NewSchema(os.Getenv("DB_NAME"))
 
 	schema.AddTables(
+		create_tenant_table(),
 		create_user_table(),
+		create_audits_table(),
 		// aqui agrege las demas funciones de creacion de tablas
 	)
 
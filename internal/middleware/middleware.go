@@ -1,9 +1,17 @@
 package middleware
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
 
 	"github.com/donbarrigon/new-project/internal/controller"
+	"github.com/donbarrigon/new-project/internal/orm"
+	"github.com/donbarrigon/new-project/internal/pkg/tenant"
 )
 
 type MiddlewareFunc func(controller.ControllerFunc) controller.ControllerFunc
@@ -22,3 +30,139 @@ func Request(next controller.ControllerFunc) controller.ControllerFunc {
 		next(ctx)
 	}
 }
+
+// Sticky hace que las lecturas de la base de datos vayan al primario despues de una escritura en el mismo request
+// solo aplica en las conexiones con replicas y DB_STICKY=true, los controladores deben usar ctx.Context()
+func Sticky(next controller.ControllerFunc) controller.ControllerFunc {
+	return func(ctx *controller.Context) {
+		ctx.Request = ctx.Request.WithContext(orm.Sticky(ctx.Request.Context()))
+		next(ctx)
+	}
+}
+
+// TrackQueries cuenta las consultas del request para detectar N+1, ver orm.DetectNPlusOne
+func TrackQueries(next controller.ControllerFunc) controller.ControllerFunc {
+	return func(ctx *controller.Context) {
+		ctx.Request = ctx.Request.WithContext(orm.TrackQueries(ctx.Request.Context()))
+		next(ctx)
+	}
+}
+
+// Audit agrega al contexto los datos del request que se guardan en la auditoria de los modelos con Auditable
+// el usuario se toma de ctx.User cuando se guarda el cambio, el request id del header X-Request-ID o se genera uno
+func Audit(next controller.ControllerFunc) controller.ControllerFunc {
+	return func(ctx *controller.Context) {
+		requestID := ctx.Request.Header.Get("X-Request-ID")
+		if requestID == "" {
+			b := make([]byte, 16)
+			rand.Read(b)
+			requestID = hex.EncodeToString(b)
+		}
+		ctx.Writer.Header().Set("X-Request-ID", requestID)
+
+		ip, _, err := net.SplitHostPort(ctx.Request.RemoteAddr)
+		if err != nil {
+			ip = ctx.Request.RemoteAddr
+		}
+		actor := &orm.AuditActor{
+			UserID: func() any {
+				if ctx.User == nil || len(ctx.User.Data) == 0 {
+					return nil
+				}
+				return ctx.User.Data[0][ctx.User.PrimaryKey()]
+			},
+			IP:        ip,
+			UserAgent: ctx.Request.UserAgent(),
+			RequestID: requestID,
+		}
+		ctx.Request = ctx.Request.WithContext(orm.WithAuditActor(ctx.Request.Context(), actor))
+		next(ctx)
+	}
+}
+
+// TenantFromToken resuelve el tenant del usuario autenticado con el token del request, la asigna el paquete de autenticacion
+// se usa con el resolver token de TENANCY_RESOLVERS y para verificar el resolver header
+var TenantFromToken func(r *http.Request) (string, error)
+
+// Tenant resuelve el tenant del request y lo agrega al contexto para que las consultas se limiten a el
+// prueba los resolvers de TENANCY_RESOLVERS en orden (subdomain, header, token), por defecto solo subdomain
+// si no se usa tenancy no hace nada, si ningun resolver identifica el tenant o el tenant no existe responde 404
+func Tenant(next controller.ControllerFunc) controller.ControllerFunc {
+	if orm.TenancyMode() == "" {
+		return next
+	}
+	resolvers := strings.Split(os.Getenv("TENANCY_RESOLVERS"), ",")
+	if resolvers[0] == "" {
+		resolvers = []string{"subdomain"}
+	}
+	return func(ctx *controller.Context) {
+		id, err := resolveTenant(ctx.Request, resolvers)
+		if err == nil && id == "" {
+			err = fmt.Errorf("no se pudo identificar el tenant del request: %w", orm.ErrNoTenant)
+		}
+		if err == nil {
+			var exists bool
+			if exists, err = tenant.Exists(ctx.Context(), id); err == nil && !exists {
+				err = fmt.Errorf("el tenant '%s' no existe: %w", id, orm.ErrNoTenant)
+			}
+		}
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		ctx.Request = ctx.Request.WithContext(orm.WithTenant(ctx.Request.Context(), id))
+		next(ctx)
+	}
+}
+
+// resolveTenant retorna el tenant del primer resolver que lo encuentre
+// el header lo envia el cliente, por eso solo se acepta si es el mismo tenant del usuario autenticado
+func resolveTenant(r *http.Request, resolvers []string) (string, error) {
+	for _, resolver := range resolvers {
+		var id string
+		switch strings.TrimSpace(resolver) {
+		case "subdomain":
+			// acme.example.com es el tenant acme si TENANCY_DOMAIN=example.com
+			domain := os.Getenv("TENANCY_DOMAIN")
+			if domain == "" {
+				return "", fmt.Errorf("el resolver de tenant subdomain requiere TENANCY_DOMAIN")
+			}
+			host, _, _ := strings.Cut(r.Host, ":")
+			id, _ = strings.CutSuffix(host, "."+domain)
+			if id == host || strings.Contains(id, ".") {
+				id = ""
+			}
+		case "header":
+			header := os.Getenv("TENANCY_HEADER")
+			if header == "" {
+				header = "X-Tenant"
+			}
+			if id = r.Header.Get(header); id == "" {
+				continue
+			}
+			if TenantFromToken == nil {
+				return "", fmt.Errorf("el resolver de tenant header requiere TenantFromToken para verificar el usuario")
+			}
+			owner, err := TenantFromToken(r)
+			if err != nil {
+				return "", err
+			}
+			if owner != id {
+				return "", fmt.Errorf("el tenant del header no es el del usuario autenticado: %w", orm.ErrNoTenant)
+			}
+		case "token":
+			if TenantFromToken != nil {
+				var err error
+				if id, err = TenantFromToken(r); err != nil {
+					return "", err
+				}
+			}
+		default:
+			return "", fmt.Errorf("el resolver de tenant '%s' no existe", resolver)
+		}
+		if id != "" {
+			return id, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,483 @@
+package orm
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// aggregate representa una funcion de agregacion del select: SUM(amount) AS total
+type aggregate struct {
+	function string // count | sum | avg | min | max
+	column   string // columna, * en count
+	alias    string // nombre de la columna en los resultados
+}
+
+// rawExpr es una expresion sql escrita a mano con sus parametros
+type rawExpr struct {
+	sql   string
+	args  []any
+	alias string // alias de la expresion si termina en AS alias
+}
+
+// aliasRegex toma el alias al final de una expresion: SUM(amount) AS total
+var aliasRegex = regexp.MustCompile(`(?i)\s+as\s+([A-Za-z_][A-Za-z0-9_]*)\s*$`)
+
+// Count retorna la cantidad de registros que coinciden con la consulta
+// no tiene en cuenta el limit, offset ni el orden, si la consulta tiene GroupBy retorna la cantidad de grupos
+func (m *Model) Count() (int64, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	if err := m.applyScopes(); err != nil {
+		return 0, err
+	}
+
+	// Usar un map para los drivers soportados
+	countFuncs := map[string]func() (int64, error){
+		"mongodb":    m.countMongoDB,
+		"mysql":      m.countMySQL,
+		"postgresql": m.countMySQL,
+		"memory":     m.countMemory,
+	}
+
+	if countFunc, ok := countFuncs[m.driver()]; ok {
+		return countFunc()
+	}
+
+	return 0, m.conn().unsupported()
+}
+
+// countMySQL cuenta los registros en mysql o postgresql
+func (m *Model) countMySQL() (int64, error) {
+	fromSQL, args := m.compileFrom()
+	whereSQL, whereArgs := m.compileWheres()
+	query := "SELECT COUNT(*) FROM " + fromSQL + whereSQL
+	args = append(args, whereArgs...)
+	if len(m.groups) > 0 {
+		// se cuentan los grupos con una subconsulta
+		q := m.clone()
+		q.orders, q.limitValue, q.offsetValue = nil, 0, 0
+		var sub string
+		sub, args = q.compileSelectSQL()
+		query = "SELECT COUNT(*) FROM (" + sub + ") AS aggregate_table"
+	}
+
+	query = rebind(m.driver(), query)
+	return remember(m, query, args, func() (int64, error) {
+		ctx, cancel := m.context()
+		defer cancel()
+
+		start := time.Now()
+		rows, err := m.sqlReader().QueryContext(ctx, query, args...)
+		if err != nil {
+			m.record(ctx, start, query, args, 0, err)
+			return 0, dbError("error al contar los registros", err)
+		}
+		defer rows.Close()
+
+		var count int64
+		if rows.Next() {
+			if err := rows.Scan(&count); err != nil {
+				m.record(ctx, start, query, args, 0, err)
+				return 0, dbError("error al leer el conteo", err)
+			}
+		}
+		m.record(ctx, start, query, args, 1, rows.Err())
+		return count, rows.Err()
+	})
+}
+
+// countMongoDB cuenta los documentos en mongodb
+func (m *Model) countMongoDB() (int64, error) {
+	if !m.usesPipeline() {
+		filter := m.mongoFilter()
+		return remember(m, m.mongoQuery("countDocuments"), []any{filter}, func() (int64, error) {
+			ctx, cancel := m.context()
+			defer cancel()
+
+			start := time.Now()
+			count, err := m.collection().CountDocuments(ctx, filter)
+			m.record(ctx, start, m.mongoQuery("countDocuments"), []any{filter}, 1, err)
+			if err != nil {
+				return 0, dbError("error al contar los documentos", err)
+			}
+			return count, nil
+		})
+	}
+
+	// se cuentan los grupos o los documentos del join agregando $count al pipeline
+	q := m.clone()
+	q.orders, q.limitValue, q.offsetValue = nil, 0, 0
+	pipeline, err := q.mongoPipeline()
+	if err != nil {
+		return 0, err
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$count", Value: "aggregate"}})
+
+	return remember(m, m.mongoQuery("aggregate"), []any{pipeline}, func() (int64, error) {
+		ctx, cancel := m.context()
+		defer cancel()
+
+		start := time.Now()
+		cursor, err := m.collection().Aggregate(ctx, pipeline)
+		if err != nil {
+			m.record(ctx, start, m.mongoQuery("aggregate"), []any{pipeline}, 0, err)
+			return 0, dbError("error al contar los documentos", err)
+		}
+		defer cursor.Close(ctx)
+
+		data := make([]map[string]any, 0, 1)
+		err = cursor.All(ctx, &data)
+		m.record(ctx, start, m.mongoQuery("aggregate"), []any{pipeline}, int64(len(data)), err)
+		if err != nil {
+			return 0, dbError("error al leer el conteo", err)
+		}
+		if len(data) == 0 {
+			return 0, nil
+		}
+		return toInt64(data[0]["aggregate"])
+	})
+}
+
+// Sum retorna la suma de la columna en los registros de la consulta, 0 si no hay registros
+func (m *Model) Sum(column string) (float64, error) {
+	value, err := m.aggregateValue("sum", column)
+	if err != nil || value == nil {
+		return 0, err
+	}
+	return toFloat64(value)
+}
+
+// Avg retorna el promedio de la columna en los registros de la consulta, 0 si no hay registros
+func (m *Model) Avg(column string) (float64, error) {
+	value, err := m.aggregateValue("avg", column)
+	if err != nil || value == nil {
+		return 0, err
+	}
+	return toFloat64(value)
+}
+
+// Min retorna el valor minimo de la columna, nil si no hay registros
+// el tipo depende de la columna: numeros, fechas o strings
+func (m *Model) Min(column string) (any, error) {
+	return m.aggregateValue("min", column)
+}
+
+// Max retorna el valor maximo de la columna, nil si no hay registros
+func (m *Model) Max(column string) (any, error) {
+	return m.aggregateValue("max", column)
+}
+
+// aggregateValue ejecuta una funcion de agregacion sobre toda la consulta y retorna el valor
+// se ignoran el orden, limit, offset, GroupBy y las columnas seleccionadas
+func (m *Model) aggregateValue(function string, column string) (any, error) {
+	if !m.checkColumn(column) {
+		return nil, m.err
+	}
+
+	q := m.clone()
+	q.orders, q.limitValue, q.offsetValue = nil, 0, 0
+	q.groups, q.havings, q.selectedColumns, q.rawSelects, q.with = nil, nil, nil, nil, nil
+	q.aggregates = []aggregate{{function: function, column: column, alias: "aggregate"}}
+	if err := q.Get(); err != nil {
+		return nil, err
+	}
+	if len(q.Data) == 0 {
+		return nil, nil
+	}
+
+	value := q.Data[0]["aggregate"]
+	if b, ok := value.([]byte); ok {
+		return string(b), nil
+	}
+	return value, nil
+}
+
+// GroupBy agrupa los resultados por las columnas
+// se usa junto con SelectCount, SelectSum, etc. para obtener un registro por grupo
+//
+//	users.GroupBy("role").SelectCount("total").Having("total", ">", 10).Get()
+func (m *Model) GroupBy(columns ...string) *Model {
+	for _, column := range columns {
+		if !m.checkColumn(column) {
+			return m
+		}
+		m.groups = append(m.groups, m.fieldName(column))
+	}
+	return m
+}
+
+// Having agrega una condicion sobre los grupos, column puede ser una columna del GroupBy o el alias de una agregacion
+func (m *Model) Having(column string, args ...any) *Model {
+	return m.addHaving("and", column, args...)
+}
+
+// OrHaving agrega una condicion sobre los grupos unida con OR
+func (m *Model) OrHaving(column string, args ...any) *Model {
+	return m.addHaving("or", column, args...)
+}
+
+func (m *Model) addHaving(boolean string, column string, args ...any) *Model {
+	// se reutiliza la validacion del where y se mueve la condicion al having
+	count := len(m.wheres)
+	m.addWhere(boolean, column, args...)
+	if len(m.wheres) > count {
+		m.havings = append(m.havings, m.wheres[count])
+		m.wheres = m.wheres[:count]
+	}
+	return m
+}
+
+// SelectCount agrega COUNT(column) AS alias al select, column es opcional por defecto *
+func (m *Model) SelectCount(alias string, column ...string) *Model {
+	col := "*"
+	if len(column) > 0 {
+		col = column[0]
+	}
+	return m.addAggregate("count", col, alias)
+}
+
+// SelectSum agrega SUM(column) AS alias al select
+func (m *Model) SelectSum(column string, alias string) *Model {
+	return m.addAggregate("sum", column, alias)
+}
+
+// SelectAvg agrega AVG(column) AS alias al select
+func (m *Model) SelectAvg(column string, alias string) *Model {
+	return m.addAggregate("avg", column, alias)
+}
+
+// SelectMin agrega MIN(column) AS alias al select
+func (m *Model) SelectMin(column string, alias string) *Model {
+	return m.addAggregate("min", column, alias)
+}
+
+// SelectMax agrega MAX(column) AS alias al select
+func (m *Model) SelectMax(column string, alias string) *Model {
+	return m.addAggregate("max", column, alias)
+}
+
+func (m *Model) addAggregate(function string, column string, alias string) *Model {
+	if m.err != nil {
+		return m
+	}
+	if column != "*" && !m.checkColumn(column) {
+		return m
+	}
+	if !isIdentifier(alias) {
+		m.err = fmt.Errorf("el alias '%s' no es válido", alias)
+		return m
+	}
+	m.aggregates = append(m.aggregates, aggregate{function: function, column: column, alias: alias})
+	return m
+}
+
+// SelectRaw agrega una expresion sql al select, los parametros se pasan con ?
+// la expresion no se valida, nunca se debe construir con datos del usuario
+// si termina en AS alias el alias se puede usar en OrderBy y Having
+// solo funciona en sql, en mongodb se usan SelectCount, SelectSum, etc.
+func (m *Model) SelectRaw(expression string, args ...any) *Model {
+	raw := rawExpr{sql: expression, args: args}
+	if match := aliasRegex.FindStringSubmatch(expression); match != nil {
+		raw.alias = match[1]
+	}
+	m.rawSelects = append(m.rawSelects, raw)
+	return m
+}
+
+// isAlias indica si column es el alias de una agregacion o de SelectRaw
+func (m *Model) isAlias(column string) bool {
+	for _, a := range m.aggregates {
+		if a.alias == column {
+			return true
+		}
+	}
+	for _, r := range m.rawSelects {
+		if r.alias == column {
+			return true
+		}
+	}
+	return false
+}
+
+// isGrouped indica si la consulta retorna grupos o agregaciones en lugar de registros
+func (m *Model) isGrouped() bool {
+	return len(m.groups) > 0 || len(m.aggregates) > 0
+}
+
+// usesPipeline indica si en mongodb la consulta se ejecuta con un pipeline de agregacion en lugar de Find
+func (m *Model) usesPipeline() bool {
+	return m.isGrouped() || len(m.joins) > 0
+}
+
+// sql retorna la expresion sql de la agregacion
+func (a aggregate) sql() string {
+	return strings.ToUpper(a.function) + "(" + a.column + ")"
+}
+
+// compileColumns construye las columnas del select con las agregaciones y expresiones
+// si hay agregaciones y no se seleccionaron columnas se usan las del GroupBy
+func (m *Model) compileColumns() (string, []any) {
+	if len(m.aggregates) == 0 && len(m.rawSelects) == 0 {
+		return m.selectColumnsSQL(), nil
+	}
+
+	columns := append([]string(nil), m.selectedColumns...)
+	if len(columns) == 0 {
+		columns = append(columns, m.groups...)
+	}
+	for _, a := range m.aggregates {
+		columns = append(columns, a.sql()+" AS "+a.alias)
+	}
+	var args []any
+	for _, r := range m.rawSelects {
+		columns = append(columns, r.sql)
+		args = append(args, r.args...)
+	}
+	return strings.Join(columns, ", "), args
+}
+
+// compileGroups construye las clausulas GROUP BY y HAVING
+// en el having los alias de las agregaciones se cambian por la expresion porque postgresql no acepta alias
+func (m *Model) compileGroups() (string, []any) {
+	var sb strings.Builder
+	if len(m.groups) > 0 {
+		sb.WriteString(" GROUP BY ")
+		sb.WriteString(strings.Join(m.groups, ", "))
+	}
+	if len(m.havings) == 0 {
+		return sb.String(), nil
+	}
+
+	havings := make([]where, len(m.havings))
+	for i, h := range m.havings {
+		for _, a := range m.aggregates {
+			if h.column == a.alias {
+				h.column = a.sql()
+			}
+		}
+		havings[i] = h
+	}
+	havingSQL, args := compileConditions(havings)
+	sb.WriteString(" HAVING ")
+	sb.WriteString(havingSQL)
+	return sb.String(), args
+}
+
+// mongoPipeline construye el pipeline de agregacion de mongodb
+// $lookup (joins), $match (where), $group y $project (agregaciones), $match (having), $sort, $skip, $limit
+func (m *Model) mongoPipeline() (mongo.Pipeline, error) {
+	if len(m.rawSelects) > 0 {
+		return nil, fmt.Errorf("SelectRaw no es compatible con mongodb")
+	}
+
+	lookups, err := m.mongoLookupStages()
+	if err != nil {
+		return nil, err
+	}
+	pipeline := mongo.Pipeline(lookups)
+	if len(m.wheres) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: m.mongoFilter()}})
+	}
+
+	opts := m.mongoFindOptions()
+	if m.isGrouped() {
+		pipeline = append(pipeline, m.mongoGroupStages()...)
+	} else if opts.Projection != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$project", Value: opts.Projection}})
+	}
+
+	if opts.Sort != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$sort", Value: opts.Sort}})
+	}
+	if opts.Skip != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$skip", Value: *opts.Skip}})
+	}
+	if opts.Limit != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: *opts.Limit}})
+	}
+	return pipeline, nil
+}
+
+// mongoGroupStages construye las etapas $group, $project y $match del having
+func (m *Model) mongoGroupStages() []bson.D {
+	// el _id del grupo tiene las columnas del GroupBy, luego se pasan al nivel principal con $project
+	var id any
+	if len(m.groups) > 0 {
+		groupID := bson.D{}
+		for _, g := range m.groups {
+			groupID = append(groupID, bson.E{Key: g, Value: "$" + g})
+		}
+		id = groupID
+	}
+	group := bson.D{{Key: "_id", Value: id}}
+	project := bson.D{{Key: "_id", Value: 0}}
+	for _, g := range m.groups {
+		project = append(project, bson.E{Key: g, Value: "$_id." + g})
+	}
+	for _, a := range m.aggregates {
+		group = append(group, bson.E{Key: a.alias, Value: a.mongo()})
+		project = append(project, bson.E{Key: a.alias, Value: 1})
+	}
+
+	stages := []bson.D{
+		{{Key: "$group", Value: group}},
+		{{Key: "$project", Value: project}},
+	}
+	if len(m.havings) > 0 {
+		stages = append(stages, bson.D{{Key: "$match", Value: mongoConditions(m.havings)}})
+	}
+	return stages
+}
+
+// mongo retorna el acumulador de $group de la agregacion
+func (a aggregate) mongo() bson.M {
+	if a.function == "count" {
+		if a.column == "*" {
+			return bson.M{"$sum": 1}
+		}
+		// COUNT(column) no cuenta los nulos
+		return bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$" + a.column, nil}}, 1, 0}}}
+	}
+	return bson.M{"$" + a.function: "$" + a.column}
+}
+
+// getMongoAggregate ejecuta la consulta con un pipeline de agregacion en mongodb
+func (m *Model) getMongoAggregate() error {
+	pipeline, err := m.mongoPipeline()
+	if err != nil {
+		return err
+	}
+
+	data, err := remember(m, m.mongoQuery("aggregate"), []any{pipeline}, func() ([]map[string]any, error) {
+		ctx, cancel := m.context()
+		defer cancel()
+
+		collection := m.collection()
+		start := time.Now()
+		cursor, err := collection.Aggregate(ctx, pipeline)
+		if err != nil {
+			m.record(ctx, start, m.mongoQuery("aggregate"), []any{pipeline}, 0, err)
+			return nil, dbError("error en la agregación de MongoDB", err)
+		}
+		defer cursor.Close(ctx)
+
+		data := make([]map[string]any, 0)
+		err = cursor.All(ctx, &data)
+		m.record(ctx, start, m.mongoQuery("aggregate"), []any{pipeline}, int64(len(data)), err)
+		if err != nil {
+			return nil, dbError("error al leer los documentos", err)
+		}
+		return data, nil
+	})
+	if err != nil {
+		return err
+	}
+	m.Data = data
+	return nil
+}
@@ -0,0 +1,87 @@
+package orm
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestCompileAggregates(t *testing.T) {
+	setupMemory(t, postTable())
+	fakeConnection(t, "test_mysql", "mysql")
+	fakeConnection(t, "test_postgresql", "postgresql")
+
+	tests := []struct {
+		name       string
+		connection string
+		query      func(q *Model) *Model
+		want       string
+		args       []any
+	}{
+		{name: "group by y having", connection: "test_mysql", query: func(q *Model) *Model {
+			return q.Select("published").SelectCount("total").SelectSum("votes", "votes_sum").
+				GroupBy("published").Having("total", ">", 2).OrHaving("votes_sum", ">=", 10).OrderBy("total", "desc")
+		}, want: "SELECT published, COUNT(*) AS total, SUM(votes) AS votes_sum FROM posts GROUP BY published HAVING COUNT(*) > ? OR SUM(votes) >= ? ORDER BY total DESC", args: []any{2, 10}},
+		{name: "having en postgresql", connection: "test_postgresql", query: func(q *Model) *Model {
+			return q.Select("published").SelectCount("total").GroupBy("published").Having("total", ">", 2)
+		}, want: "SELECT published, COUNT(*) AS total FROM posts GROUP BY published HAVING COUNT(*) > $1", args: []any{2}},
+		{name: "agregaciones con where", connection: "test_postgresql", query: func(q *Model) *Model {
+			return q.SelectAvg("votes", "avg").SelectMin("votes", "min").SelectMax("votes", "max").Where("published", true)
+		}, want: "SELECT AVG(votes) AS avg, MIN(votes) AS min, MAX(votes) AS max FROM posts WHERE published = $1", args: []any{true}},
+		{name: "select raw", connection: "test_mysql", query: func(q *Model) *Model {
+			return q.SelectRaw("COUNT(DISTINCT slug) AS slugs").GroupBy("title")
+		}, want: "SELECT title, COUNT(DISTINCT slug) AS slugs FROM posts GROUP BY title"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.query(newPost().Connection(tt.connection))
+			got, args := q.compileSelect()
+			if q.err != nil {
+				t.Fatal(q.err)
+			}
+			if got != tt.want {
+				t.Errorf("consulta =\n%s\nse esperaba\n%s", got, tt.want)
+			}
+			if !slices.Equal(args, tt.args) {
+				t.Errorf("argumentos = %v, se esperaba %v", args, tt.args)
+			}
+		})
+	}
+
+	t.Run("having de una columna que no existe", func(t *testing.T) {
+		if q := newPost().Connection("test_mysql").Having("nada", 1); q.err == nil {
+			t.Error("Having acepto una columna que no existe ni es un alias")
+		}
+	})
+}
+
+func TestMongoAggregatePipeline(t *testing.T) {
+	setupMemory(t, postTable())
+	fakeConnection(t, "test_mongodb", "mongodb")
+
+	q := newPost().Connection("test_mongodb").Select("published").SelectCount("total").SelectSum("votes", "votes_sum").
+		GroupBy("published").Having("total", ">", 2).OrderBy("total", "desc")
+	pipeline, err := q.mongoPipeline()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[[{$group [{_id [{published $published}]} {total map[$sum:1]} {votes_sum map[$sum:$votes]}]}] " +
+		"[{$project [{_id 0} {published $_id.published} {total 1} {votes_sum 1}]}] " +
+		"[{$match map[total:map[$gt:2]]}] [{$sort [{total -1}]}]]"
+	if got := fmt.Sprint(pipeline); got != want {
+		t.Errorf("pipeline =\n%s\nse esperaba\n%s", got, want)
+	}
+}
+
+func TestMemoryAggregates(t *testing.T) {
+	setupMemory(t, postTable())
+	seedPosts(t, 10, 20)
+
+	// el driver memory solo cuenta, las demas agregaciones se prueban compilando la consulta
+	if n, err := newPost().Where("votes", ">", 10).Count(); err != nil || n != 1 {
+		t.Errorf("count = %d, %v, se esperaba 1", n, err)
+	}
+	if _, err := newPost().Sum("votes"); err == nil {
+		t.Error("el driver memory no retorno error con Sum")
+	}
+}
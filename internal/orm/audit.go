@@ -0,0 +1,156 @@
+package orm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// AuditTable es la tabla donde se guardan los cambios de los modelos auditados, la crea la migracion create_audits_table
+const AuditTable = "audits"
+
+// AuditActor son los datos del request que hizo el cambio
+type AuditActor struct {
+	UserID    func() any // retorna el usuario autenticado, es una funcion porque la autenticacion puede ser posterior al middleware
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+// auditKey es la key del contexto con el AuditActor
+type auditKey struct{}
+
+// audited son las tablas que ya registraron los handlers de auditoria
+var audited sync.Map
+
+// WithAuditActor retorna un contexto con los datos del request que se guardan en la auditoria
+// los cambios hechos con modelos que usan el contexto quedan a nombre del actor, sin actor quedan sin usuario
+func WithAuditActor(ctx context.Context, actor *AuditActor) context.Context {
+	return context.WithValue(ctx, auditKey{}, actor)
+}
+
+// Auditable guarda en la tabla audits los cambios de los registros al crear, actualizar, eliminar y restaurar
+// los valores se guardan sin las columnas de Hidden ni las de exclude, se usa en el constructor del modelo
+// el registro de la auditoria se guarda en la misma transaccion y con el mismo contexto que el cambio
+//
+//	model.Auditable("last_login_at")
+func (m *Model) Auditable(exclude ...string) {
+	if _, loaded := audited.LoadOrStore(m.tableName, true); loaded {
+		return
+	}
+	for _, event := range []string{EventCreated, EventUpdated, EventDeleted, EventRestored} {
+		On(m.tableName, event, func(e *ModelEvent) error {
+			return e.Model.audit(e, exclude)
+		})
+	}
+}
+
+// History retorna los cambios auditados del registro con la clave primaria id, del mas reciente al mas antiguo
+//
+//	history, err := users.WithContext(ctx).History(id)
+func (m *Model) History(id any) (*Model, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	history := m.newRelated(AuditTable)
+	err := history.
+		Where("auditable_type", m.tableName).
+		Where("auditable_id", keyString(id)).
+		OrderBy("created_at", "desc").
+		OrderBy(history.PrimaryKey(), "desc").
+		Get()
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// audit guarda el cambio del evento en la tabla audits
+// al crear se guardan los valores nuevos, al eliminar los anteriores y al actualizar solo las columnas que cambiaron
+func (m *Model) audit(e *ModelEvent, exclude []string) error {
+	var oldValues, newValues map[string]any
+	switch e.Name {
+	case EventCreated:
+		newValues = m.auditValues(e.Attributes, exclude)
+	case EventDeleted:
+		oldValues = m.auditValues(e.Original, exclude)
+		newValues = m.auditValues(e.Dirty, exclude)
+	default:
+		newValues = m.auditValues(e.Dirty, exclude)
+		oldValues = make(map[string]any, len(newValues))
+		for column := range newValues {
+			oldValues[column] = e.Original[column]
+		}
+		if len(newValues) == 0 {
+			// solo cambiaron columnas ocultas o excluidas
+			return nil
+		}
+	}
+
+	pk := m.PrimaryKey()
+	id, ok := e.Original[pk]
+	if !ok {
+		id = e.Attributes[pk]
+	}
+
+	audits := m.newRelated(AuditTable)
+	entry := map[string]any{
+		"auditable_type": m.tableName,
+		"auditable_id":   keyString(id),
+		"event":          e.Name,
+		"old_values":     oldValues,
+		"new_values":     newValues,
+		"created_at":     time.Now(),
+	}
+	if audits.driver() != "mongodb" {
+		// en sql los valores se guardan como json sin depender de los casts del schema, que puede no estar cargado
+		for column, values := range map[string]map[string]any{"old_values": oldValues, "new_values": newValues} {
+			if values == nil {
+				entry[column] = nil
+				continue
+			}
+			b, err := json.Marshal(values)
+			if err != nil {
+				return fmt.Errorf("error al codificar la auditoria de '%s': %w", m.tableName, err)
+			}
+			entry[column] = string(b)
+		}
+	}
+	if actor, ok := m.baseContext().Value(auditKey{}).(*AuditActor); ok && actor != nil {
+		if actor.UserID != nil {
+			if user := actor.UserID(); user != nil {
+				entry["user_id"] = keyString(user)
+			}
+		}
+		entry["ip_address"] = actor.IP
+		entry["user_agent"] = actor.UserAgent
+		entry["request_id"] = actor.RequestID
+	}
+
+	if err := audits.Create(entry); err != nil {
+		return fmt.Errorf("error al guardar la auditoria de '%s': %w", m.tableName, err)
+	}
+	return nil
+}
+
+// auditValues retorna una copia de values sin las columnas ocultas, las excluidas y las relaciones cargadas
+func (m *Model) auditValues(values map[string]any, exclude []string) map[string]any {
+	if len(values) == 0 {
+		return nil
+	}
+	var hidden []string
+	if a := m.attributeDefs(false); a != nil {
+		hidden = a.hidden
+	}
+	result := maps.Clone(values)
+	for column := range result {
+		if _, ok := m.relation(column); ok || slices.Contains(hidden, column) || slices.Contains(exclude, column) {
+			delete(result, column)
+		}
+	}
+	return result
+}
@@ -0,0 +1,92 @@
+package orm
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	// el schema no tiene la tabla audits, como en cmd/api que no carga las migraciones
+	setupMemory(t, noteTable())
+	audited.Delete("notes")
+	t.Cleanup(func() {
+		audited.Delete("notes")
+		observers.Lock()
+		delete(observers.handlers, "notes")
+		observers.Unlock()
+	})
+
+	newNote := func() *Model {
+		m := &Model{}
+		m.Table("note")
+		m.Fillable("body", "slug")
+		m.Auditable("slug")
+		return m
+	}
+	ctx := WithAuditActor(context.Background(), &AuditActor{UserID: func() any { return 7 }, IP: "127.0.0.1"})
+
+	note := newNote().WithContext(ctx)
+	tests := []struct {
+		name  string
+		write func() error
+		event string
+		old   map[string]any
+		new   map[string]any
+	}{
+		{name: "create", write: func() error { return note.Create(map[string]any{"body": "hola", "slug": "hola"}) },
+			event: EventCreated, new: map[string]any{"id": float64(1), "body": "hola"}},
+		{name: "update", write: func() error { return note.Update(map[string]any{"body": "editado"}) },
+			event: EventUpdated, old: map[string]any{"body": "hola"}, new: map[string]any{"body": "editado"}},
+		{name: "update de una columna excluida", write: func() error { return note.Update(map[string]any{"slug": "otro"}) }},
+		{name: "delete", write: note.Delete, event: EventDeleted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, _ := MemoryCount(AuditTable, nil)
+			if err := tt.write(); err != nil {
+				t.Fatal(err)
+			}
+			after, _ := MemoryCount(AuditTable, nil)
+			if tt.event == "" {
+				if after != before {
+					t.Errorf("se guardaron %d auditorias, no se esperaba ninguna", after-before)
+				}
+				return
+			}
+			if after != before+1 {
+				t.Fatalf("se guardaron %d auditorias, se esperaba 1", after-before)
+			}
+
+			history, err := newNote().History(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entry := history.Data[0]
+			if entry["event"] != tt.event || entry["user_id"] != "7" || entry["ip_address"] != "127.0.0.1" {
+				t.Errorf("auditoria = %v, se esperaba el evento %s del usuario 7", entry, tt.event)
+			}
+			for column, want := range map[string]map[string]any{"old_values": tt.old, "new_values": tt.new} {
+				if want == nil {
+					continue
+				}
+				s, ok := entry[column].(string)
+				if !ok {
+					t.Fatalf("%s = %#v, se esperaba json", column, entry[column])
+				}
+				var got map[string]any
+				if err := json.Unmarshal([]byte(s), &got); err != nil {
+					t.Fatal(err)
+				}
+				for key, value := range want {
+					if got[key] != value {
+						t.Errorf("%s[%s] = %v, se esperaba %v", column, key, got[key], value)
+					}
+				}
+				if _, ok := got["slug"]; ok {
+					t.Errorf("%s tiene la columna excluida slug: %s", column, s)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,468 @@
+package orm
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math/big"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AccessorFunc transforma el valor de la columna al leerlo de la base de datos, row es el registro completo
+// si la columna no existe en la tabla el accessor crea una columna calculada que no se guarda
+type AccessorFunc func(value any, row map[string]any) any
+
+// MutatorFunc transforma el valor de la columna antes de guardarlo, row son los valores que se van a guardar
+type MutatorFunc func(value any, row map[string]any) (any, error)
+
+// attributeSet son los casts, accessors y mutators definidos para una tabla
+// se comparte entre las copias del modelo y se registra con Register para las relaciones
+type attributeSet struct {
+	casts     map[string]string
+	types     map[string]reflect.Type // tipo al que se decodifican las columnas json definidas con CastJSON
+	accessors map[string]AccessorFunc
+	mutators  map[string]MutatorFunc
+	hidden    []string // columnas que no se incluyen en el json
+	visible   []string // si no esta vacio solo estas columnas se incluyen en el json
+	appends   []string // columnas calculadas que se agregan al json
+}
+
+// Casts define como se convierten las columnas al leerlas y al guardarlas, se usa en el constructor del modelo
+// por defecto se usa el tipo de la columna en la migracion, los casts definidos aqui lo reemplazan
+//
+//	model.Casts(map[string]string{
+//		"active":    "bool",
+//		"age":       "int",
+//		"rating":    "float",
+//		"price":     "decimal:2",      // string con 2 decimales
+//		"birthday":  "date",           // time.Time sin hora
+//		"starts_at": "datetime:15:04", // string con el formato, sin formato es time.Time
+//		"settings":  "json",           // map[string]any o []any
+//		"ssn":       "encrypted",      // se guarda encriptado con APP_KEY
+//		"status":    "enum:draft,published",
+//	})
+func (m *Model) Casts(casts map[string]string) {
+	maps.Copy(m.attributeDefs(true).casts, casts)
+}
+
+// CastJSON define una columna json que se decodifica en un valor del tipo de target: model.CastJSON("settings", Settings{})
+func (m *Model) CastJSON(column string, target any) {
+	a := m.attributeDefs(true)
+	a.casts[column] = "json"
+	a.types[column] = reflect.TypeOf(target)
+}
+
+// Accessor define una funcion que transforma el valor de la columna al leerlo
+//
+//	model.Accessor("full_name", func(value any, row map[string]any) any {
+//		return fmt.Sprint(row["first_name"], " ", row["last_name"])
+//	})
+func (m *Model) Accessor(column string, fn AccessorFunc) {
+	m.attributeDefs(true).accessors[column] = fn
+}
+
+// Mutator define una funcion que transforma el valor de la columna antes de guardarlo
+//
+//	model.Mutator("email", func(value any, row map[string]any) (any, error) {
+//		return strings.ToLower(fmt.Sprint(value)), nil
+//	})
+func (m *Model) Mutator(column string, fn MutatorFunc) {
+	m.attributeDefs(true).mutators[column] = fn
+}
+
+// attributeDefs retorna los casts del modelo y si no tiene los registrados para la tabla
+// si create es true y el modelo no tiene casts se crean
+func (m *Model) attributeDefs(create bool) *attributeSet {
+	if m.attributes != nil {
+		return m.attributes
+	}
+	if create {
+		m.attributes = &attributeSet{
+			casts:     make(map[string]string),
+			types:     make(map[string]reflect.Type),
+			accessors: make(map[string]AccessorFunc),
+			mutators:  make(map[string]MutatorFunc),
+		}
+		return m.attributes
+	}
+	registry.RLock()
+	defer registry.RUnlock()
+	return registry.attributes[m.tableName]
+}
+
+// castFor retorna el cast de la columna, el definido con Casts o el que corresponde a su tipo en la migracion
+func (m *Model) castFor(a *attributeSet, column string) string {
+	if a != nil {
+		if cast, ok := a.casts[column]; ok {
+			return cast
+		}
+	}
+	if m.table == nil {
+		return ""
+	}
+	for _, col := range m.table.Columns {
+		if col.Name == column {
+			return columnCast(col.Type, col.Scale, col.Constraints["enum"])
+		}
+	}
+	return ""
+}
+
+// columnCast retorna el cast por defecto de un tipo de columna de la migracion
+func columnCast(typ string, scale *int, enum string) string {
+	switch {
+	case typ == "boolean":
+		return "bool"
+	case strings.HasPrefix(typ, "int"), strings.HasPrefix(typ, "uint"):
+		return "int"
+	case strings.HasPrefix(typ, "float"):
+		return "float"
+	case typ == "decimal":
+		if scale != nil {
+			return "decimal:" + strconv.Itoa(*scale)
+		}
+		return "decimal"
+	case typ == "date":
+		return "date"
+	case typ == "datetime", typ == "timestamp", typ == "timestamptz":
+		return "datetime"
+	case typ == "json", typ == "jsonb":
+		return "json"
+	case typ == "enum":
+		// los valores de la migracion vienen entre comillas: 'a', 'b'
+		values := strings.Split(enum, ",")
+		for i, v := range values {
+			values[i] = strings.Trim(strings.TrimSpace(v), "'")
+		}
+		return "enum:" + strings.Join(values, ",")
+	case typ == "char", typ == "varchar", strings.HasSuffix(typ, "text"):
+		return "string"
+	}
+	return ""
+}
+
+// isComputed indica si la columna es calculada por un accessor y no existe en la tabla
+func (m *Model) isComputed(column string) bool {
+	a := m.attributeDefs(false)
+	if a == nil || a.accessors[column] == nil {
+		return false
+	}
+	return m.hasMigration && !m.HasColumn(column)
+}
+
+// castRows aplica los casts y los accessors a los registros leidos de la base de datos
+func (m *Model) castRows(rows []map[string]any) error {
+	for _, row := range rows {
+		if err := m.castValues(row); err != nil {
+			return err
+		}
+		m.applyAccessors(row, nil)
+	}
+	return nil
+}
+
+// castValues convierte los valores leidos de la base de datos segun el cast de cada columna
+func (m *Model) castValues(row map[string]any) error {
+	a := m.attributeDefs(false)
+	for column, value := range row {
+		cast := m.castFor(a, column)
+		if cast == "" || value == nil {
+			continue
+		}
+		var typ reflect.Type
+		if a != nil {
+			typ = a.types[column]
+		}
+		v, err := castGet(cast, value, typ)
+		if err != nil {
+			return fmt.Errorf("error al convertir la columna '%s': %w", column, err)
+		}
+		row[column] = v
+	}
+	return nil
+}
+
+// applyAccessors ejecuta los accessors sobre el registro
+// si changed no es nil solo se ejecutan los de las columnas que cambiaron y los de las columnas calculadas
+func (m *Model) applyAccessors(row map[string]any, changed map[string]any) {
+	a := m.attributeDefs(false)
+	if a == nil {
+		return
+	}
+	for column, fn := range a.accessors {
+		// los accessors de Append se ejecutan al convertir a json
+		if slices.Contains(a.appends, column) {
+			continue
+		}
+		if _, ok := changed[column]; changed != nil && !ok && !m.isComputed(column) {
+			continue
+		}
+		row[column] = fn(row[column], row)
+	}
+}
+
+// castAttributes aplica los mutators y los casts a los valores que se van a guardar
+// retorna una copia con los valores como se guardan en la base de datos
+func (m *Model) castAttributes(values map[string]any) (map[string]any, error) {
+	a := m.attributeDefs(false)
+	result := maps.Clone(values)
+	if a != nil {
+		for _, column := range slices.Sorted(maps.Keys(a.mutators)) {
+			value, ok := result[column]
+			if !ok {
+				continue
+			}
+			v, err := a.mutators[column](value, result)
+			if err != nil {
+				return nil, fmt.Errorf("error en el mutator de la columna '%s': %w", column, err)
+			}
+			result[column] = v
+		}
+	}
+	driver := m.driver()
+	for column, value := range result {
+		cast := m.castFor(a, column)
+		if cast == "" || value == nil {
+			continue
+		}
+		v, err := castSet(driver, cast, value)
+		if err != nil {
+			return nil, fmt.Errorf("error al convertir la columna '%s': %w", column, err)
+		}
+		result[column] = v
+	}
+	return result, nil
+}
+
+// castGet convierte un valor leido de la base de datos
+// typ es el tipo al que se decodifica una columna json, si es nil se usa map[string]any o []any
+func castGet(cast string, value any, typ reflect.Type) (any, error) {
+	kind, arg, _ := strings.Cut(cast, ":")
+	switch kind {
+	case "string", "enum":
+		return toString(value)
+	case "bool":
+		return toBool(value)
+	case "int":
+		return toInt64(value)
+	case "float":
+		return toFloat64(value)
+	case "decimal":
+		return formatDecimal(value, arg)
+	case "date", "datetime":
+		t, err := toTime(value)
+		if err != nil {
+			return nil, err
+		}
+		if kind == "date" {
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		}
+		if arg != "" {
+			return t.Format(arg), nil
+		}
+		return t, nil
+	case "json":
+		if typ != nil {
+			dst := reflect.New(typ).Elem()
+			if err := assignJSON(dst, value); err != nil {
+				return nil, err
+			}
+			return dst.Interface(), nil
+		}
+		var raw []byte
+		switch v := value.(type) {
+		case []byte:
+			raw = v
+		case string:
+			raw = []byte(v)
+		default:
+			// mongodb ya retorna los documentos decodificados
+			return value, nil
+		}
+		var result any
+		if err := json.Unmarshal(raw, &result); err != nil {
+			return nil, fmt.Errorf("error al decodificar json: %w", err)
+		}
+		return result, nil
+	case "encrypted":
+		s, err := toString(value)
+		if err != nil {
+			return nil, err
+		}
+		return decrypt(s)
+	}
+	return nil, fmt.Errorf("el cast '%s' no existe", cast)
+}
+
+// castSet convierte un valor al formato en que se guarda en la base de datos del driver
+func castSet(driver string, cast string, value any) (any, error) {
+	kind, arg, _ := strings.Cut(cast, ":")
+	switch kind {
+	case "string":
+		return toString(value)
+	case "bool":
+		return toBool(value)
+	case "int":
+		return toInt64(value)
+	case "float":
+		return toFloat64(value)
+	case "decimal":
+		s, err := formatDecimal(value, arg)
+		if err != nil {
+			return nil, err
+		}
+		if driver == "mongodb" {
+			return primitive.ParseDecimal128(s)
+		}
+		return s, nil
+	case "date", "datetime":
+		var t time.Time
+		var err error
+		if s, ok := value.(string); ok && arg != "" {
+			t, err = time.ParseInLocation(arg, s, time.Local)
+		} else {
+			t, err = toTime(value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if kind == "date" {
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		}
+		return t, nil
+	case "json":
+		switch v := value.(type) {
+		case string, []byte:
+			if driver != "mongodb" {
+				return value, nil
+			}
+			// en mongodb se guarda como documento
+			var result any
+			if err := json.Unmarshal([]byte(fmt.Sprint(v)), &result); err != nil {
+				return nil, fmt.Errorf("error al decodificar json: %w", err)
+			}
+			return result, nil
+		}
+		if driver == "mongodb" {
+			return value, nil
+		}
+		b, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("error al codificar json: %w", err)
+		}
+		return string(b), nil
+	case "encrypted":
+		s, err := toString(value)
+		if err != nil {
+			return nil, err
+		}
+		return encrypt(s)
+	case "enum":
+		s, err := toString(value)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(strings.Split(arg, ","), s) {
+			return nil, fmt.Errorf("el valor '%s' no es válido, debe ser uno de: %s", s, arg)
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("el cast '%s' no existe", cast)
+}
+
+// formatDecimal convierte el valor en un string decimal exacto, si scale no es vacio se redondea a esa cantidad de decimales
+func formatDecimal(value any, scale string) (string, error) {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = strings.TrimSpace(v)
+	case []byte:
+		s = strings.TrimSpace(string(v))
+	case primitive.Decimal128:
+		s = v.String()
+	default:
+		f, err := toFloat64(value)
+		if err != nil {
+			return "", err
+		}
+		s = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return "", fmt.Errorf("'%s' no es un número decimal", s)
+	}
+	if scale == "" {
+		return s, nil
+	}
+	n, err := strconv.Atoi(scale)
+	if err != nil {
+		return "", fmt.Errorf("la escala '%s' del cast decimal no es válida", scale)
+	}
+	return r.FloatString(n), nil
+}
+
+// encryptionKey es la llave de los campos encriptados, se deriva de APP_KEY
+// a diferencia de los cursores no se genera una aleatoria porque los datos no se podrian leer al reiniciar
+var encryptionKey = sync.OnceValues(func() ([]byte, error) {
+	key := os.Getenv("APP_KEY")
+	if key == "" {
+		return nil, fmt.Errorf("APP_KEY no está definida, es necesaria para los campos encriptados")
+	}
+	sum := sha256.Sum256([]byte(key))
+	return sum[:], nil
+})
+
+// encrypt encripta el texto con AES-GCM y lo retorna en base64 con el nonce al inicio
+func encrypt(plain string) (string, error) {
+	gcm, err := newGCM()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("error al generar el nonce: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(plain), nil)), nil
+}
+
+// decrypt desencripta un texto generado por encrypt
+func decrypt(encoded string) (string, error) {
+	gcm, err := newGCM()
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("el valor encriptado no es válido")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("no se pudo desencriptar el valor: %w", err)
+	}
+	return string(plain), nil
+}
+
+func newGCM() (cipher.AEAD, error) {
+	key, err := encryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,59 @@
+package orm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCasts(t *testing.T) {
+	setupMemory(t, postTable())
+
+	tests := []struct {
+		name    string
+		define  func(m *Model)
+		values  map[string]any
+		column  string
+		want    any
+		wantErr bool
+	}{
+		{name: "int de la migracion", values: map[string]any{"votes": "7"}, column: "votes", want: int64(7)},
+		{name: "bool de la migracion", values: map[string]any{"published": 1}, column: "published", want: true},
+		{name: "json de la migracion", values: map[string]any{"meta": map[string]any{"tags": []any{"go"}}}, column: "meta", want: map[string]any{"tags": []any{"go"}}},
+		{name: "decimal", define: func(m *Model) { m.Casts(map[string]string{"title": "decimal:2"}) }, values: map[string]any{"title": 3.14159}, column: "title", want: "3.14"},
+		{name: "enum valido", define: func(m *Model) { m.Casts(map[string]string{"title": "enum:draft,published"}) }, values: map[string]any{"title": "draft"}, column: "title", want: "draft"},
+		{name: "enum invalido", define: func(m *Model) { m.Casts(map[string]string{"title": "enum:draft,published"}) }, values: map[string]any{"title": "otro"}, wantErr: true},
+		{name: "mutator y accessor", define: func(m *Model) {
+			m.Mutator("title", func(value any, row map[string]any) (any, error) { return strings.ToLower(value.(string)), nil })
+			m.Accessor("title", func(value any, row map[string]any) any { return "[" + value.(string) + "]" })
+		}, values: map[string]any{"title": "HOLA"}, column: "title", want: "[hola]"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newPost()
+			if tt.define != nil {
+				tt.define(m)
+			}
+			tt.values["slug"] = "cast-" + string(rune('a'+i))
+			err := m.Create(tt.values)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("se esperaba un error al guardar")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			found := newPost()
+			found.attributes = m.attributes
+			if err := found.Find(m.Data[0]["id"]); err != nil {
+				t.Fatal(err)
+			}
+			if got := found.Data[0][tt.column]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s = %#v, se esperaba %#v", tt.column, got, tt.want)
+			}
+		})
+	}
+}
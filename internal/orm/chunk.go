@@ -0,0 +1,76 @@
+package orm
+
+import (
+	"fmt"
+)
+
+// Chunk recorre los resultados de la consulta en bloques de size registros
+// fn recibe cada bloque, si retorna un error se detiene el recorrido y se retorna el error
+// si la consulta no tiene orden se ordena por la clave primaria para que los bloques sean estables
+// los bloques se consultan con OFFSET, si la tabla cambia durante el recorrido se debe usar ChunkByID
+func (m *Model) Chunk(size int, fn func(rows []map[string]any) error) error {
+	if size < 1 {
+		return fmt.Errorf("el tamaño del bloque debe ser mayor a 0")
+	}
+
+	query := m.clone()
+	if len(query.orders) == 0 {
+		query.OrderBy(query.PrimaryKey())
+	}
+
+	for page := 0; ; page++ {
+		chunk := query.clone().Limit(size).Offset(page * size)
+		if err := chunk.Get(); err != nil {
+			return err
+		}
+		if len(chunk.Data) == 0 {
+			return nil
+		}
+		if err := fn(chunk.Data); err != nil {
+			return err
+		}
+		if len(chunk.Data) < size {
+			return nil
+		}
+	}
+}
+
+// ChunkByID recorre los resultados en bloques de size registros ordenados por la clave primaria
+// cada bloque continua despues de la ultima clave del anterior (WHERE pk > ?) en lugar de usar OFFSET,
+// asi los registros que se insertan o eliminan durante el recorrido no hacen que se salten o repitan registros
+// el orden de la consulta se reemplaza por el de la clave primaria
+func (m *Model) ChunkByID(size int, fn func(rows []map[string]any) error) error {
+	if size < 1 {
+		return fmt.Errorf("el tamaño del bloque debe ser mayor a 0")
+	}
+
+	query := m.clone()
+	pk := query.PrimaryKey()
+	query.orders = nil
+	query.OrderBy(pk).Limit(size).Offset(0)
+	// la condicion pk > ? debe aplicar a todas las condiciones de la consulta, incluidas las de OrWhere
+	query.groupWheres()
+
+	var last any
+	for {
+		chunk := query.clone()
+		if last != nil {
+			chunk.Where(pk, ">", last)
+		}
+		if err := chunk.Get(); err != nil {
+			return err
+		}
+		if len(chunk.Data) == 0 {
+			return nil
+		}
+		if err := fn(chunk.Data); err != nil {
+			return err
+		}
+		if len(chunk.Data) < size {
+			return nil
+		}
+		if last = chunk.Data[len(chunk.Data)-1][pk]; last == nil {
+			return fmt.Errorf("los resultados no tienen la clave primaria '%s'", pk)
+		}
+	}
+}
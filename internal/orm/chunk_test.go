@@ -0,0 +1,71 @@
+package orm
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestChunk(t *testing.T) {
+	setupMemory(t, postTable())
+	seedPosts(t, 30, 10, 20, 10, 30, 20, 10)
+	errStop := errors.New("stop")
+
+	tests := []struct {
+		name    string
+		chunk   func(q *Model, fn func(rows []map[string]any) error) error
+		query   func(q *Model) *Model
+		stop    int
+		want    [][]int64
+		wantErr error
+	}{
+		{name: "chunk", chunk: func(q *Model, fn func([]map[string]any) error) error { return q.Chunk(3, fn) },
+			query: func(q *Model) *Model { return q }, want: [][]int64{{1, 2, 3}, {4, 5, 6}, {7}}},
+		{name: "chunk con orden", chunk: func(q *Model, fn func([]map[string]any) error) error { return q.Chunk(4, fn) },
+			query: func(q *Model) *Model { return q.OrderBy("id", "desc") }, want: [][]int64{{7, 6, 5, 4}, {3, 2, 1}}},
+		{name: "chunk by id", chunk: func(q *Model, fn func([]map[string]any) error) error { return q.ChunkByID(2, fn) },
+			query: func(q *Model) *Model { return q.Where("votes", ">", 10) }, want: [][]int64{{1, 3}, {5, 6}}},
+		{name: "chunk by id con or", chunk: func(q *Model, fn func([]map[string]any) error) error { return q.ChunkByID(2, fn) },
+			query: func(q *Model) *Model { return q.Where("votes", 10).OrWhere("votes", 30) }, want: [][]int64{{1, 2}, {4, 5}, {7}}},
+		{name: "error de fn", chunk: func(q *Model, fn func([]map[string]any) error) error { return q.ChunkByID(2, fn) },
+			query: func(q *Model) *Model { return q }, stop: 2, want: [][]int64{{1, 2}, {3, 4}}, wantErr: errStop},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][]int64
+			err := tt.chunk(tt.query(newPost()), func(rows []map[string]any) error {
+				got = append(got, ids(&Model{Data: rows}))
+				if len(got) > len(tt.want) {
+					return errors.New("demasiados bloques")
+				}
+				if len(got) == tt.stop {
+					return errStop
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, se esperaba %v", err, tt.wantErr)
+			}
+			if !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("bloques = %v, se esperaba %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("chunk by id ve los registros insertados", func(t *testing.T) {
+		var got []int64
+		err := newPost().Where("votes", 10).ChunkByID(2, func(rows []map[string]any) error {
+			got = append(got, ids(&Model{Data: rows})...)
+			if len(got) == 2 {
+				return newPost().Create(map[string]any{"title": "nuevo", "slug": "nuevo", "votes": 10})
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []int64{2, 4, 7, 8}; !slices.Equal(got, want) {
+			t.Errorf("ids = %v, se esperaba %v", got, want)
+		}
+	})
+}
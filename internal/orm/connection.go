@@ -0,0 +1,335 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultConnection es el nombre de la conexion que usan los modelos si no se asigna otra con Connection
+const DefaultConnection = "default"
+
+// connection es una conexion abierta con una base de datos
+type connection struct {
+	name     string
+	config   string        // conexion de la que se toman las variables de entorno, vacio usa name
+	driver   string        // mongodb, mysql, postgresql o memory
+	sql      *sql.DB       // conexion de mysql o postgresql, es nil en mongodb
+	mongo    *mongo.Client // cliente de mongodb, es nil en sql
+	memory   *memoryStore  // tablas en memoria del driver memory para las pruebas
+	database string        // nombre de la base de datos, vacio usa la variable NAME
+	schema   string        // search_path de postgresql, se usa en tenancy por schema
+	err      error         // error por el que la conexion no se puede usar
+
+	replicas []*replica    // replicas de lectura de sql, sql es el primario
+	next     atomic.Uint64 // contador del round-robin de las replicas
+	sticky   bool          // las lecturas van al primario despues de una escritura en el mismo contexto
+	done     chan struct{} // detiene la verificacion de las replicas
+
+	packetOnce sync.Once // consulta max_allowed_packet de mysql la primera vez que se inserta en lotes
+	packetSize int       // max_allowed_packet de mysql en bytes
+}
+
+// connections almacena las conexiones abiertas por nombre
+var connections = struct {
+	sync.RWMutex
+	m map[string]*connection
+}{m: make(map[string]*connection)}
+
+// connKey es la key del contexto con el nombre de la conexion para Raw, Exec y Transaction
+type connKey struct{}
+
+// Connection asigna la conexion con la que trabaja el modelo, se usa en el constructor del modelo
+// el nombre debe estar en DB_CONNECTIONS: model.Connection("analytics")
+func (m *Model) Connection(name string) *Model {
+	m.connName = name
+	return m
+}
+
+// UseConnection retorna un contexto para que Raw, Exec y Transaction usen la conexion name en lugar de default
+//
+//	ctx = orm.UseConnection(ctx, "analytics")
+//	rows, err := orm.Raw(ctx, "SELECT * FROM events WHERE type = ?", "login")
+func UseConnection(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, connKey{}, name)
+}
+
+// Close cierra todas las conexiones abiertas, se debe llamar al terminar la aplicacion
+func Close() error {
+	connections.Lock()
+	defer connections.Unlock()
+
+	var errs []error
+	for name, conn := range connections.m {
+		if err := conn.close(); err != nil {
+			errs = append(errs, fmt.Errorf("error al cerrar la conexión '%s': %w", name, err))
+		}
+		delete(connections.m, name)
+	}
+	return errors.Join(errs...)
+}
+
+// close cierra la conexion, sus replicas y detiene su verificacion
+func (c *connection) close() error {
+	var errs []error
+	if c.done != nil {
+		close(c.done)
+	}
+	for _, r := range c.replicas {
+		if err := r.db.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("réplica '%s': %w", r.host, err))
+		}
+	}
+	if c.sql != nil {
+		errs = append(errs, c.sql.Close())
+	}
+	if c.mongo != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		errs = append(errs, c.mongo.Disconnect(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// getConnection retorna la conexion con el nombre, si no esta abierta retorna una sin driver
+func getConnection(name string) *connection {
+	name = connectionName(name)
+	connections.RLock()
+	defer connections.RUnlock()
+	if conn, ok := connections.m[name]; ok {
+		return conn
+	}
+	return &connection{name: name}
+}
+
+// contextConnection retorna la conexion de la transaccion del contexto, la de UseConnection,
+// la del tenant del contexto en tenancy por base de datos o schema o la default
+func contextConnection(ctx context.Context) *connection {
+	if tx, ok := ctx.Value(txKey{}).(*Tx); ok {
+		return tx.conn
+	}
+	name, _ := ctx.Value(connKey{}).(string)
+	if _, ok := TenantID(ctx); ok && name == "" && tenantPerDatabase() {
+		return tenantConnection(ctx)
+	}
+	return getConnection(name)
+}
+
+// conn retorna la conexion del modelo, dentro de una transaccion es la de la transaccion
+// las tablas de tenant en tenancy por base de datos o schema usan la conexion del tenant del contexto
+func (m *Model) conn() *connection {
+	if m.tx != nil {
+		return m.tx.conn
+	}
+	if m.usesTenantConnection() {
+		return tenantConnection(m.baseContext())
+	}
+	return getConnection(m.connName)
+}
+
+// unsupported retorna el error de una conexion que no se puede usar o con un driver no soportado
+func (c *connection) unsupported() error {
+	if c.err != nil {
+		return c.err
+	}
+	return fmt.Errorf("driver de base de datos '%s' no soportado", c.driver)
+}
+
+// driver retorna el driver de la conexion del modelo
+func (m *Model) driver() string {
+	return m.conn().driver
+}
+
+// collection retorna la coleccion de mongodb del modelo
+func (m *Model) collection() *mongo.Collection {
+	return m.conn().collection(m.tableName)
+}
+
+// collection retorna la coleccion de mongodb con el nombre
+func (c *connection) collection(name string) *mongo.Collection {
+	return c.mongo.Database(c.database).Collection(name)
+}
+
+// env retorna la variable de entorno de la conexion o de la conexion de la que toma la configuracion
+func (c *connection) env(key string) string {
+	if c.config != "" {
+		return env(c.config, key)
+	}
+	return env(c.name, key)
+}
+
+// env retorna la variable de entorno de la conexion
+// en default es DB_<KEY> (MONGO_URI para la uri de mongodb) y en las demas DB_<NAME>_<KEY>
+func env(name string, key string) string {
+	if name == DefaultConnection {
+		if key == "MONGO_URI" {
+			return os.Getenv("MONGO_URI")
+		}
+		return os.Getenv("DB_" + key)
+	}
+	return os.Getenv("DB_" + strings.ToUpper(name) + "_" + key)
+}
+
+// envInt retorna la variable de entorno de la conexion como entero, ok es false si no esta definida o no es valida
+func (c *connection) envInt(key string) (int, bool) {
+	value, err := strconv.Atoi(c.env(key))
+	return value, err == nil
+}
+
+// envDuration retorna la variable de entorno de la conexion como duracion (30s, 5m)
+func (c *connection) envDuration(key string) (time.Duration, bool) {
+	value, err := time.ParseDuration(c.env(key))
+	return value, err == nil
+}
+
+// openConnection abre la conexion con el nombre segun sus variables de entorno
+func openConnection(name string) (*connection, error) {
+	conn := &connection{name: name, driver: env(name, "DRIVER")}
+	if conn.driver == "" {
+		conn.driver = "mongodb"
+	}
+	return conn, conn.open()
+}
+
+// open abre la conexion segun su driver
+func (c *connection) open() error {
+	switch c.driver {
+	case "mongodb":
+		return c.openMongoDB()
+	case "mysql", "postgresql":
+		return c.openSQL()
+	case "memory":
+		return c.openMemory()
+	}
+	return c.unsupported()
+}
+
+// openSQL abre la conexion con mysql o postgresql y sus replicas de lectura
+// el primario es DB_WRITE_HOST=host:puerto o DB_HOST y DB_PORT, las replicas se listan en DB_READ_HOSTS
+func (c *connection) openSQL() error {
+	host, port := c.env("HOST"), c.env("PORT")
+	if writeHost := c.env("WRITE_HOST"); writeHost != "" {
+		host, port = splitHost(writeHost, port)
+	}
+
+	db, err := c.openDB(host, port)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return fmt.Errorf("error al verificar la conexión: %w", err)
+	}
+	c.sql = db
+
+	c.sticky, _ = strconv.ParseBool(c.env("STICKY"))
+	if err := c.openReplicas(); err != nil {
+		// se cierran el primario y las replicas que ya se abrieron
+		c.close()
+		c.sql, c.replicas = nil, nil
+		return err
+	}
+	return nil
+}
+
+// openDB abre un pool de conexiones con el host y configura su tamaño
+// para postgresql se usa lib/pq que se registra como "postgres"
+func (c *connection) openDB(host string, port string) (*sql.DB, error) {
+	user, password, database := c.env("USER"), c.env("PASSWORD"), c.env("NAME")
+	if c.database != "" {
+		database = c.database
+	}
+
+	driverName := "mysql"
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=%s&collation=%s&parseTime=True&loc=Local",
+		user, password, host, port, database, c.env("CHARSET"), c.env("COLLATION"))
+	if c.driver == "postgresql" {
+		driverName = "postgres"
+		sslMode := c.env("SSLMODE")
+		if sslMode == "" {
+			sslMode = "disable"
+		}
+		dsn = fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s", host, port, user, password, database, sslMode)
+		if c.schema != "" {
+			dsn += " search_path=" + c.schema
+		}
+	}
+
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error al abrir la conexión con '%s': %w", host, err)
+	}
+
+	// pool de conexiones
+	if n, ok := c.envInt("MAX_OPEN_CONNS"); ok {
+		db.SetMaxOpenConns(n)
+	}
+	if n, ok := c.envInt("MAX_IDLE_CONNS"); ok {
+		db.SetMaxIdleConns(n)
+	}
+	if d, ok := c.envDuration("CONN_MAX_LIFETIME"); ok {
+		db.SetConnMaxLifetime(d)
+	}
+	if d, ok := c.envDuration("CONN_MAX_IDLE_TIME"); ok {
+		db.SetConnMaxIdleTime(d)
+	}
+	return db, nil
+}
+
+// openMongoDB abre la conexion con mongodb y configura el pool
+func (c *connection) openMongoDB() error {
+	clientOptions := options.Client().ApplyURI(c.env("MONGO_URI"))
+
+	// pool de conexiones
+	if n, ok := c.envInt("MONGO_MAX_POOL_SIZE"); ok {
+		clientOptions.SetMaxPoolSize(uint64(n))
+	}
+	if n, ok := c.envInt("MONGO_MIN_POOL_SIZE"); ok {
+		clientOptions.SetMinPoolSize(uint64(n))
+	}
+	if d, ok := c.envDuration("MONGO_MAX_CONN_IDLE_TIME"); ok {
+		clientOptions.SetMaxConnIdleTime(d)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, clientOptions)
+	if err != nil {
+		return fmt.Errorf("error conectando a MongoDB: %w", err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.Background())
+		return fmt.Errorf("no se pudo conectar a MongoDB: %w", err)
+	}
+
+	c.mongo = client
+	if c.database == "" {
+		c.database = c.env("NAME")
+	}
+	return nil
+}
+
+// connectionNames retorna default y las conexiones de DB_CONNECTIONS
+func connectionNames() []string {
+	names := []string{DefaultConnection}
+	for _, name := range strings.Split(os.Getenv("DB_CONNECTIONS"), ",") {
+		name = strings.TrimSpace(name)
+		if name != "" && name != DefaultConnection {
+			names = append(names, name)
+		}
+	}
+	return names
+}
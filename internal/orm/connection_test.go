@@ -0,0 +1,113 @@
+package orm
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestEnv(t *testing.T) {
+	t.Setenv("DB_HOST", "primario")
+	t.Setenv("MONGO_URI", "mongodb://default")
+	t.Setenv("DB_ANALYTICS_HOST", "analytics")
+	t.Setenv("DB_ANALYTICS_MONGO_URI", "mongodb://analytics")
+
+	tests := []struct {
+		name string
+		conn *connection
+		key  string
+		want string
+	}{
+		{name: "default", conn: &connection{name: DefaultConnection}, key: "HOST", want: "primario"},
+		{name: "uri de mongodb en default", conn: &connection{name: DefaultConnection}, key: "MONGO_URI", want: "mongodb://default"},
+		{name: "conexion con nombre", conn: &connection{name: "analytics"}, key: "HOST", want: "analytics"},
+		{name: "uri de mongodb con nombre", conn: &connection{name: "analytics"}, key: "MONGO_URI", want: "mongodb://analytics"},
+		{name: "configuracion de otra conexion", conn: &connection{name: "tenant_a", config: "analytics"}, key: "HOST", want: "analytics"},
+		{name: "sin definir", conn: &connection{name: "legacy"}, key: "HOST", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.conn.env(tt.key); got != tt.want {
+				t.Errorf("env(%s) = %q, se esperaba %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConnectionNames(t *testing.T) {
+	tests := []struct {
+		connections string
+		want        []string
+	}{
+		{connections: "", want: []string{DefaultConnection}},
+		{connections: "analytics, legacy", want: []string{DefaultConnection, "analytics", "legacy"}},
+		{connections: "default,,analytics,", want: []string{DefaultConnection, "analytics"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.connections, func(t *testing.T) {
+			t.Setenv("DB_CONNECTIONS", tt.connections)
+			if got := connectionNames(); !slices.Equal(got, tt.want) {
+				t.Errorf("conexiones = %v, se esperaba %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamedConnection(t *testing.T) {
+	setupMemory(t, noteTable())
+	t.Setenv("DB_ANALYTICS_DRIVER", "memory")
+	analytics, err := openConnection("analytics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	connections.Lock()
+	connections.m["analytics"] = analytics
+	connections.Unlock()
+	t.Cleanup(func() {
+		connections.Lock()
+		delete(connections.m, "analytics")
+		connections.Unlock()
+	})
+
+	newNote := func(name string) *Model {
+		m := &Model{}
+		m.Table("note")
+		m.Fillable("body")
+		return m.Connection(name)
+	}
+	if err := newNote("analytics").Create(map[string]any{"body": "evento"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		connection string
+		want       int64
+		wantErr    bool
+	}{
+		{name: "default", connection: "", want: 0},
+		{name: "analytics", connection: "analytics", want: 1},
+		{name: "conexion sin abrir", connection: "legacy", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := newNote(tt.connection).Count()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, se esperaba error: %v", err, tt.wantErr)
+			}
+			if n != tt.want {
+				t.Errorf("count = %d, se esperaba %d", n, tt.want)
+			}
+		})
+	}
+
+	t.Run("UseConnection", func(t *testing.T) {
+		ctx := UseConnection(context.Background(), "analytics")
+		if conn := contextConnection(ctx); conn != analytics {
+			t.Errorf("conexion del contexto = %s, se esperaba analytics", conn.name)
+		}
+		if conn := contextConnection(context.Background()); conn.name != DefaultConnection {
+			t.Errorf("conexion sin UseConnection = %s, se esperaba default", conn.name)
+		}
+	})
+}
@@ -1 +1,145 @@
 package orm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Create inserta un registro en la base de datos con los datos que permitan fillable y guarded
+// el registro creado con su clave primaria queda en `m.Data`
+// se disparan los eventos saving, creating, created y saved, antes de guardar se aplican los mutators y los casts
+func (m *Model) Create(data map[string]any) error {
+	data, err := m.applyTenant(data, true)
+	if err != nil {
+		return err
+	}
+	values, err := m.fillData(data)
+	if err != nil {
+		return err
+	}
+	// los registros con bloqueo optimista empiezan en la version 1
+	if column := m.lockColumn(); column != "" {
+		if _, ok := values[column]; !ok {
+			values[column] = 1
+		}
+	}
+
+	// Usar un map para los drivers soportados
+	createFuncs := map[string]func(map[string]any) error{
+		"mongodb":    m.createMongoDB,
+		"mysql":      m.createMySQL,
+		"postgresql": m.createMySQL,
+		"memory":     m.createMemory,
+	}
+
+	createFunc, ok := createFuncs[m.driver()]
+	if !ok {
+		return m.conn().unsupported()
+	}
+
+	e := m.newEvent(nil, values)
+	if err := m.fire(e, EventSaving, EventCreating); err != nil {
+		return err
+	}
+	// se guardan los valores con los mutators y casts aplicados y en `m.Data` quedan como si se leyeran de la base de datos
+	values, err = m.castAttributes(e.Attributes)
+	if err != nil {
+		return err
+	}
+	if err := createFunc(values); err != nil {
+		return err
+	}
+	m.flushCache()
+	if err := m.castRows([]map[string]any{values}); err != nil {
+		return err
+	}
+	e.Attributes = values
+	m.Data = []map[string]any{values}
+	m.single = true
+	m.syncOriginal()
+	return m.fire(e, EventCreated, EventSaved)
+}
+
+// createMySQL inserta el registro en mysql o postgresql y asigna la clave primaria generada
+func (m *Model) createMySQL(values map[string]any) error {
+	columns := sortedColumns(values)
+	args := make([]any, len(columns))
+	for i, col := range columns {
+		args[i] = values[col]
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s)",
+		m.tableName,
+		strings.Join(columns, ", "),
+		strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", "),
+	)
+
+	ctx, cancel := m.context()
+	defer cancel()
+
+	pk := m.PrimaryKey()
+
+	// postgresql no soporta LastInsertId, se usa RETURNING
+	start := time.Now()
+	if m.driver() == "postgresql" {
+		query = rebind(m.driver(), query+" RETURNING "+pk)
+		rows, err := m.sqlDB().QueryContext(ctx, query, args...)
+		if err != nil {
+			m.record(ctx, start, query, args, 0, err)
+			return dbError("error al crear el registro", err)
+		}
+		defer rows.Close()
+		if rows.Next() {
+			var id any
+			if err := rows.Scan(&id); err != nil {
+				m.record(ctx, start, query, args, 0, err)
+				return dbError("error al leer el id del registro", err)
+			}
+			values[pk] = id
+		}
+		m.record(ctx, start, query, args, 1, rows.Err())
+		return rows.Err()
+	}
+
+	query = rebind(m.driver(), query)
+	result, err := m.sqlDB().ExecContext(ctx, query, args...)
+	m.record(ctx, start, query, args, rowsAffected(result), err)
+	if err != nil {
+		return dbError("error al crear el registro", err)
+	}
+	if _, ok := values[pk]; !ok {
+		if id, err := result.LastInsertId(); err == nil && id > 0 {
+			values[pk] = id
+		}
+	}
+	return nil
+}
+
+// createMongoDB inserta el documento en mongodb y asigna el _id generado
+func (m *Model) createMongoDB(values map[string]any) error {
+	ctx, cancel := m.context()
+	defer cancel()
+
+	collection := m.collection()
+	start := time.Now()
+	result, err := collection.InsertOne(ctx, values)
+	m.record(ctx, start, m.mongoQuery("insertOne"), []any{values}, 1, err)
+	if err != nil {
+		return dbError("error al crear el documento", err)
+	}
+	values["_id"] = result.InsertedID
+	return nil
+}
+
+// sortedColumns retorna las keys ordenadas para que las consultas generadas siempre sean iguales
+func sortedColumns(values map[string]any) []string {
+	columns := make([]string, 0, len(values))
+	for col := range values {
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+	return columns
+}
@@ -0,0 +1,301 @@
+package orm
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrInvalidCursor se retorna si el cursor no se puede decodificar, su firma no es valida o es de otra consulta
+// los controladores lo pueden identificar con errors.Is(err, orm.ErrInvalidCursor) y responder 400
+var ErrInvalidCursor = errors.New("cursor inválido")
+
+// CursorPagination es el resultado de una consulta paginada por cursor
+// a diferencia de Paginate no cuenta los registros ni usa OFFSET, cada pagina continua desde el ultimo registro de la anterior
+type CursorPagination struct {
+	Data        any     `json:"data"`          // registros de la pagina sin las columnas ocultas, []map[string]any o []T en Repository
+	PerPage     int     `json:"per_page"`      // registros por pagina
+	NextCursor  *string `json:"next_cursor"`   // nil si no hay mas registros
+	PrevCursor  *string `json:"prev_cursor"`   // nil en la primera pagina
+	Path        string  `json:"path"`          // url sin parametros, se llena con SetURL
+	NextPageURL *string `json:"next_page_url"` // se llena con SetURL
+	PrevPageURL *string `json:"prev_page_url"` // se llena con SetURL
+}
+
+// cursorPayload es el contenido del cursor antes de firmarlo
+type cursorPayload struct {
+	Table   string        `json:"t"`           // tabla de la consulta
+	Columns []string      `json:"c"`           // columnas del orden, el cursor solo sirve para la misma consulta
+	Values  []cursorValue `json:"v"`           // valores de las columnas en el registro donde se corto la pagina
+	Prev    bool          `json:"p,omitempty"` // true si el cursor va hacia la pagina anterior
+}
+
+// cursorValue guarda el tipo de los valores que json no conserva (fechas, ObjectID)
+type cursorValue struct {
+	Type  string `json:"t,omitempty"`
+	Value any    `json:"v"`
+}
+
+// cursorKey es la llave con la que se firman los cursores
+// se toma de APP_KEY, si no esta definida se genera una aleatoria y los cursores solo sirven mientras el proceso este vivo
+var cursorKey = sync.OnceValue(func() []byte {
+	if key := os.Getenv("APP_KEY"); key != "" {
+		return []byte(key)
+	}
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+})
+
+// CursorPaginate ejecuta la consulta por paginas usando un cursor, los registros de la pagina quedan en `m.Data`
+// cursor es vacio para la primera pagina, las siguientes usan NextCursor o PrevCursor del resultado anterior
+// se ordena por las columnas de OrderBy y la clave primaria para desempatar, las columnas del orden no deben ser nulas
+func (m *Model) CursorPaginate(perPage int, cursor string) (*CursorPagination, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if perPage < 1 {
+		return nil, fmt.Errorf("la cantidad de registros por página debe ser mayor a 0")
+	}
+
+	orders := m.keysetOrders()
+	columns := make([]string, len(orders))
+	for i, o := range orders {
+		columns[i] = o.column
+	}
+
+	payload := &cursorPayload{}
+	if cursor != "" {
+		var err error
+		if payload, err = decodeCursor(cursor); err != nil {
+			return nil, err
+		}
+		if payload.Table != m.tableName || !slices.Equal(payload.Columns, columns) || len(payload.Values) != len(columns) {
+			return nil, fmt.Errorf("%w: no corresponde a esta consulta", ErrInvalidCursor)
+		}
+		values := make([]any, len(payload.Values))
+		for i, v := range payload.Values {
+			values[i] = v.decode()
+		}
+		m.whereKeyset(orders, values, payload.Prev)
+	}
+
+	// las columnas del orden deben venir en los resultados para construir los cursores
+	if len(m.selectedColumns) > 0 {
+		for _, col := range columns {
+			if !slices.Contains(m.selectedColumns, col) {
+				m.selectedColumns = append(m.selectedColumns, col)
+			}
+		}
+	}
+
+	// para ir hacia atras se invierte el orden y luego los resultados
+	m.orders = orders
+	if payload.Prev {
+		m.orders = reverseOrders(orders)
+	}
+	m.Limit(perPage + 1).Offset(0)
+	err := m.Get()
+	m.orders = orders
+	if err != nil {
+		return nil, err
+	}
+
+	hasMore := len(m.Data) > perPage
+	if hasMore {
+		m.Data = m.Data[:perPage]
+	}
+	if payload.Prev {
+		slices.Reverse(m.Data)
+	}
+
+	pagination := &CursorPagination{Data: m.ToMaps(), PerPage: perPage}
+	if len(m.Data) == 0 {
+		return pagination, nil
+	}
+
+	// si se llego con un cursor hay registros del otro lado
+	hasNext, hasPrev := hasMore, cursor != ""
+	if payload.Prev {
+		hasNext, hasPrev = true, hasMore
+	}
+	if hasNext {
+		next, err := encodeCursor(m.tableName, columns, m.Data[len(m.Data)-1], false)
+		if err != nil {
+			return nil, err
+		}
+		pagination.NextCursor = &next
+	}
+	if hasPrev {
+		prev, err := encodeCursor(m.tableName, columns, m.Data[0], true)
+		if err != nil {
+			return nil, err
+		}
+		pagination.PrevCursor = &prev
+	}
+	return pagination, nil
+}
+
+// SetURL construye los links de la paginacion a partir de la url del request
+// se conservan los demas parametros de la url y solo se cambia cursor
+func (p *CursorPagination) SetURL(u *url.URL) *CursorPagination {
+	p.Path = pathURL(u)
+	p.NextPageURL, p.PrevPageURL = nil, nil
+	if p.NextCursor != nil {
+		next := withQuery(u, "cursor", *p.NextCursor)
+		p.NextPageURL = &next
+	}
+	if p.PrevCursor != nil {
+		prev := withQuery(u, "cursor", *p.PrevCursor)
+		p.PrevPageURL = &prev
+	}
+	return p
+}
+
+// keysetOrders retorna el orden de la consulta con la clave primaria al final para que el orden sea unico
+func (m *Model) keysetOrders() []order {
+	orders := append([]order(nil), m.orders...)
+	pk := m.PrimaryKey()
+	for _, o := range orders {
+		if o.column == pk {
+			return orders
+		}
+	}
+	direction := "ASC"
+	if len(orders) > 0 {
+		direction = orders[len(orders)-1].direction
+	}
+	return append(orders, order{column: pk, direction: direction})
+}
+
+// reverseOrders retorna el orden con las direcciones invertidas
+func reverseOrders(orders []order) []order {
+	reversed := make([]order, len(orders))
+	for i, o := range orders {
+		reversed[i] = order{column: o.column, direction: "DESC"}
+		if o.direction == "DESC" {
+			reversed[i].direction = "ASC"
+		}
+	}
+	return reversed
+}
+
+// whereKeyset agrega la condicion para continuar despues (o antes si prev) del registro con values
+// con el orden a, b queda: (a > ?) OR (a = ? AND b > ?)
+func (m *Model) whereKeyset(orders []order, values []any, prev bool) {
+	m.groupWheres()
+	m.WhereGroup(func(q *Model) {
+		for i := range orders {
+			q.OrWhereGroup(func(g *Model) {
+				for j := 0; j < i; j++ {
+					g.Where(orders[j].column, values[j])
+				}
+				operator := ">"
+				if (orders[i].direction == "DESC") != prev {
+					operator = "<"
+				}
+				g.Where(orders[i].column, operator, values[i])
+			})
+		}
+	})
+}
+
+// encodeCursor firma las columnas del orden y sus valores en row y los codifica en base64
+func encodeCursor(table string, columns []string, row map[string]any, prev bool) (string, error) {
+	payload := cursorPayload{Table: table, Columns: columns, Prev: prev}
+	for _, col := range columns {
+		payload.Values = append(payload.Values, newCursorValue(row[col]))
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("error al crear el cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b) + "." + base64.RawURLEncoding.EncodeToString(signCursor(b)), nil
+}
+
+// decodeCursor valida la firma del cursor y retorna su contenido
+func decodeCursor(cursor string) (*cursorPayload, error) {
+	data, signature, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	b, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, signCursor(b)) {
+		return nil, ErrInvalidCursor
+	}
+
+	payload := &cursorPayload{}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err := decoder.Decode(payload); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return payload, nil
+}
+
+// signCursor retorna la firma hmac del contenido del cursor
+func signCursor(b []byte) []byte {
+	mac := hmac.New(sha256.New, cursorKey())
+	mac.Write(b)
+	return mac.Sum(nil)
+}
+
+// newCursorValue convierte el valor de la columna en un valor que se pueda guardar en json sin perder el tipo
+func newCursorValue(value any) cursorValue {
+	switch v := value.(type) {
+	case []byte:
+		return cursorValue{Value: string(v)}
+	case time.Time:
+		return cursorValue{Type: "time", Value: v.Format(time.RFC3339Nano)}
+	case primitive.DateTime:
+		return cursorValue{Type: "date", Value: int64(v)}
+	case primitive.ObjectID:
+		return cursorValue{Type: "oid", Value: v.Hex()}
+	default:
+		return cursorValue{Value: v}
+	}
+}
+
+// decode retorna el valor con su tipo original
+func (c cursorValue) decode() any {
+	if n, ok := c.Value.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			if c.Type == "date" {
+				return primitive.DateTime(i)
+			}
+			return i
+		}
+		f, _ := n.Float64()
+		return f
+	}
+
+	s, _ := c.Value.(string)
+	switch c.Type {
+	case "time":
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return t
+		}
+	case "oid":
+		if id, err := primitive.ObjectIDFromHex(s); err == nil {
+			return id
+		}
+	}
+	return c.Value
+}
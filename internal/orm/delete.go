@@ -0,0 +1,114 @@
+package orm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Delete elimina de la base de datos los registros cargados en `m.Data` usando su clave primaria
+// si la tabla usa SoftDeletes los registros solo se marcan como eliminados
+// por cada registro se disparan los eventos deleting y deleted
+func (m *Model) Delete() error {
+	return m.delete(false)
+}
+
+func (m *Model) delete(force bool) error {
+	if len(m.Data) == 0 {
+		return fmt.Errorf("no hay registros cargados para eliminar")
+	}
+	if column := m.softDeleteColumn(); column != "" && !force {
+		return m.softDelete(column)
+	}
+
+	ids := pluck(m.Data, m.PrimaryKey())
+	if len(ids) == 0 {
+		return fmt.Errorf("los registros no tienen clave primaria '%s'", m.PrimaryKey())
+	}
+
+	// Usar un map para los drivers soportados
+	deleteFuncs := map[string]func([]any) error{
+		"mongodb":    m.deleteMongoDB,
+		"mysql":      m.deleteMySQL,
+		"postgresql": m.deleteMySQL,
+		"memory":     m.deleteMemory,
+	}
+
+	deleteFunc, ok := deleteFuncs[m.driver()]
+	if !ok {
+		return m.conn().unsupported()
+	}
+
+	// los eventos se disparan por cada registro, los registros se eliminan juntos
+	rowEvents := make([]*ModelEvent, len(m.Data))
+	for i, row := range m.Data {
+		rowEvents[i] = m.newEvent(m.originalRow(i), row)
+		if err := m.fire(rowEvents[i], EventDeleting); err != nil {
+			return err
+		}
+	}
+	if err := deleteFunc(ids); err != nil {
+		return err
+	}
+	m.flushCache()
+	for _, e := range rowEvents {
+		if err := m.fire(e, EventDeleted); err != nil {
+			return err
+		}
+	}
+	m.Data, m.original = nil, nil
+	return nil
+}
+
+// deleteMySQL elimina los registros en mysql o postgresql
+func (m *Model) deleteMySQL(ids []any) error {
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE %s IN (%s)",
+		m.tableName,
+		m.PrimaryKey(),
+		strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "),
+	)
+
+	ctx, cancel := m.context()
+	defer cancel()
+
+	args := ids
+	for column, value := range m.tenantMatch(nil) {
+		query += " AND " + column + " = ?"
+		args = append(args[:len(args):len(args)], value)
+	}
+
+	query = rebind(m.driver(), query)
+	start := time.Now()
+	result, err := m.sqlDB().ExecContext(ctx, query, args...)
+	m.record(ctx, start, query, args, rowsAffected(result), err)
+	if err != nil {
+		return dbError("error al eliminar los registros", err)
+	}
+	return nil
+}
+
+// deleteMongoDB elimina los documentos en mongodb
+func (m *Model) deleteMongoDB(ids []any) error {
+	ctx, cancel := m.context()
+	defer cancel()
+
+	collection := m.collection()
+	filter := bson.M{"_id": bson.M{"$in": ids}}
+	for column, value := range m.tenantMatch(nil) {
+		filter[column] = value
+	}
+	start := time.Now()
+	result, err := collection.DeleteMany(ctx, filter)
+	var deleted int64
+	if result != nil {
+		deleted = result.DeletedCount
+	}
+	m.record(ctx, start, m.mongoQuery("deleteMany"), []any{filter}, deleted, err)
+	if err != nil {
+		return dbError("error al eliminar los documentos", err)
+	}
+	return nil
+}
@@ -0,0 +1,385 @@
+package orm
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// With indica las relaciones que se cargaran junto con la consulta (eager loading)
+// las relaciones anidadas se separan con punto: With("roles.permissions")
+// las relaciones se cargan con una consulta por relacion usando WHERE IN en sql
+// y con $lookup en mongodb, asi se evita el problema de N+1 consultas
+func (m *Model) With(names ...string) *Model {
+	m.with = append(m.with, names...)
+	return m
+}
+
+// Load carga las relaciones sobre los registros que ya estan en `m.Data`
+func (m *Model) Load(names ...string) error {
+	if len(m.Data) == 0 || len(names) == 0 {
+		return nil
+	}
+	return m.loadRelations(names)
+}
+
+// eagerLoad carga las relaciones indicadas con With despues de ejecutar la consulta
+func (m *Model) eagerLoad() error {
+	if len(m.Data) == 0 || len(m.with) == 0 {
+		return nil
+	}
+	return m.loadRelations(m.with)
+}
+
+// parseWith separa las relaciones del primer nivel de sus relaciones anidadas
+// ["roles.permissions", "posts"] => ["roles", "posts"], {"roles": ["permissions"], "posts": []}
+func parseWith(names []string) ([]string, map[string][]string) {
+	order := make([]string, 0, len(names))
+	tree := make(map[string][]string, len(names))
+	for _, name := range names {
+		first, rest, _ := strings.Cut(name, ".")
+		if _, ok := tree[first]; !ok {
+			order = append(order, first)
+			tree[first] = make([]string, 0)
+		}
+		if rest != "" {
+			tree[first] = append(tree[first], rest)
+		}
+	}
+	return order, tree
+}
+
+func (m *Model) loadRelations(names []string) error {
+	order, tree := parseWith(names)
+
+	relations := make([]*Relation, 0, len(order))
+	for _, name := range order {
+		r, ok := m.relation(name)
+		if !ok {
+			return fmt.Errorf("la relación '%s' no está definida en '%s'", name, m.tableName)
+		}
+		relations = append(relations, r.resolve(m))
+	}
+
+	// MorphTo se carga igual en todos los drivers agrupando por tipo
+	lookups := make([]*Relation, 0, len(relations))
+	for _, r := range relations {
+		if r.Type != MorphToRelation {
+			lookups = append(lookups, r)
+			continue
+		}
+		if err := m.loadMorphTo(r, tree[r.Name]); err != nil {
+			return err
+		}
+	}
+
+	if m.driver() == "mongodb" {
+		if len(lookups) == 0 {
+			return nil
+		}
+		return m.loadMongoDB(lookups, tree)
+	}
+
+	for _, r := range lookups {
+		if err := m.loadSQL(r, tree[r.Name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadSQL carga una relacion con una consulta WHERE IN por tabla involucrada
+func (m *Model) loadSQL(r *Relation, nested []string) error {
+	if r.isMorph() {
+		return m.loadMorphSQL(r, nested)
+	}
+
+	switch r.Type {
+	case HasOneRelation, HasManyRelation:
+		related := m.newRelated(r.Related).With(nested...)
+		if err := related.WhereIn(r.ForeignKey, pluck(m.Data, r.LocalKey)).Get(); err != nil {
+			return err
+		}
+		groups := groupBy(related.Data, r.ForeignKey)
+		for _, row := range m.Data {
+			matches := groups[keyString(row[r.LocalKey])]
+			if r.Type == HasOneRelation {
+				row[r.Name] = firstOrNil(matches)
+			} else {
+				row[r.Name] = nonNil(matches)
+			}
+		}
+
+	case BelongsToRelation:
+		related := m.newRelated(r.Related).With(nested...)
+		if err := related.WhereIn(r.OwnerKey, pluck(m.Data, r.ForeignKey)).Get(); err != nil {
+			return err
+		}
+		groups := groupBy(related.Data, r.OwnerKey)
+		for _, row := range m.Data {
+			row[r.Name] = firstOrNil(groups[keyString(row[r.ForeignKey])])
+		}
+
+	case BelongsToManyRelation:
+		pivot := m.newRelated(r.Pivot)
+		pivot.Select(append([]string{r.ForeignPivotKey, r.RelatedPivotKey}, r.PivotColumns...)...)
+		if err := pivot.WhereIn(r.ForeignPivotKey, pluck(m.Data, r.LocalKey)).Get(); err != nil {
+			return err
+		}
+		related := m.newRelated(r.Related).With(nested...)
+		if err := related.WhereIn(r.RelatedKey, pluck(pivot.Data, r.RelatedPivotKey)).Get(); err != nil {
+			return err
+		}
+		relatedGroups := groupBy(related.Data, r.RelatedKey)
+		pivotGroups := groupBy(pivot.Data, r.ForeignPivotKey)
+		for _, row := range m.Data {
+			items := make([]map[string]any, 0)
+			for _, p := range pivotGroups[keyString(row[r.LocalKey])] {
+				for _, item := range relatedGroups[keyString(p[r.RelatedPivotKey])] {
+					// se copia por que el mismo registro puede estar en varios padres con distinto pivot
+					item = maps.Clone(item)
+					item["pivot"] = p
+					items = append(items, item)
+				}
+			}
+			row[r.Name] = items
+		}
+
+	case HasManyThroughRelation:
+		through := m.newRelated(r.Through).Select(r.FirstKey, r.SecondLocalKey)
+		if err := through.WhereIn(r.FirstKey, pluck(m.Data, r.LocalKey)).Get(); err != nil {
+			return err
+		}
+		related := m.newRelated(r.Related).With(nested...)
+		if err := related.WhereIn(r.SecondKey, pluck(through.Data, r.SecondLocalKey)).Get(); err != nil {
+			return err
+		}
+		relatedGroups := groupBy(related.Data, r.SecondKey)
+		throughGroups := groupBy(through.Data, r.FirstKey)
+		for _, row := range m.Data {
+			items := make([]map[string]any, 0)
+			for _, t := range throughGroups[keyString(row[r.LocalKey])] {
+				items = append(items, relatedGroups[keyString(t[r.SecondLocalKey])]...)
+			}
+			row[r.Name] = items
+		}
+
+	default:
+		return fmt.Errorf("tipo de relación '%s' no soportado", r.Type)
+	}
+	return nil
+}
+
+// loadMongoDB carga todas las relaciones en una sola agregacion con $lookup sobre los documentos ya consultados
+func (m *Model) loadMongoDB(relations []*Relation, tree map[string][]string) error {
+	pipeline := bson.A{bson.M{"$match": bson.M{"_id": bson.M{"$in": pluck(m.Data, "_id")}}}}
+	projection := bson.M{"_id": 1}
+	for _, r := range relations {
+		stages, err := lookupStages(m, r, tree[r.Name])
+		if err != nil {
+			return err
+		}
+		pipeline = append(pipeline, stages...)
+		projection[r.Name] = 1
+	}
+	pipeline = append(pipeline, bson.M{"$project": projection})
+
+	ctx, cancel := m.context()
+	defer cancel()
+
+	collection := m.collection()
+	start := time.Now()
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		m.record(ctx, start, m.mongoQuery("aggregate"), []any{pipeline}, 0, err)
+		return dbError("error al cargar las relaciones en MongoDB", err)
+	}
+	defer cursor.Close(ctx)
+
+	results := make([]map[string]any, 0)
+	err = cursor.All(ctx, &results)
+	m.record(ctx, start, m.mongoQuery("aggregate"), []any{pipeline}, int64(len(results)), err)
+	if err != nil {
+		return dbError("error al leer las relaciones", err)
+	}
+
+	docs := groupBy(results, "_id")
+	for _, row := range m.Data {
+		doc := firstOrNil(docs[keyString(row["_id"])])
+		for _, r := range relations {
+			if doc == nil {
+				row[r.Name] = nil
+				continue
+			}
+			row[r.Name] = doc[r.Name]
+		}
+	}
+	return nil
+}
+
+// lookupStages construye las etapas $lookup de una relacion incluyendo sus relaciones anidadas
+func lookupStages(m *Model, r *Relation, nested []string) (bson.A, error) {
+	if r.isMorph() {
+		return morphLookupStages(m, r, nested)
+	}
+
+	switch r.Type {
+	case HasOneRelation, HasManyRelation:
+		pipeline, err := nestedLookups(m, r.Related, nested)
+		if err != nil {
+			return nil, err
+		}
+		stages := bson.A{lookup(r.Related, r.LocalKey, r.ForeignKey, r.Name, pipeline)}
+		if r.Type == HasOneRelation {
+			stages = append(stages, firstElement(r.Name))
+		}
+		return stages, nil
+
+	case BelongsToRelation:
+		pipeline, err := nestedLookups(m, r.Related, nested)
+		if err != nil {
+			return nil, err
+		}
+		return bson.A{lookup(r.Related, r.ForeignKey, r.OwnerKey, r.Name, pipeline), firstElement(r.Name)}, nil
+
+	case BelongsToManyRelation:
+		pipeline, err := nestedLookups(m, r.Related, nested)
+		if err != nil {
+			return nil, err
+		}
+		pivot := bson.M{r.ForeignPivotKey: "$" + r.ForeignPivotKey, r.RelatedPivotKey: "$" + r.RelatedPivotKey}
+		for _, col := range r.PivotColumns {
+			pivot[col] = "$" + col
+		}
+		// se busca en la tabla pivote y por cada registro se busca el relacionado al que se le agrega el pivot
+		pivotPipeline := bson.A{
+			lookup(r.Related, r.RelatedPivotKey, r.RelatedKey, "__related", pipeline),
+			bson.M{"$unwind": "$__related"},
+			bson.M{"$replaceRoot": bson.M{"newRoot": bson.M{"$mergeObjects": bson.A{"$__related", bson.M{"pivot": pivot}}}}},
+		}
+		return bson.A{lookup(r.Pivot, r.LocalKey, r.ForeignPivotKey, r.Name, pivotPipeline)}, nil
+
+	case HasManyThroughRelation:
+		pipeline, err := nestedLookups(m, r.Related, nested)
+		if err != nil {
+			return nil, err
+		}
+		throughPipeline := bson.A{
+			lookup(r.Related, r.SecondLocalKey, r.SecondKey, "__related", pipeline),
+			bson.M{"$unwind": "$__related"},
+			bson.M{"$replaceRoot": bson.M{"newRoot": "$__related"}},
+		}
+		return bson.A{lookup(r.Through, r.LocalKey, r.FirstKey, r.Name, throughPipeline)}, nil
+	}
+	return nil, fmt.Errorf("tipo de relación '%s' no soportado", r.Type)
+}
+
+// nestedLookups construye las etapas $lookup de las relaciones anidadas de la tabla
+// parent es el modelo de la consulta, la tabla relacionada usa su contexto para los global scopes
+func nestedLookups(parent *Model, table string, names []string) (bson.A, error) {
+	model := parent.newRelated(table)
+	stages := bson.A{}
+
+	// los global scopes de la tabla relacionada se aplican dentro del $lookup
+	wheres, err := model.scopeWheres()
+	if err != nil {
+		return nil, err
+	}
+	if len(wheres) > 0 {
+		stages = append(stages, bson.M{"$match": mongoConditions(wheres)})
+	}
+
+	order, tree := parseWith(names)
+	for _, name := range order {
+		r, ok := model.relation(name)
+		if !ok {
+			return nil, fmt.Errorf("la relación '%s' no está definida en '%s'", name, table)
+		}
+		s, err := lookupStages(model, r.resolve(model), tree[name])
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, s...)
+	}
+	return stages, nil
+}
+
+func lookup(from string, localField string, foreignField string, as string, pipeline bson.A) bson.M {
+	stage := bson.M{
+		"from":         from,
+		"localField":   localField,
+		"foreignField": foreignField,
+		"as":           as,
+	}
+	if len(pipeline) > 0 {
+		stage["pipeline"] = pipeline
+	}
+	return bson.M{"$lookup": stage}
+}
+
+// firstElement convierte el array resultado del $lookup en un solo documento
+func firstElement(field string) bson.M {
+	return bson.M{"$addFields": bson.M{field: bson.M{"$arrayElemAt": bson.A{"$" + field, 0}}}}
+}
+
+// keyString convierte un valor en un string para poder comparar claves de distintos tipos
+// mysql retorna los valores como []byte y mongodb como ObjectID
+func keyString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case primitive.ObjectID:
+		return v.Hex()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// pluck retorna los valores unicos y no nulos de la columna
+func pluck(rows []map[string]any, column string) []any {
+	seen := make(map[string]bool, len(rows))
+	values := make([]any, 0, len(rows))
+	for _, row := range rows {
+		value, ok := row[column]
+		if !ok || value == nil {
+			continue
+		}
+		key := keyString(value)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		values = append(values, value)
+	}
+	return values
+}
+
+// groupBy agrupa las filas por el valor de la columna
+func groupBy(rows []map[string]any, column string) map[string][]map[string]any {
+	groups := make(map[string][]map[string]any, len(rows))
+	for _, row := range rows {
+		key := keyString(row[column])
+		groups[key] = append(groups[key], row)
+	}
+	return groups
+}
+
+func firstOrNil(rows []map[string]any) map[string]any {
+	if len(rows) == 0 {
+		return nil
+	}
+	return rows[0]
+}
+
+func nonNil(rows []map[string]any) []map[string]any {
+	if rows == nil {
+		return make([]map[string]any, 0)
+	}
+	return rows
+}
@@ -0,0 +1,203 @@
+package orm
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// eventos del ciclo de vida de los modelos
+// los eventos que terminan en -ing se ejecutan antes de la operacion y si un handler retorna un error la operacion se cancela
+const (
+	EventCreating  = "creating"
+	EventCreated   = "created"
+	EventUpdating  = "updating"
+	EventUpdated   = "updated"
+	EventSaving    = "saving"
+	EventSaved     = "saved"
+	EventDeleting  = "deleting"
+	EventDeleted   = "deleted"
+	EventRestoring = "restoring"
+	EventRestored  = "restored"
+)
+
+// events son todos los eventos, se usa para buscar los metodos de los observers
+var events = []string{
+	EventCreating, EventCreated, EventUpdating, EventUpdated, EventSaving,
+	EventSaved, EventDeleting, EventDeleted, EventRestoring, EventRestored,
+}
+
+// ModelEvent es lo que reciben los handlers de los eventos
+type ModelEvent struct {
+	Name       string         // nombre del evento
+	Table      string         // tabla del modelo
+	Model      *Model         // modelo que ejecuta la operacion
+	Attributes map[string]any // valores que se van a guardar, en los eventos -ing se pueden modificar
+	Original   map[string]any // registro antes del cambio, es nil al crear
+	Dirty      map[string]any // columnas que cambian con su nuevo valor
+}
+
+// EventHandler es una funcion que escucha un evento
+type EventHandler func(e *ModelEvent) error
+
+// observers almacena los handlers registrados por tabla y evento
+var observers = struct {
+	sync.RWMutex
+	handlers map[string]map[string][]EventHandler
+}{handlers: make(map[string]map[string][]EventHandler)}
+
+// muteKey es la key del contexto que silencia los eventos
+type muteKey struct{}
+
+// On registra un handler para un evento de la tabla
+//
+//	orm.On("users", orm.EventCreating, func(e *orm.ModelEvent) error {
+//		e.Attributes["uuid"] = uuid.NewString()
+//		return nil
+//	})
+func On(table string, event string, handler EventHandler) {
+	observers.Lock()
+	defer observers.Unlock()
+	if observers.handlers[table] == nil {
+		observers.handlers[table] = make(map[string][]EventHandler)
+	}
+	observers.handlers[table][event] = append(observers.handlers[table][event], handler)
+}
+
+// Observe registra los metodos del observer como handlers de la tabla
+// el observer puede tener cualquiera de los metodos Creating, Created, Updating, Updated, Saving, Saved,
+// Deleting, Deleted, Restoring y Restored con la firma func(e *orm.ModelEvent) error
+func Observe(table string, observer any) {
+	v := reflect.ValueOf(observer)
+	for _, event := range events {
+		method := v.MethodByName(strings.ToUpper(event[:1]) + event[1:])
+		if !method.IsValid() {
+			continue
+		}
+		if handler, ok := method.Interface().(func(*ModelEvent) error); ok {
+			On(table, event, handler)
+		}
+	}
+}
+
+// WithoutEvents ejecuta fn con los eventos silenciados
+// los modelos que usen el contexto que recibe fn (con WithContext o una transaccion creada con el) no disparan eventos
+func WithoutEvents(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(context.WithValue(ctx, muteKey{}, true))
+}
+
+// WithoutEvents silencia los eventos de las operaciones del modelo
+func (m *Model) WithoutEvents() *Model {
+	m.muted = true
+	return m
+}
+
+// eventsMuted indica si los eventos estan silenciados en el modelo o en su contexto
+func (m *Model) eventsMuted() bool {
+	if m.muted {
+		return true
+	}
+	if m.tx != nil && m.tx.ctx.Value(muteKey{}) != nil {
+		return true
+	}
+	return m.ctx != nil && m.ctx.Value(muteKey{}) != nil
+}
+
+// newEvent crea el evento para guardar attributes sobre el registro original
+// Dirty son los valores de attributes que son distintos a los de original
+func (m *Model) newEvent(original map[string]any, attributes map[string]any) *ModelEvent {
+	dirty := make(map[string]any, len(attributes))
+	for column, value := range attributes {
+		if old, ok := original[column]; !ok || !sameValue(old, value) {
+			dirty[column] = value
+		}
+	}
+	return &ModelEvent{Table: m.tableName, Model: m, Attributes: attributes, Original: original, Dirty: dirty}
+}
+
+// fire ejecuta los handlers de los eventos en orden, se detiene en el primer error
+func (m *Model) fire(e *ModelEvent, names ...string) error {
+	if m.eventsMuted() {
+		return nil
+	}
+	for _, name := range names {
+		observers.RLock()
+		handlers := observers.handlers[m.tableName][name]
+		observers.RUnlock()
+
+		e.Name = name
+		for _, handler := range handlers {
+			if err := handler(e); err != nil {
+				return fmt.Errorf("evento '%s' de '%s': %w", name, m.tableName, err)
+			}
+		}
+	}
+	return nil
+}
+
+// syncOriginal guarda una copia de los registros de `m.Data` para saber que cambia despues
+func (m *Model) syncOriginal() {
+	m.original = make([]map[string]any, len(m.Data))
+	for i, row := range m.Data {
+		m.original[i] = maps.Clone(row)
+	}
+}
+
+// originalRow retorna los valores originales del registro i de `m.Data`
+func (m *Model) originalRow(i int) map[string]any {
+	if i < len(m.original) {
+		return maps.Clone(m.original[i])
+	}
+	return maps.Clone(m.Data[i])
+}
+
+// GetOriginal retorna los valores del registro i como se cargaron de la base de datos
+func (m *Model) GetOriginal(i int) map[string]any {
+	if i < 0 || i >= len(m.original) {
+		return nil
+	}
+	return maps.Clone(m.original[i])
+}
+
+// GetDirty retorna las columnas del registro i de `m.Data` que cambiaron desde que se cargo
+func (m *Model) GetDirty(i int) map[string]any {
+	if i < 0 || i >= len(m.Data) {
+		return nil
+	}
+	dirty := m.newEvent(m.originalRow(i), m.Data[i]).Dirty
+	// las relaciones cargadas y las columnas calculadas con accessors no son columnas
+	for column := range dirty {
+		if _, ok := m.relation(column); ok || m.isComputed(column) {
+			delete(dirty, column)
+		}
+	}
+	return dirty
+}
+
+// IsDirty indica si el registro i cambio desde que se cargo, si se pasan columnas solo se revisan esas
+func (m *Model) IsDirty(i int, columns ...string) bool {
+	dirty := m.GetDirty(i)
+	if len(columns) == 0 {
+		return len(dirty) > 0
+	}
+	for _, column := range columns {
+		if _, ok := dirty[column]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sameValue compara dos valores de una columna, []byte y string con el mismo contenido son iguales
+func sameValue(a any, b any) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	if a == nil || b == nil {
+		return false
+	}
+	return keyString(a) == keyString(b)
+}
@@ -0,0 +1,73 @@
+package orm
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestEvents(t *testing.T) {
+	setupMemory(t, noteTable())
+
+	var fired []string
+	errBlocked := errors.New("bloqueado")
+	for _, event := range events {
+		On("notes", event, func(e *ModelEvent) error {
+			fired = append(fired, e.Name)
+			switch {
+			case e.Name == EventCreating && e.Attributes["body"] == "bloqueado":
+				return errBlocked
+			case e.Name == EventCreating:
+				e.Attributes["slug"] = "desde-el-evento"
+			case e.Name == EventUpdated && e.Dirty["body"] != "editado":
+				return errors.New("dirty no tiene el cambio")
+			}
+			return nil
+		})
+	}
+	newNote := func() *Model {
+		m := &Model{}
+		m.Table("note")
+		return m
+	}
+	note := newNote()
+
+	tests := []struct {
+		name    string
+		run     func() error
+		wantErr error
+		want    []string
+	}{
+		{name: "create", run: func() error { return note.Create(map[string]any{"body": "hola"}) }, want: []string{EventSaving, EventCreating, EventCreated, EventSaved}},
+		{name: "update", run: func() error { return note.Update(map[string]any{"body": "editado"}) }, want: []string{EventSaving, EventUpdating, EventUpdated, EventSaved}},
+		{name: "delete", run: note.Delete, want: []string{EventDeleting, EventDeleted}},
+		{name: "cancelado", run: func() error { return newNote().Create(map[string]any{"body": "bloqueado"}) }, wantErr: errBlocked, want: []string{EventSaving, EventCreating}},
+		{name: "silenciados", run: func() error { return newNote().WithoutEvents().Create(map[string]any{"body": "mudo"}) }, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fired = []string{}
+			if err := tt.run(); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, se esperaba %v", err, tt.wantErr)
+			}
+			if !slices.Equal(fired, tt.want) {
+				t.Errorf("eventos = %v, se esperaba %v", fired, tt.want)
+			}
+		})
+	}
+
+	t.Run("los eventos -ing modifican los valores", func(t *testing.T) {
+		if err := newNote().Create(map[string]any{"body": "con slug"}); err != nil {
+			t.Fatal(err)
+		}
+		if n, _ := MemoryCount("notes", map[string]any{"body": "con slug", "slug": "desde-el-evento"}); n != 1 {
+			t.Errorf("no se guardo el valor asignado en creating")
+		}
+		if n, _ := MemoryCount("notes", map[string]any{"body": "mudo", "slug": nil}); n != 1 {
+			t.Errorf("WithoutEvents ejecuto el evento creating")
+		}
+		if n, _ := MemoryCount("notes", map[string]any{"body": "bloqueado"}); n != 0 {
+			t.Errorf("se guardo la nota cancelada en creating")
+		}
+	})
+}
@@ -1,11 +1,8 @@
 package orm
 
 import (
-	"context"
-	"database/sql"
 	"errors"
 	"fmt"
-	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -37,79 +34,98 @@ func (m *Model) Find(id any, columns ...string) error {
 		"mongodb":    m.findMongoDB,
 		"mysql":      m.findMySQL,
 		"postgresql": m.findMySQL,
+		"memory":     m.findMemory,
 	}
 
-	if findFunc, ok := findFuncs[dbDriver]; ok {
-		return findFunc(id)
+	if findFunc, ok := findFuncs[m.driver()]; ok {
+		if err := findFunc(id); err != nil {
+			return err
+		}
+		if err := m.castRows(m.Data); err != nil {
+			return err
+		}
+		// carga las relaciones indicadas con With
+		if err := m.eagerLoad(); err != nil {
+			return err
+		}
+		m.syncOriginal()
+		m.single = true
+		return nil
 	}
 
-	return fmt.Errorf("driver de base de datos '%s' no soportado", dbDriver)
+	return m.conn().unsupported()
 }
 
 // findMySQL hace la busqueda en mysql o postgresql
 func (m *Model) findMySQL(id any) error {
 
-	// Construir la consulta SQL
-	query := fmt.Sprintf(
-		"SELECT %s FROM %s WHERE id = ?",
-		strings.Join(m.selectedColumns, ", "),
-		m.tableName,
-	)
-
-	// Ejecutar la consulta
-	row := db.QueryRow(query, id)
-
-	// Crear un slice de punteros a interfaces para almacenar los valores
-	values := make([]any, len(m.selectedColumns))
-	valuePtrs := make([]any, len(m.selectedColumns))
-	for i := range values {
-		// hacer que los punteros apunten a los valores correspondientes
-		valuePtrs[i] = &values[i]
+	// Construir la consulta SQL, la tabla y las columnas van como identificadores entre comillas
+	columns := []Ident{"*"}
+	if len(m.selectedColumns) > 0 {
+		columns = make([]Ident, len(m.selectedColumns))
+		for i, col := range m.selectedColumns {
+			columns[i] = Ident(col)
+		}
+	}
+	// las condiciones de los global scopes se agregan despues del id
+	scopes := subquery{}
+	wheres, err := m.scopeWheres()
+	if err != nil {
+		return err
+	}
+	if len(wheres) > 0 {
+		scopeSQL, scopeArgs := compileConditions(wheres)
+		scopes = subquery{sql: " AND " + scopeSQL, args: scopeArgs}
 	}
 
-	// Crear slice de punteros con capacidad predefinida segun claude
-	// values := make([]any, 0, len(m.selectedColumns))
-	// valuePtrs := make([]any, 0, len(m.selectedColumns))
+	query, args, err := compileRaw(m.driver(), "SELECT :columns FROM :table WHERE :pk = :id:scopes", []any{map[string]any{
+		"columns": columns,
+		"table":   Ident(m.tableName),
+		"pk":      Ident(m.PrimaryKey()),
+		"id":      id,
+		"scopes":  scopes,
+	}})
+	if err != nil {
+		return err
+	}
 
-	// for range m.selectedColumns {
-	// 	var v any
-	// 	values = append(values, v)
-	// 	valuePtrs = append(valuePtrs, &v)
-	// }
+	data, err := remember(m, query, args, func() ([]map[string]any, error) {
+		ctx, cancel := m.context()
+		defer cancel()
 
-	if err := row.Scan(valuePtrs...); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return fmt.Errorf("registro no encontrado")
-		}
-		return fmt.Errorf("error al escanear resultado: %w", err)
+		start := time.Now()
+		data, err := queryRows(ctx, m.sqlReader(), query, args)
+		m.record(ctx, start, query, args, int64(len(data)), err)
+		return data, err
+	})
+	if err != nil {
+		return err
 	}
-
-	result := make(map[string]any, len(m.selectedColumns))
-	for i, colName := range m.selectedColumns {
-		result[colName] = values[i]
+	if len(data) == 0 {
+		return fmt.Errorf("registro no encontrado")
 	}
 
-	m.Data = []map[string]any{result}
+	m.Data = data[:1]
 	return nil
 }
 
 // findMongoDB hace la busqueda en mongodb
 func (m *Model) findMongoDB(id any) error {
-	// Timeout en el contexto
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
 	// Convertir el ID a ObjectID si es necesario
 	objID, err := m.convertToObjectID(id)
 	if err != nil {
 		return err
 	}
 
-	// Definir la colección basada en el nombre del modelo
-	collection := dbc.Database(databaseName).Collection(m.tableName)
-
-	// Construir el filtro de búsqueda
+	// Construir el filtro de búsqueda con las condiciones de los global scopes
 	filter := bson.M{"_id": objID}
+	wheres, err := m.scopeWheres()
+	if err != nil {
+		return err
+	}
+	if len(wheres) > 0 {
+		filter = bson.M{"$and": bson.A{filter, mongoConditions(wheres)}}
+	}
 
 	// Construir la proyección para devolver solo las columnas especificadas
 	var opts *options.FindOneOptions
@@ -119,15 +135,34 @@ func (m *Model) findMongoDB(id any) error {
 	}
 	opts = options.FindOne().SetProjection(projection)
 
-	var result map[string]any
 	// realizar la consulta
-	if err := collection.FindOne(ctx, filter, opts).Decode(&result); err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			return fmt.Errorf("documento no encontrado")
+	data, err := remember(m, m.mongoQuery("findOne"), []any{filter, projection}, func() ([]map[string]any, error) {
+		ctx, cancel := m.context()
+		defer cancel()
+
+		// Definir la colección basada en el nombre del modelo
+		collection := m.collection()
+
+		var result map[string]any
+		start := time.Now()
+		err := collection.FindOne(ctx, filter, opts).Decode(&result)
+		var found int64
+		if err == nil {
+			found = 1
 		}
-		return fmt.Errorf("error en consulta MongoDB: %w", err)
+		m.record(ctx, start, m.mongoQuery("findOne"), []any{filter}, found, err)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, fmt.Errorf("documento no encontrado")
+			}
+			return nil, dbError("error en consulta MongoDB", err)
+		}
+		return []map[string]any{result}, nil
+	})
+	if err != nil {
+		return err
 	}
 
-	m.Data = []map[string]any{result}
+	m.Data = data
 	return nil
 }
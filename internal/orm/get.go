@@ -0,0 +1,171 @@
+package orm
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Get ejecuta la consulta construida con Where, OrderBy, Limit, etc. y guarda los resultados en `m.Data`
+// si se usaron relaciones con With se cargan despues de la consulta
+// dest es opcional, si se pasa un puntero a un slice de structs los resultados tambien se copian ahi: m.Get(&users)
+func (m *Model) Get(dest ...any) error {
+	if m.err != nil {
+		return m.err
+	}
+	if err := m.applyScopes(); err != nil {
+		return err
+	}
+	m.single = false
+
+	// Usar un map para los drivers soportados
+	getFuncs := map[string]func() error{
+		"mongodb":    m.getMongoDB,
+		"mysql":      m.getMySQL,
+		"postgresql": m.getMySQL,
+		"memory":     m.getMemory,
+	}
+
+	getFunc, ok := getFuncs[m.driver()]
+	if !ok {
+		return m.conn().unsupported()
+	}
+	if err := getFunc(); err != nil {
+		return err
+	}
+	if err := m.castRows(m.Data); err != nil {
+		return err
+	}
+
+	if err := m.eagerLoad(); err != nil {
+		return err
+	}
+	m.syncOriginal()
+
+	if len(dest) > 0 {
+		return m.Hydrate(dest[0])
+	}
+	return nil
+}
+
+// First ejecuta la consulta y guarda solo el primer registro en `m.Data`
+// dest es opcional, si se pasa un puntero a un struct el registro tambien se copia ahi: m.First(&u)
+func (m *Model) First(dest ...any) error {
+	m.Limit(1)
+	if err := m.Get(); err != nil {
+		return err
+	}
+	if len(m.Data) == 0 {
+		return fmt.Errorf("registro no encontrado")
+	}
+	m.single = true
+	if len(dest) > 0 {
+		return m.Hydrate(dest[0])
+	}
+	return nil
+}
+
+// getMySQL ejecuta la consulta en mysql o postgresql
+func (m *Model) getMySQL() error {
+	query, args := m.compileSelect()
+
+	data, err := remember(m, query, args, func() ([]map[string]any, error) {
+		ctx, cancel := m.context()
+		defer cancel()
+
+		start := time.Now()
+		rows, err := m.sqlReader().QueryContext(ctx, query, args...)
+		if err != nil {
+			m.record(ctx, start, query, args, 0, err)
+			return nil, dbError("error en la consulta", err)
+		}
+		defer rows.Close()
+
+		data, err := scanRows(rows)
+		m.record(ctx, start, query, args, int64(len(data)), err)
+		return data, err
+	})
+	if err != nil {
+		return err
+	}
+	m.Data = data
+	return nil
+}
+
+// scanRows lee todas las filas del resultado y las convierte en maps
+func scanRows(rows *sql.Rows) ([]map[string]any, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, dbError("error al leer las columnas", err)
+	}
+
+	data := make([]map[string]any, 0)
+	for rows.Next() {
+		row, err := scanRow(rows, columns)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, dbError("error al recorrer los resultados", err)
+	}
+	return data, nil
+}
+
+// scanRow lee la fila actual y la convierte en un map
+func scanRow(rows *sql.Rows, columns []string) (map[string]any, error) {
+	// Crear un slice de punteros a interfaces para almacenar los valores
+	values := make([]any, len(columns))
+	valuePtrs := make([]any, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+
+	if err := rows.Scan(valuePtrs...); err != nil {
+		return nil, dbError("error al escanear resultado", err)
+	}
+
+	row := make(map[string]any, len(columns))
+	for i, colName := range columns {
+		row[colName] = values[i]
+	}
+	return row, nil
+}
+
+// getMongoDB ejecuta la consulta en mongodb
+func (m *Model) getMongoDB() error {
+	if m.usesPipeline() {
+		return m.getMongoAggregate()
+	}
+
+	filter, opts := m.mongoFilter(), m.mongoFindOptions()
+	data, err := remember(m, m.mongoQuery("find"), []any{filter, opts}, func() ([]map[string]any, error) {
+		ctx, cancel := m.context()
+		defer cancel()
+
+		collection := m.collection()
+
+		start := time.Now()
+		cursor, err := collection.Find(ctx, filter, opts)
+		if err != nil {
+			m.record(ctx, start, m.mongoQuery("find"), []any{filter}, 0, err)
+			return nil, dbError("error en consulta MongoDB", err)
+		}
+		defer cursor.Close(ctx)
+
+		data := make([]map[string]any, 0)
+		err = cursor.All(ctx, &data)
+		m.record(ctx, start, m.mongoQuery("find"), []any{filter}, int64(len(data)), err)
+		if err != nil {
+			return nil, dbError("error al leer los documentos", err)
+		}
+		return data, nil
+	})
+	if err != nil {
+		return err
+	}
+	m.Data = data
+	return nil
+}
@@ -0,0 +1,475 @@
+package orm
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/donbarrigon/new-project/lib/formatter"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fieldsCache guarda por cada tipo de struct el mapa columna => indice del campo
+// asi el reflect del struct se hace una sola vez y no por cada registro
+var fieldsCache sync.Map // map[reflect.Type]map[string][]int
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+	modelType   = reflect.TypeOf(Model{})
+)
+
+// formatos de fecha que se intentan cuando la fecha viene como texto
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"15:04:05",
+}
+
+// Hydrate copia los registros de `m.Data` en dest
+// dest puede ser un puntero a un struct, a un slice de structs o de punteros a structs,
+// a un map[string]any o a un []map[string]any
+// las columnas se asignan a los campos segun el tag db, luego el tag json y por ultimo el nombre del campo en snake_case
+//
+//	var u User
+//	m.Where("email", email).First(&u)
+//	var users []User
+//	m.Get(&users)
+func (m *Model) Hydrate(dest any) error {
+	return hydrate(m.Data, dest)
+}
+
+// hydrate copia las filas en dest
+func hydrate(rows []map[string]any, dest any) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("se espera un puntero para guardar los resultados, se recibió %T", dest)
+	}
+	rv = rv.Elem()
+
+	switch {
+	case rv.Kind() == reflect.Slice:
+		slice := reflect.MakeSlice(rv.Type(), len(rows), len(rows))
+		for i, row := range rows {
+			if err := assignValue(slice.Index(i), row); err != nil {
+				return err
+			}
+		}
+		rv.Set(slice)
+		return nil
+
+	default:
+		if len(rows) == 0 {
+			return fmt.Errorf("registro no encontrado")
+		}
+		return assignValue(rv, rows[0])
+	}
+}
+
+// typeFields retorna el mapa columna => indice de los campos del struct
+// los structs embebidos se recorren como si sus campos fueran del struct padre
+func typeFields(t reflect.Type) map[string][]int {
+	if cached, ok := fieldsCache.Load(t); ok {
+		return cached.(map[string][]int)
+	}
+
+	fields := make(map[string][]int)
+	collectFields(t, nil, fields)
+
+	actual, _ := fieldsCache.LoadOrStore(t, fields)
+	return actual.(map[string][]int)
+}
+
+func collectFields(t reflect.Type, index []int, fields map[string][]int) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldIndex := append(append([]int{}, index...), i)
+
+		// el modelo base no se hidrata
+		if field.Type == modelType {
+			continue
+		}
+
+		// los structs embebidos aportan sus campos
+		if field.Anonymous {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && ft != timeType && !reflect.PointerTo(ft).Implements(scannerType) {
+				collectFields(ft, fieldIndex, fields)
+				continue
+			}
+		}
+
+		// Verificar si el campo es público
+		if field.PkgPath != "" {
+			continue
+		}
+
+		column := columnName(field)
+		if column == "" {
+			continue
+		}
+		// si hay nombres repetidos gana el del struct menos anidado
+		if existing, ok := fields[column]; ok && len(existing) <= len(fieldIndex) {
+			continue
+		}
+		fields[column] = fieldIndex
+	}
+}
+
+// columnName obtiene el nombre de la columna del campo, "" si el campo se debe ignorar
+// el tag db solo se usa si es un nombre de columna, hay modelos que lo usan para definir el tipo sql
+func columnName(field reflect.StructField) string {
+	if tag, ok := field.Tag.Lookup("db"); ok {
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			return ""
+		}
+		if isIdentifier(name) {
+			return name
+		}
+	}
+	if tag, ok := field.Tag.Lookup("json"); ok {
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return formatter.ToSnakeCase(field.Name)
+}
+
+// fieldByIndex retorna el campo creando los punteros a structs embebidos que esten en nil
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// hydrateStruct asigna los valores de la fila a los campos del struct
+func hydrateStruct(row map[string]any, v reflect.Value) error {
+	fields := typeFields(v.Type())
+	for column, value := range row {
+		index, ok := fields[column]
+		if !ok {
+			continue
+		}
+		if err := assignValue(fieldByIndex(v, index), value); err != nil {
+			return fmt.Errorf("error al asignar la columna '%s': %w", column, err)
+		}
+	}
+	return nil
+}
+
+// assignValue convierte el valor que retorna el driver al tipo del destino
+func assignValue(dst reflect.Value, value any) error {
+	// sql.Null*, y cualquier tipo que implemente sql.Scanner se encarga de su conversion
+	if dst.CanAddr() && dst.Addr().Type().Implements(scannerType) {
+		return dst.Addr().Interface().(sql.Scanner).Scan(scannerValue(value))
+	}
+
+	if value == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Ptr:
+		ptr := reflect.New(dst.Type().Elem())
+		if err := assignValue(ptr.Elem(), value); err != nil {
+			return err
+		}
+		dst.Set(ptr)
+		return nil
+
+	case reflect.Interface:
+		dst.Set(reflect.ValueOf(value))
+		return nil
+
+	case reflect.String:
+		s, err := toString(value)
+		if err != nil {
+			return err
+		}
+		dst.SetString(s)
+		return nil
+
+	case reflect.Bool:
+		b, err := toBool(value)
+		if err != nil {
+			return err
+		}
+		dst.SetBool(b)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := toInt64(value)
+		if err != nil {
+			return err
+		}
+		dst.SetInt(n)
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := toInt64(value)
+		if err != nil {
+			return err
+		}
+		dst.SetUint(uint64(n))
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		f, err := toFloat64(value)
+		if err != nil {
+			return err
+		}
+		dst.SetFloat(f)
+		return nil
+
+	case reflect.Struct:
+		if dst.Type() == timeType {
+			t, err := toTime(value)
+			if err != nil {
+				return err
+			}
+			dst.Set(reflect.ValueOf(t))
+			return nil
+		}
+		if row, ok := asRow(value); ok {
+			return hydrateStruct(row, dst)
+		}
+		return assignJSON(dst, value)
+
+	case reflect.Map:
+		if rv := reflect.ValueOf(value); rv.Type().AssignableTo(dst.Type()) {
+			dst.Set(rv)
+			return nil
+		}
+		return assignJSON(dst, value)
+
+	case reflect.Slice:
+		// []byte se copia tal cual
+		if dst.Type().Elem().Kind() == reflect.Uint8 {
+			switch v := value.(type) {
+			case []byte:
+				dst.SetBytes(append([]byte{}, v...))
+				return nil
+			case string:
+				dst.SetBytes([]byte(v))
+				return nil
+			case primitive.Binary:
+				dst.SetBytes(append([]byte{}, v.Data...))
+				return nil
+			}
+		}
+		// los resultados de las relaciones y los arrays de mongodb se asignan elemento por elemento
+		if items, ok := asList(value); ok {
+			slice := reflect.MakeSlice(dst.Type(), len(items), len(items))
+			for i, item := range items {
+				if err := assignValue(slice.Index(i), item); err != nil {
+					return err
+				}
+			}
+			dst.Set(slice)
+			return nil
+		}
+		return assignJSON(dst, value)
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Type().ConvertibleTo(dst.Type()) {
+		dst.Set(rv.Convert(dst.Type()))
+		return nil
+	}
+	return fmt.Errorf("no se puede convertir %T a %s", value, dst.Type())
+}
+
+// scannerValue convierte los tipos de mongodb a tipos que entiende sql.Scanner
+func scannerValue(value any) any {
+	switch v := value.(type) {
+	case primitive.ObjectID:
+		return v.Hex()
+	case primitive.DateTime:
+		return v.Time()
+	case primitive.Decimal128:
+		return v.String()
+	case int32:
+		return int64(v)
+	case int:
+		return int64(v)
+	}
+	return value
+}
+
+// asRow convierte los documentos en un map[string]any
+func asRow(value any) (map[string]any, bool) {
+	switch v := value.(type) {
+	case map[string]any:
+		return v, true
+	case primitive.M:
+		return v, true
+	case primitive.D:
+		return v.Map(), true
+	}
+	return nil, false
+}
+
+// asList convierte los resultados de relaciones y arrays en un []any
+func asList(value any) ([]any, bool) {
+	switch v := value.(type) {
+	case []map[string]any:
+		items := make([]any, len(v))
+		for i, row := range v {
+			items[i] = row
+		}
+		return items, true
+	case []any:
+		return v, true
+	case primitive.A:
+		return v, true
+	}
+	return nil, false
+}
+
+// assignJSON asigna columnas json (texto) o documentos a maps, structs y slices
+func assignJSON(dst reflect.Value, value any) error {
+	var raw []byte
+	switch v := value.(type) {
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("no se puede convertir %T a %s: %w", value, dst.Type(), err)
+		}
+		raw = b
+	}
+	ptr := reflect.New(dst.Type())
+	if err := json.Unmarshal(raw, ptr.Interface()); err != nil {
+		return fmt.Errorf("error al decodificar json en %s: %w", dst.Type(), err)
+	}
+	dst.Set(ptr.Elem())
+	return nil
+}
+
+func toString(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case primitive.ObjectID:
+		return v.Hex(), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case primitive.DateTime:
+		return v.Time().Format(time.RFC3339Nano), nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+func toBool(value any) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case []byte:
+		return strconv.ParseBool(string(v))
+	case string:
+		return strconv.ParseBool(v)
+	}
+	n, err := toInt64(value)
+	if err != nil {
+		return false, fmt.Errorf("no se puede convertir %T a bool", value)
+	}
+	return n != 0, nil
+}
+
+func toInt64(value any) (int64, error) {
+	switch v := value.(type) {
+	case []byte:
+		return strconv.ParseInt(strings.TrimSpace(string(v)), 10, 64)
+	case string:
+		return strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return int64(rv.Float()), nil
+	}
+	return 0, fmt.Errorf("no se puede convertir %T a entero", value)
+}
+
+func toFloat64(value any) (float64, error) {
+	switch v := value.(type) {
+	case []byte:
+		return strconv.ParseFloat(strings.TrimSpace(string(v)), 64)
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	case primitive.Decimal128:
+		return strconv.ParseFloat(v.String(), 64)
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	}
+	return 0, fmt.Errorf("no se puede convertir %T a decimal", value)
+}
+
+func toTime(value any) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case primitive.DateTime:
+		return v.Time(), nil
+	case primitive.Timestamp:
+		return time.Unix(int64(v.T), 0), nil
+	case []byte:
+		return parseTime(string(v))
+	case string:
+		return parseTime(v)
+	}
+	return time.Time{}, fmt.Errorf("no se puede convertir %T a fecha", value)
+}
+
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("formato de fecha no reconocido: %s", s)
+}
@@ -0,0 +1,112 @@
+package orm
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type hydrateTimestamps struct {
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at"`
+}
+
+type hydrateBook struct {
+	ID    int64  `db:"id"`
+	Title string `json:"title"`
+}
+
+type hydrateWriter struct {
+	hydrateTimestamps
+	ID       uint64            `db:"id"`
+	Name     string            `db:"VARCHAR(255)" json:"name"` // el tag db con el tipo sql no es un nombre de columna
+	Nickname sql.NullString    `json:"nickname"`
+	Active   bool              `json:"active"`
+	Rating   float64           `json:"rating"`
+	Settings map[string]any    `json:"settings"`
+	Books    []hydrateBook     `json:"books"`
+	Profile  *hydrateBook      `json:"profile"`
+	Password string            `json:"-"`
+	Extra    map[string]string `json:"extra"`
+	LastSeen time.Time
+}
+
+func TestHydrate(t *testing.T) {
+	created := time.Date(2026, 10, 19, 8, 30, 0, 0, time.Local)
+	row := map[string]any{
+		"id":         []byte("7"), // mysql retorna los enteros como []byte sin parseTime
+		"name":       "ana",
+		"nickname":   nil,
+		"active":     int64(1),
+		"rating":     "4.5",
+		"settings":   `{"theme":"dark"}`,
+		"books":      []map[string]any{{"id": 1, "title": "uno"}, {"id": 2, "title": "dos"}},
+		"profile":    map[string]any{"id": 3, "title": "perfil"},
+		"password":   "secret",
+		"extra":      map[string]any{"a": "b"},
+		"created_at": "2026-10-19 08:30:00",
+		"deleted_at": nil,
+		"last_seen":  created,
+	}
+	want := hydrateWriter{
+		hydrateTimestamps: hydrateTimestamps{CreatedAt: created},
+		ID:                7,
+		Name:              "ana",
+		Active:            true,
+		Rating:            4.5,
+		Settings:          map[string]any{"theme": "dark"},
+		Books:             []hydrateBook{{ID: 1, Title: "uno"}, {ID: 2, Title: "dos"}},
+		Profile:           &hydrateBook{ID: 3, Title: "perfil"},
+		Extra:             map[string]string{"a": "b"},
+		LastSeen:          created,
+	}
+
+	t.Run("struct", func(t *testing.T) {
+		var got hydrateWriter
+		if err := hydrate([]map[string]any{row}, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("struct =\n%+v\nse esperaba\n%+v", got, want)
+		}
+	})
+
+	t.Run("slices", func(t *testing.T) {
+		rows := []map[string]any{{"id": 1, "title": "uno"}, {"id": 2, "title": "dos"}}
+		var values []hydrateBook
+		var pointers []*hydrateBook
+		var maps []map[string]any
+		for _, dest := range []any{&values, &pointers, &maps} {
+			if err := hydrate(rows, dest); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(values) != 2 || values[1].Title != "dos" {
+			t.Errorf("[]T = %+v", values)
+		}
+		if len(pointers) != 2 || pointers[0].ID != 1 {
+			t.Errorf("[]*T = %+v", pointers)
+		}
+		if len(maps) != 2 || maps[0]["title"] != "uno" {
+			t.Errorf("[]map = %+v", maps)
+		}
+	})
+
+	failures := []struct {
+		name string
+		rows []map[string]any
+		dest any
+	}{
+		{name: "sin puntero", rows: []map[string]any{{"id": 1}}, dest: hydrateBook{}},
+		{name: "sin registros", dest: &hydrateBook{}},
+		{name: "tipo incompatible", rows: []map[string]any{{"id": "uno"}}, dest: &hydrateBook{}},
+	}
+	for _, tt := range failures {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := hydrate(tt.rows, tt.dest); err == nil {
+				t.Error("no retorno error")
+			}
+		})
+	}
+}
@@ -0,0 +1,407 @@
+package orm
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// limites de los lotes de InsertMany, Upsert e InsertOrIgnore
+const (
+	maxBindParams     = 65535            // maximo de parametros por consulta en mysql y postgresql
+	defaultPacketSize = 4 << 20          // max_allowed_packet por defecto si no se puede consultar
+	maxBatchSize      = 64 << 20         // tamaño maximo de un lote en postgresql
+	valueOverhead     = len("?, ") + 8   // bytes aproximados que ocupa cada valor ademas de su contenido
+	rowOverhead       = len("(), ") + 16 // bytes aproximados que ocupa cada registro ademas de sus valores
+)
+
+// bulkInsert es el tipo de insercion de los registros
+type bulkInsert struct {
+	kind          string   // insert, ignore o upsert
+	uniqueBy      []string // columnas unicas del upsert
+	updateColumns []string // columnas que se actualizan si el registro ya existe
+}
+
+// InsertMany inserta los registros en lotes, retorna la cantidad de registros insertados
+// los lotes se arman para no superar el limite de parametros ni max_allowed_packet de mysql
+// solo se guardan los datos que permitan fillable y guarded y se aplican los mutators y los casts
+// no se cargan los registros en `m.Data` ni se retornan sus claves primarias
+// se disparan los eventos saving, creating, created y saved por registro, para mayor velocidad use WithoutEvents
+// los lotes no son atomicos, para que la insercion sea todo o nada use una transaccion
+func (m *Model) InsertMany(rows []map[string]any) (int64, error) {
+	return m.insertBulk(rows, bulkInsert{kind: "insert"})
+}
+
+// InsertOrIgnore inserta los registros como InsertMany y omite los que ya existen por una clave unica
+// retorna la cantidad de registros insertados
+func (m *Model) InsertOrIgnore(rows []map[string]any) (int64, error) {
+	return m.insertBulk(rows, bulkInsert{kind: "ignore"})
+}
+
+// Upsert inserta los registros y si ya existe uno con los mismos valores en uniqueBy actualiza updateColumns
+// si updateColumns esta vacio se actualizan todas las columnas menos las de uniqueBy y created_at
+// con bloqueo optimista la version de los registros que ya existen se incrementa en lugar de reemplazarse
+// en mysql uniqueBy debe tener un indice unico, la coincidencia la define la base de datos con sus indices unicos
+// se disparan los eventos saving y saved por registro, retorna las filas afectadas segun el driver
+//
+//	users.Upsert(rows, []string{"email"}, []string{"name", "updated_at"})
+func (m *Model) Upsert(rows []map[string]any, uniqueBy []string, updateColumns []string) (int64, error) {
+	if len(uniqueBy) == 0 {
+		return 0, fmt.Errorf("upsert necesita al menos una columna única")
+	}
+	for _, column := range slices.Concat(uniqueBy, updateColumns) {
+		if err := m.columnError(column); err != nil {
+			return 0, err
+		}
+	}
+	return m.insertBulk(rows, bulkInsert{kind: "upsert", uniqueBy: uniqueBy, updateColumns: updateColumns})
+}
+
+// insertBulk prepara los registros, dispara los eventos y los inserta con el driver
+func (m *Model) insertBulk(rows []map[string]any, bulk bulkInsert) (int64, error) {
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	// Usar un map para los drivers soportados
+	insertFuncs := map[string]func([]map[string]any, bulkInsert) (int64, error){
+		"mongodb":    m.insertManyMongoDB,
+		"mysql":      m.insertManyMySQL,
+		"postgresql": m.insertManyMySQL,
+		"memory":     m.insertManyMemory,
+	}
+
+	insertFunc, ok := insertFuncs[m.driver()]
+	if !ok {
+		return 0, m.conn().unsupported()
+	}
+
+	before, after := []string{EventSaving, EventCreating}, []string{EventCreated, EventSaved}
+	if bulk.kind == "upsert" {
+		before, after = []string{EventSaving}, []string{EventSaved}
+	}
+	fireEvents := !m.eventsMuted() && m.hasHandlers(slices.Concat(before, after)...)
+
+	values := make([]map[string]any, len(rows))
+	var events []*ModelEvent
+	if fireEvents {
+		events = make([]*ModelEvent, len(rows))
+	}
+	lock := m.lockColumn()
+	for i, row := range rows {
+		v, err := m.applyTenant(row, true)
+		if err == nil {
+			v, err = m.fillData(v)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("registro %d: %w", i, err)
+		}
+		if lock != "" {
+			if _, ok := v[lock]; !ok {
+				v[lock] = 1
+			}
+		}
+		if fireEvents {
+			events[i] = m.newEvent(nil, v)
+			if err := m.fire(events[i], before...); err != nil {
+				return 0, err
+			}
+			v = events[i].Attributes
+		}
+		if values[i], err = m.castAttributes(v); err != nil {
+			return 0, fmt.Errorf("registro %d: %w", i, err)
+		}
+	}
+
+	n, err := insertFunc(values, bulk)
+	// los lotes anteriores a un error ya se guardaron
+	m.flushCache()
+	if err != nil {
+		return n, err
+	}
+	for _, e := range events {
+		if err := m.fire(e, after...); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// hasHandlers indica si hay handlers registrados para alguno de los eventos de la tabla
+func (m *Model) hasHandlers(names ...string) bool {
+	observers.RLock()
+	defer observers.RUnlock()
+	for _, name := range names {
+		if len(observers.handlers[m.tableName][name]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// insertManyMySQL inserta los registros en lotes en mysql o postgresql
+// los registros se agrupan por sus columnas para que las que no vienen tomen el valor por defecto de la tabla
+func (m *Model) insertManyMySQL(rows []map[string]any, bulk bulkInsert) (int64, error) {
+	groups := make(map[string][]map[string]any)
+	var keys []string
+	for _, row := range rows {
+		key := strings.Join(sortedColumns(row), ",")
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], row)
+	}
+
+	limit := m.packetLimit()
+	var total int64
+	for _, key := range keys {
+		columns := strings.Split(key, ",")
+		for _, batch := range insertBatches(groups[key], columns, limit) {
+			query, args := m.compileInsert(columns, batch, bulk)
+			// cada lote tiene su propio tiempo limite
+			query = rebind(m.driver(), query)
+			ctx, cancel := m.context()
+			start := time.Now()
+			result, err := m.sqlDB().ExecContext(ctx, query, args...)
+			m.record(ctx, start, query, args, rowsAffected(result), err)
+			cancel()
+			if err != nil {
+				return total, dbError("error al insertar los registros", err)
+			}
+			total += rowsAffected(result)
+		}
+	}
+	return total, nil
+}
+
+// compileInsert construye el INSERT de un lote con la clausula de conflicto segun el driver
+func (m *Model) compileInsert(columns []string, rows []map[string]any, bulk bulkInsert) (string, []any) {
+	var sb strings.Builder
+	args := make([]any, 0, len(rows)*len(columns))
+
+	sb.WriteString("INSERT ")
+	if bulk.kind == "ignore" && m.driver() == "mysql" {
+		sb.WriteString("IGNORE ")
+	}
+	placeholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+	fmt.Fprintf(&sb, "INTO %s (%s) VALUES ", m.tableName, strings.Join(columns, ", "))
+	for i, row := range rows {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(placeholders)
+		for _, col := range columns {
+			args = append(args, row[col])
+		}
+	}
+
+	switch {
+	case bulk.kind == "ignore" && m.driver() == "postgresql":
+		sb.WriteString(" ON CONFLICT DO NOTHING")
+
+	case bulk.kind == "upsert":
+		updates := m.upsertColumns(columns, bulk)
+		sets := make([]string, len(updates))
+		for i, col := range updates {
+			if m.driver() == "postgresql" {
+				sets[i] = col + " = EXCLUDED." + col
+			} else {
+				sets[i] = col + " = VALUES(" + col + ")"
+			}
+		}
+		if lock := m.lockColumn(); lock != "" && len(sets) > 0 {
+			if m.driver() == "postgresql" {
+				sets = append(sets, lock+" = "+m.tableName+"."+lock+" + 1")
+			} else {
+				sets = append(sets, lock+" = "+lock+" + 1")
+			}
+		}
+		if m.driver() == "postgresql" {
+			fmt.Fprintf(&sb, " ON CONFLICT (%s)", strings.Join(bulk.uniqueBy, ", "))
+			if len(sets) == 0 {
+				sb.WriteString(" DO NOTHING")
+			} else {
+				sb.WriteString(" DO UPDATE SET " + strings.Join(sets, ", "))
+			}
+		} else {
+			if len(sets) == 0 {
+				// sin columnas para actualizar se reasigna la columna unica para que no falle
+				sets = []string{bulk.uniqueBy[0] + " = " + bulk.uniqueBy[0]}
+			}
+			sb.WriteString(" ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", "))
+		}
+	}
+	return sb.String(), args
+}
+
+// upsertColumns retorna las columnas que se actualizan si el registro ya existe
+// sin updateColumns son todas las de columns menos las de uniqueBy y created_at
+// la columna de la version nunca se toma del registro nuevo porque volveria a 1, se incrementa aparte
+func (m *Model) upsertColumns(columns []string, bulk bulkInsert) []string {
+	lock := m.lockColumn()
+	updates := bulk.updateColumns
+	if len(updates) == 0 {
+		updates = slices.DeleteFunc(slices.Clone(columns), func(c string) bool {
+			return slices.Contains(bulk.uniqueBy, c) || c == "created_at"
+		})
+	}
+	return slices.DeleteFunc(slices.Clone(updates), func(c string) bool { return lock != "" && c == lock })
+}
+
+// insertBatches divide los registros en lotes que no superen el limite de parametros ni limit bytes
+func insertBatches(rows []map[string]any, columns []string, limit int) [][]map[string]any {
+	perBatch := max(maxBindParams/len(columns), 1)
+	var batches [][]map[string]any
+	start, size := 0, 0
+	for i, row := range rows {
+		rowSize := rowOverhead
+		for _, col := range columns {
+			rowSize += valueSize(row[col]) + valueOverhead
+		}
+		if i > start && (i-start >= perBatch || size+rowSize > limit) {
+			batches = append(batches, rows[start:i])
+			start, size = i, 0
+		}
+		size += rowSize
+	}
+	return append(batches, rows[start:])
+}
+
+// valueSize retorna los bytes aproximados que ocupa el valor en la consulta
+func valueSize(value any) int {
+	switch v := value.(type) {
+	case string:
+		return len(v)
+	case []byte:
+		return len(v)
+	case nil:
+		return 4
+	}
+	return 24
+}
+
+// packetLimit retorna los bytes maximos de una consulta, en mysql es max_allowed_packet con un margen
+// max_allowed_packet se consulta una sola vez por conexion porque cada servidor puede tener uno distinto
+func (m *Model) packetLimit() int {
+	conn := m.conn()
+	if conn.driver != "mysql" {
+		return maxBatchSize
+	}
+	conn.packetOnce.Do(func() {
+		conn.packetSize = defaultPacketSize
+		if conn.sql == nil {
+			return
+		}
+		ctx, cancel := m.context()
+		defer cancel()
+		if rows, err := conn.sql.QueryContext(ctx, "SELECT @@max_allowed_packet"); err == nil {
+			if rows.Next() {
+				var size int
+				if rows.Scan(&size) == nil && size > 0 {
+					conn.packetSize = size
+				}
+			}
+			rows.Close()
+		}
+	})
+	// margen para el texto de la consulta y los calculos aproximados
+	return conn.packetSize / 4 * 3
+}
+
+// insertManyMongoDB inserta los documentos en mongodb, el driver divide los lotes segun los limites del servidor
+func (m *Model) insertManyMongoDB(rows []map[string]any, bulk bulkInsert) (int64, error) {
+	ctx, cancel := m.context()
+	defer cancel()
+
+	collection := m.collection()
+
+	if bulk.kind == "upsert" {
+		lock := m.lockColumn()
+		models := make([]mongo.WriteModel, len(rows))
+		for i, row := range rows {
+			updates := m.upsertColumns(sortedColumns(row), bulk)
+			filter := bson.M{}
+			set, setOnInsert := bson.M{}, bson.M{}
+			for col, value := range row {
+				switch {
+				case slices.Contains(bulk.uniqueBy, col):
+					filter[col] = value
+				case slices.Contains(updates, col):
+					set[col] = value
+				case lock != "" && col == lock:
+					// la version se asigna abajo segun si se actualiza el documento
+				default:
+					setOnInsert[col] = value
+				}
+			}
+			update := bson.M{}
+			if len(set) > 0 {
+				update["$set"] = set
+				if lock != "" {
+					// $inc sobre un documento nuevo asigna 1
+					update["$inc"] = bson.M{lock: 1}
+				}
+			} else if lock != "" {
+				setOnInsert[lock] = row[lock]
+			}
+			if len(setOnInsert) > 0 {
+				update["$setOnInsert"] = setOnInsert
+			}
+			if len(update) == 0 {
+				update["$setOnInsert"] = filter
+			}
+			models[i] = mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update).SetUpsert(true)
+		}
+		start := time.Now()
+		result, err := collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		var saved int64
+		if result != nil {
+			saved = result.UpsertedCount + result.ModifiedCount
+		}
+		m.record(ctx, start, m.mongoQuery("bulkWrite"), []any{rows}, saved, err)
+		if err != nil {
+			return 0, dbError("error al guardar los documentos", err)
+		}
+		return saved, nil
+	}
+
+	docs := make([]any, len(rows))
+	for i, row := range rows {
+		docs[i] = row
+	}
+	// con ignore el orden no importa para que se inserten todos los que no esten duplicados
+	start := time.Now()
+	result, err := collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(bulk.kind != "ignore"))
+	inserted := int64(0)
+	if result != nil {
+		inserted = int64(len(result.InsertedIDs))
+	}
+	m.record(ctx, start, m.mongoQuery("insertMany"), []any{docs}, inserted, err)
+	if err != nil {
+		var bulkErr mongo.BulkWriteException
+		if bulk.kind == "ignore" && errors.As(err, &bulkErr) && onlyDuplicates(bulkErr) {
+			return int64(len(rows) - len(bulkErr.WriteErrors)), nil
+		}
+		return inserted, dbError("error al insertar los documentos", err)
+	}
+	return inserted, nil
+}
+
+// onlyDuplicates indica si todos los errores de escritura son por claves duplicadas
+func onlyDuplicates(err mongo.BulkWriteException) bool {
+	if err.WriteConcernError != nil {
+		return false
+	}
+	for _, e := range err.WriteErrors {
+		if !mongo.IsDuplicateKeyError(e) {
+			return false
+		}
+	}
+	return true
+}
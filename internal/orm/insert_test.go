@@ -0,0 +1,164 @@
+package orm
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeConnection registra una conexion sin base de datos con el driver para compilar las consultas
+func fakeConnection(t *testing.T, name string, driver string) {
+	t.Helper()
+	connections.Lock()
+	connections.m[name] = &connection{name: name, driver: driver}
+	connections.Unlock()
+	t.Cleanup(func() {
+		connections.Lock()
+		delete(connections.m, name)
+		connections.Unlock()
+	})
+}
+
+func TestInsertMany(t *testing.T) {
+	setupMemory(t, postTable())
+	seedPosts(t, 10)
+
+	tests := []struct {
+		name   string
+		insert func(rows []map[string]any) (int64, error)
+		rows   []map[string]any
+		want   int64
+		total  int
+	}{
+		{name: "insert many", insert: newPost().InsertMany, rows: []map[string]any{
+			{"title": "a", "slug": "a"},
+			{"title": "b", "slug": "b", "votes": 3},
+		}, want: 2, total: 3},
+		{name: "insert or ignore", insert: newPost().InsertOrIgnore, rows: []map[string]any{
+			{"title": "a otra vez", "slug": "a"},
+			{"title": "c", "slug": "c"},
+		}, want: 1, total: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := tt.insert(tt.rows)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != tt.want {
+				t.Errorf("insertados = %d, se esperaba %d", n, tt.want)
+			}
+			if total, _ := MemoryCount("posts", map[string]any{"version": 1}); total != tt.total {
+				t.Errorf("registros con version 1 = %d, se esperaba %d", total, tt.total)
+			}
+		})
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	setupMemory(t, postTable())
+	// created_at es fillable para comprobar que el upsert no lo reemplaza
+	upsertPost := func() *Model {
+		m := newPost()
+		m.Fillable("title", "slug", "votes", "version", "created_at")
+		return m
+	}
+	created := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := upsertPost().InsertMany([]map[string]any{{"title": "viejo", "slug": "post-1", "created_at": created}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		updateColumns []string
+		row           map[string]any
+		attrs         map[string]any
+	}{
+		{name: "todas las columnas", row: map[string]any{"title": "nuevo", "slug": "post-1", "votes": 5, "created_at": time.Now()},
+			attrs: map[string]any{"slug": "post-1", "title": "nuevo", "votes": 5, "version": 2, "created_at": created}},
+		{name: "columnas de update", updateColumns: []string{"votes"}, row: map[string]any{"title": "ignorado", "slug": "post-1", "votes": 7},
+			attrs: map[string]any{"slug": "post-1", "title": "nuevo", "votes": 7, "version": 3}},
+		{name: "la version del registro no se usa", row: map[string]any{"title": "otro", "slug": "post-1", "version": 1},
+			attrs: map[string]any{"slug": "post-1", "title": "otro", "version": 4}},
+		{name: "registro nuevo", row: map[string]any{"title": "nuevo", "slug": "post-2"},
+			attrs: map[string]any{"slug": "post-2", "version": 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := upsertPost().Upsert([]map[string]any{tt.row}, []string{"slug"}, tt.updateColumns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != 1 {
+				t.Errorf("filas afectadas = %d, se esperaba 1", n)
+			}
+			if count, _ := MemoryCount("posts", tt.attrs); count != 1 {
+				t.Errorf("no hay un registro con %v", tt.attrs)
+			}
+		})
+	}
+}
+
+func TestCompileUpsert(t *testing.T) {
+	setupMemory(t, postTable())
+	fakeConnection(t, "test_mysql", "mysql")
+	fakeConnection(t, "test_postgresql", "postgresql")
+
+	columns := []string{"created_at", "slug", "title", "version"}
+	rows := []map[string]any{{"created_at": time.Now(), "slug": "a", "title": "a", "version": 1}}
+
+	tests := []struct {
+		name          string
+		connection    string
+		updateColumns []string
+		want          string
+	}{
+		{name: "mysql", connection: "test_mysql",
+			want: "INSERT INTO posts (created_at, slug, title, version) VALUES (?, ?, ?, ?) ON DUPLICATE KEY UPDATE title = VALUES(title), version = version + 1"},
+		{name: "postgresql", connection: "test_postgresql",
+			want: "INSERT INTO posts (created_at, slug, title, version) VALUES (?, ?, ?, ?) ON CONFLICT (slug) DO UPDATE SET title = EXCLUDED.title, version = posts.version + 1"},
+		{name: "sin columnas para actualizar", connection: "test_postgresql", updateColumns: []string{"version"},
+			want: "INSERT INTO posts (created_at, slug, title, version) VALUES (?, ?, ?, ?) ON CONFLICT (slug) DO NOTHING"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newPost().Connection(tt.connection)
+			got, args := q.compileInsert(columns, rows, bulkInsert{kind: "upsert", uniqueBy: []string{"slug"}, updateColumns: tt.updateColumns})
+			if got != tt.want {
+				t.Errorf("consulta =\n%s\nse esperaba\n%s", got, tt.want)
+			}
+			if len(args) != len(columns) {
+				t.Errorf("argumentos = %d, se esperaba %d", len(args), len(columns))
+			}
+			if strings.Contains(got, "created_at = ") {
+				t.Errorf("la consulta actualiza created_at: %s", got)
+			}
+		})
+	}
+}
+
+func TestPacketLimit(t *testing.T) {
+	setupMemory(t, postTable())
+	fakeConnection(t, "test_mysql", "mysql")
+	fakeConnection(t, "test_mysql_small", "mysql")
+	fakeConnection(t, "test_postgresql", "postgresql")
+	// el servidor de test_mysql_small tiene un max_allowed_packet menor, no debe afectar a las demas conexiones
+	small := getConnection("test_mysql_small")
+	small.packetOnce.Do(func() { small.packetSize = 1 << 20 })
+
+	tests := []struct {
+		connection string
+		want       int
+	}{
+		{connection: "test_mysql_small", want: (1 << 20) / 4 * 3},
+		{connection: "test_mysql", want: defaultPacketSize / 4 * 3},
+		{connection: "test_postgresql", want: maxBatchSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.connection, func(t *testing.T) {
+			if got := newPost().Connection(tt.connection).packetLimit(); got != tt.want {
+				t.Errorf("packetLimit = %d, se esperaba %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package orm
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Join representa un JOIN de la consulta con sus condiciones ON
+type Join struct {
+	kind  string  // INNER | LEFT | RIGHT | CROSS
+	table string  // tabla que se une
+	ons   []where // condiciones del ON, cuando se comparan columnas value es un columnRef
+	model *Model  // modelo de la consulta, se usa para validar las columnas
+}
+
+// columnRef es una columna usada como valor en una comparacion: users.id = posts.user_id
+type columnRef string
+
+// subquery es una consulta compilada para usarla dentro de otra
+type subquery struct {
+	sql  string
+	args []any
+}
+
+// operadores que se pueden usar para comparar columnas
+var columnOperators = map[string]bool{"=": true, "!=": true, "<>": true, "<": true, "<=": true, ">": true, ">=": true}
+
+// On agrega una condicion que compara dos columnas: j.On("users.id", "=", "posts.user_id")
+func (j *Join) On(first string, operator string, second string) *Join {
+	return j.addOn("and", first, operator, second)
+}
+
+// OrOn agrega una condicion que compara dos columnas unida con OR
+func (j *Join) OrOn(first string, operator string, second string) *Join {
+	return j.addOn("or", first, operator, second)
+}
+
+// Where agrega una condicion al ON que compara una columna con un valor: j.Where("posts.published", "=", true)
+func (j *Join) Where(column string, operator string, value any) *Join {
+	j.model.addWhere("and", column, operator, value)
+	j.takeWhere()
+	return j
+}
+
+func (j *Join) addOn(boolean string, first string, operator string, second string) *Join {
+	j.model.addWhereColumn(boolean, first, operator, second)
+	j.takeWhere()
+	return j
+}
+
+// takeWhere mueve la ultima condicion agregada al modelo a las condiciones del ON
+func (j *Join) takeWhere() {
+	if j.model.err != nil || len(j.model.wheres) == 0 {
+		return
+	}
+	last := len(j.model.wheres) - 1
+	j.ons = append(j.ons, j.model.wheres[last])
+	j.model.wheres = j.model.wheres[:last]
+}
+
+// Join agrega un INNER JOIN: Join("posts", "users.id", "=", "posts.user_id")
+func (m *Model) Join(table string, first string, operator string, second string) *Model {
+	return m.addJoin("INNER", table, func(j *Join) { j.On(first, operator, second) })
+}
+
+// LeftJoin agrega un LEFT JOIN
+func (m *Model) LeftJoin(table string, first string, operator string, second string) *Model {
+	return m.addJoin("LEFT", table, func(j *Join) { j.On(first, operator, second) })
+}
+
+// RightJoin agrega un RIGHT JOIN, no es compatible con mongodb
+func (m *Model) RightJoin(table string, first string, operator string, second string) *Model {
+	return m.addJoin("RIGHT", table, func(j *Join) { j.On(first, operator, second) })
+}
+
+// CrossJoin agrega un CROSS JOIN, no es compatible con mongodb
+func (m *Model) CrossJoin(table string) *Model {
+	return m.addJoin("CROSS", table, nil)
+}
+
+// JoinOn agrega un INNER JOIN con varias condiciones
+//
+//	m.JoinOn("posts", func(j *orm.Join) {
+//		j.On("users.id", "=", "posts.user_id").Where("posts.published", "=", true)
+//	})
+func (m *Model) JoinOn(table string, fn func(j *Join)) *Model {
+	return m.addJoin("INNER", table, fn)
+}
+
+// LeftJoinOn agrega un LEFT JOIN con varias condiciones
+func (m *Model) LeftJoinOn(table string, fn func(j *Join)) *Model {
+	return m.addJoin("LEFT", table, fn)
+}
+
+// RightJoinOn agrega un RIGHT JOIN con varias condiciones, no es compatible con mongodb
+func (m *Model) RightJoinOn(table string, fn func(j *Join)) *Model {
+	return m.addJoin("RIGHT", table, fn)
+}
+
+func (m *Model) addJoin(kind string, table string, fn func(j *Join)) *Model {
+	if m.err != nil {
+		return m
+	}
+	if !isIdentifier(table) {
+		m.err = fmt.Errorf("el nombre de tabla '%s' no es válido", table)
+		return m
+	}
+
+	j := &Join{kind: kind, table: table, model: &Model{tableName: m.tableName, table: m.table, hasMigration: m.hasMigration}}
+	if fn != nil {
+		fn(j)
+	}
+	if j.model.err != nil {
+		m.err = j.model.err
+		return m
+	}
+	if kind != "CROSS" && len(j.ons) == 0 {
+		m.err = fmt.Errorf("el join con la tabla '%s' no tiene condiciones", table)
+		return m
+	}
+	if m.driver() == "mongodb" {
+		if _, _, err := m.lookupFields(j); err != nil {
+			m.err = err
+			return m
+		}
+	}
+	j.model = nil
+	m.joins = append(m.joins, j)
+	return m
+}
+
+// WhereColumn agrega una condicion que compara dos columnas: WhereColumn("updated_at", ">", "created_at")
+func (m *Model) WhereColumn(first string, operator string, second string) *Model {
+	return m.addWhereColumn("and", first, operator, second)
+}
+
+// OrWhereColumn agrega una condicion que compara dos columnas unida con OR
+func (m *Model) OrWhereColumn(first string, operator string, second string) *Model {
+	return m.addWhereColumn("or", first, operator, second)
+}
+
+func (m *Model) addWhereColumn(boolean string, first string, operator string, second string) *Model {
+	if !m.checkColumn(first) || !m.checkColumn(second) {
+		return m
+	}
+	if !columnOperators[operator] {
+		m.err = fmt.Errorf("operador '%s' no soportado para comparar columnas", operator)
+		return m
+	}
+	m.wheres = append(m.wheres, where{
+		boolean:  boolean,
+		column:   m.fieldName(first),
+		operator: operator,
+		value:    columnRef(m.fieldName(second)),
+	})
+	return m
+}
+
+// WhereExists agrega una condicion EXISTS con la subconsulta, solo sql
+//
+//	users.WhereExists(posts.WhereColumn("posts.user_id", "=", "users.id"))
+func (m *Model) WhereExists(sub ModelInterface) *Model {
+	return m.addExists("exists", sub)
+}
+
+// WhereNotExists agrega una condicion NOT EXISTS con la subconsulta, solo sql
+func (m *Model) WhereNotExists(sub ModelInterface) *Model {
+	return m.addExists("not exists", sub)
+}
+
+func (m *Model) addExists(operator string, sub ModelInterface) *Model {
+	value := m.subquery(sub)
+	if m.err != nil {
+		return m
+	}
+	m.wheres = append(m.wheres, where{boolean: "and", operator: operator, value: value})
+	return m
+}
+
+// SelectSub agrega una subconsulta al select: (SELECT COUNT(*) FROM posts WHERE ...) AS posts_count, solo sql
+func (m *Model) SelectSub(sub ModelInterface, alias string) *Model {
+	value := m.subquery(sub)
+	if m.err != nil {
+		return m
+	}
+	if !isIdentifier(alias) {
+		m.err = fmt.Errorf("el alias '%s' no es válido", alias)
+		return m
+	}
+	m.rawSelects = append(m.rawSelects, rawExpr{sql: "(" + value.sql + ") AS " + alias, args: value.args, alias: alias})
+	return m
+}
+
+// subquery compila la consulta del modelo para usarla dentro de la consulta de m
+// mongodb no soporta subconsultas, en ese caso se guarda el error en m
+func (m *Model) subquery(sub ModelInterface) subquery {
+	if m.err != nil {
+		return subquery{}
+	}
+	if m.driver() == "mongodb" {
+		m.err = fmt.Errorf("las subconsultas no son compatibles con mongodb")
+		return subquery{}
+	}
+	s := sub.getModel()
+	if s.err != nil {
+		m.err = s.err
+		return subquery{}
+	}
+	query, args := s.compileSelectSQL()
+	return subquery{sql: query, args: args}
+}
+
+// compileFrom construye la tabla y los joins de la consulta
+func (m *Model) compileFrom() (string, []any) {
+	var sb strings.Builder
+	var args []any
+	sb.WriteString(m.tableName)
+	for _, j := range m.joins {
+		sb.WriteString(" " + j.kind + " JOIN " + j.table)
+		if len(j.ons) == 0 {
+			continue
+		}
+		onSQL, onArgs := compileConditions(j.ons)
+		sb.WriteString(" ON " + onSQL)
+		args = append(args, onArgs...)
+	}
+	return sb.String(), args
+}
+
+// lookupFields retorna los campos de $lookup para el join en mongodb
+// solo se pueden traducir INNER y LEFT JOIN con una condicion de igualdad entre columnas
+func (m *Model) lookupFields(j *Join) (localField string, foreignField string, err error) {
+	if j.kind != "INNER" && j.kind != "LEFT" {
+		return "", "", fmt.Errorf("%s JOIN no es compatible con mongodb", j.kind)
+	}
+	if len(j.ons) != 1 || j.ons[0].operator != "=" {
+		return "", "", fmt.Errorf("en mongodb el join con '%s' debe tener una sola condición de igualdad", j.table)
+	}
+	ref, ok := j.ons[0].value.(columnRef)
+	if !ok {
+		return "", "", fmt.Errorf("en mongodb el join con '%s' debe comparar dos columnas", j.table)
+	}
+
+	// la columna de la tabla que se une es el foreignField y la otra el localField
+	first, second := j.ons[0].column, string(ref)
+	if strings.HasPrefix(first, j.table+".") {
+		first, second = second, first
+	}
+	if !strings.HasPrefix(second, j.table+".") {
+		return "", "", fmt.Errorf("en mongodb el join con '%s' debe usar una columna calificada de '%s'", j.table, j.table)
+	}
+	return m.fieldName(first), strings.TrimPrefix(second, j.table+"."), nil
+}
+
+// mongoLookupStages traduce los joins a etapas $lookup y $unwind
+// los campos de la tabla unida quedan en un subdocumento con el nombre de la tabla: posts.title
+func (m *Model) mongoLookupStages() ([]bson.D, error) {
+	stages := make([]bson.D, 0, len(m.joins)*2)
+	for _, j := range m.joins {
+		localField, foreignField, err := m.lookupFields(j)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages,
+			bson.D{{Key: "$lookup", Value: bson.M{
+				"from":         j.table,
+				"localField":   localField,
+				"foreignField": foreignField,
+				"as":           j.table,
+			}}},
+			bson.D{{Key: "$unwind", Value: bson.M{
+				"path":                       "$" + j.table,
+				"preserveNullAndEmptyArrays": j.kind == "LEFT",
+			}}},
+		)
+	}
+	return stages, nil
+}
@@ -0,0 +1,101 @@
+package orm
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestCompileJoins(t *testing.T) {
+	setupMemory(t, postTable(), noteTable())
+	fakeConnection(t, "test_mysql", "mysql")
+	fakeConnection(t, "test_postgresql", "postgresql")
+
+	// notesCount es la subconsulta correlacionada con posts
+	notesCount := func(connection string) *Model {
+		sub := &Model{}
+		sub.Table("note")
+		sub.Connection(connection)
+		return sub.SelectCount("total").WhereColumn("notes.slug", "=", "posts.slug")
+	}
+
+	tests := []struct {
+		name       string
+		connection string
+		query      func(q *Model) *Model
+		want       string
+		args       []any
+	}{
+		{name: "join", connection: "test_mysql", query: func(q *Model) *Model {
+			return q.Select("posts.id", "notes.body").Join("notes", "notes.slug", "=", "posts.slug").Where("posts.votes", ">", 1)
+		}, want: "SELECT posts.id, notes.body FROM posts INNER JOIN notes ON notes.slug = posts.slug WHERE posts.votes > ?", args: []any{1}},
+		{name: "left join con condiciones", connection: "test_postgresql", query: func(q *Model) *Model {
+			return q.LeftJoinOn("notes", func(j *Join) {
+				j.On("notes.slug", "=", "posts.slug").Where("notes.body", "=", "x")
+			}).WhereColumn("posts.title", "!=", "notes.body").Where("posts.votes", 2)
+		}, want: "SELECT * FROM posts LEFT JOIN notes ON notes.slug = posts.slug AND notes.body = $1 WHERE posts.title != notes.body AND posts.votes = $2", args: []any{"x", 2}},
+		{name: "cross y right join", connection: "test_mysql", query: func(q *Model) *Model {
+			return q.CrossJoin("notes").RightJoin("notes", "notes.id", "=", "posts.id")
+		}, want: "SELECT * FROM posts CROSS JOIN notes RIGHT JOIN notes ON notes.id = posts.id"},
+		{name: "subconsultas", connection: "test_postgresql", query: func(q *Model) *Model {
+			return q.SelectSub(notesCount("test_postgresql"), "notes_count").WhereExists(notesCount("test_postgresql")).Where("votes", 3)
+		}, want: "SELECT (SELECT COUNT(*) AS total FROM notes WHERE notes.slug = posts.slug) AS notes_count FROM posts " +
+			"WHERE EXISTS (SELECT COUNT(*) AS total FROM notes WHERE notes.slug = posts.slug) AND votes = $1", args: []any{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.query(newPost().Connection(tt.connection))
+			got, args := q.compileSelect()
+			if q.err != nil {
+				t.Fatal(q.err)
+			}
+			if got != tt.want {
+				t.Errorf("consulta =\n%s\nse esperaba\n%s", got, tt.want)
+			}
+			if !slices.Equal(args, tt.args) {
+				t.Errorf("argumentos = %v, se esperaba %v", args, tt.args)
+			}
+		})
+	}
+
+	invalid := []struct {
+		name  string
+		query func(q *Model) *Model
+	}{
+		{name: "tabla invalida", query: func(q *Model) *Model { return q.Join("notes;", "a", "=", "b") }},
+		{name: "columna que no existe", query: func(q *Model) *Model { return q.Join("notes", "notes.slug", "=", "posts.nada") }},
+		{name: "operador invalido", query: func(q *Model) *Model { return q.WhereColumn("title", "= 1 OR", "slug") }},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if q := tt.query(newPost().Connection("test_mysql")); q.err == nil {
+				t.Error("la consulta no tiene error")
+			}
+		})
+	}
+}
+
+func TestMongoLookup(t *testing.T) {
+	setupMemory(t, postTable(), noteTable())
+	fakeConnection(t, "test_mongodb", "mongodb")
+
+	q := newPost().Connection("test_mongodb").Join("notes", "notes.slug", "=", "posts.slug")
+	stages, err := q.mongoLookupStages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[[{$lookup map[as:notes foreignField:slug from:notes localField:slug]}] [{$unwind map[path:$notes preserveNullAndEmptyArrays:false]}]]"
+	if got := fmt.Sprint(stages); got != want {
+		t.Errorf("etapas =\n%s\nse esperaba\n%s", got, want)
+	}
+
+	// los joins que no se pueden traducir a $lookup fallan al construir la consulta
+	for _, q := range []*Model{
+		newPost().Connection("test_mongodb").RightJoin("notes", "notes.slug", "=", "posts.slug"),
+		newPost().Connection("test_mongodb").Join("notes", "notes.slug", ">", "posts.slug"),
+	} {
+		if q.err == nil {
+			t.Error("mongodb acepto un join que no se puede traducir a $lookup")
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package orm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrStaleModel se retorna al actualizar un registro que otra operacion modifico despues de leerlo
+// los controladores lo pueden identificar con errors.Is(err, orm.ErrStaleModel) y responder 409
+var ErrStaleModel = errors.New("el registro fue modificado por otra operación")
+
+// VersionColumn define la columna de la version para el bloqueo optimista
+// si no se define y la tabla tiene la columna version en la migracion se usa esa
+func (m *Model) VersionColumn(column string) {
+	m.versionColumn = column
+}
+
+// lockColumn retorna la columna de la version o vacio si la tabla no usa bloqueo optimista
+func (m *Model) lockColumn() string {
+	if m.versionColumn != "" {
+		return m.versionColumn
+	}
+	if m.HasColumn("version") {
+		return "version"
+	}
+	return ""
+}
+
+// lockVersion agrega a values la siguiente version del registro y retorna la condicion con la version esperada
+// la version esperada es la de values si viene en los datos (la que leyo el cliente) o si no la del registro cargado
+func (m *Model) lockVersion(row map[string]any, values map[string]any) (map[string]any, error) {
+	column := m.lockColumn()
+	if column == "" {
+		return nil, nil
+	}
+	expected, ok := values[column]
+	if !ok {
+		expected = row[column]
+	}
+	next := int64(1)
+	if expected != nil {
+		version, err := toInt64(expected)
+		if err != nil {
+			return nil, fmt.Errorf("la versión '%v' del registro no es válida", expected)
+		}
+		expected, next = version, version+1
+	}
+	values[column] = next
+	return map[string]any{column: expected}, nil
+}
+
+// staleError es el error de un registro desactualizado
+func (m *Model) staleError(id any) error {
+	return fmt.Errorf("%w: '%s' con %s %v", ErrStaleModel, m.tableName, m.PrimaryKey(), id)
+}
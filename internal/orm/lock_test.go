@@ -0,0 +1,43 @@
+package orm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOptimisticLock(t *testing.T) {
+	setupMemory(t, postTable())
+	seedPosts(t, 10)
+
+	first, second := newPost(), newPost()
+	if err := first.Find(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Find(1); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		model   *Model
+		values  map[string]any
+		wantErr error
+		version int64
+	}{
+		{name: "primera escritura", model: first, values: map[string]any{"title": "uno"}, version: 2},
+		{name: "version desactualizada", model: second, values: map[string]any{"title": "dos"}, wantErr: ErrStaleModel, version: 2},
+		{name: "version del cliente", model: first, values: map[string]any{"title": "tres", "version": 1}, wantErr: ErrStaleModel, version: 2},
+		{name: "despues de recargar", model: first, values: map[string]any{"title": "cuatro"}, version: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.model.Update(tt.values)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, se esperaba %v", err, tt.wantErr)
+			}
+			if n, _ := MemoryCount("posts", map[string]any{"id": 1, "version": tt.version}); n != 1 {
+				t.Errorf("el registro no tiene la version %d", tt.version)
+			}
+		})
+	}
+}
@@ -0,0 +1,702 @@
+package orm
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/donbarrigon/new-project/internal/cache"
+	"github.com/donbarrigon/new-project/internal/database/migration"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryStore son las tablas en memoria de una conexion con el driver memory
+// se usa en las pruebas para no depender de una base de datos, las tablas se crean vacias con la primera escritura
+// las columnas, claves primarias, valores por defecto y columnas unicas se toman de la migracion en cache
+type memoryStore struct {
+	sync.RWMutex
+	tables map[string]*memoryTable
+}
+
+// memoryTable son los registros de una tabla en memoria
+type memoryTable struct {
+	rows   []map[string]any
+	lastID int64 // ultimo id asignado al autoincremento
+}
+
+// ConnectMemory reemplaza la conexion default por una con el driver memory y tablas vacias
+// se usa en las pruebas despues de registrar el schema con tables.NewMigration(), tambien se puede usar DB_DRIVER=memory con Connect
+func ConnectMemory() {
+	conn := &connection{name: DefaultConnection, driver: "memory"}
+	conn.openMemory()
+
+	connections.Lock()
+	defer connections.Unlock()
+	if old, ok := connections.m[DefaultConnection]; ok {
+		old.close()
+	}
+	connections.m[DefaultConnection] = conn
+}
+
+// ResetMemory elimina los registros de todas las conexiones con el driver memory, se usa entre pruebas
+func ResetMemory() {
+	connections.RLock()
+	defer connections.RUnlock()
+	for _, conn := range connections.m {
+		if conn.memory == nil {
+			continue
+		}
+		conn.memory.Lock()
+		// las consultas en cache de las tablas tambien se eliminan
+		cache.Flush(slices.Collect(maps.Keys(conn.memory.tables))...)
+		conn.memory.tables = make(map[string]*memoryTable)
+		conn.memory.Unlock()
+	}
+}
+
+// MemoryCount retorna la cantidad de registros de la tabla en la conexion default con el driver memory
+// que tienen los valores de attrs, sin attrs cuenta todos, se usa en las aserciones de las pruebas
+func MemoryCount(table string, attrs map[string]any) (int, error) {
+	conn := getConnection(DefaultConnection)
+	if conn.memory == nil {
+		return 0, fmt.Errorf("la conexión default no usa el driver memory")
+	}
+	wheres := make([]where, 0, len(attrs))
+	for _, column := range slices.Sorted(maps.Keys(attrs)) {
+		operator := "="
+		if attrs[column] == nil {
+			operator = "null"
+		}
+		wheres = append(wheres, where{boolean: "and", column: column, operator: operator, value: attrs[column]})
+	}
+
+	conn.memory.RLock()
+	defer conn.memory.RUnlock()
+	count := 0
+	for _, row := range conn.memory.tables[table].all() {
+		ok, err := memoryMatch(row, wheres)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// openMemory crea las tablas vacias de la conexion
+func (c *connection) openMemory() error {
+	c.memory = &memoryStore{tables: make(map[string]*memoryTable)}
+	return nil
+}
+
+// table retorna la tabla, si no existe la crea
+func (s *memoryStore) table(name string) *memoryTable {
+	t, ok := s.tables[name]
+	if !ok {
+		t = &memoryTable{}
+		s.tables[name] = t
+	}
+	return t
+}
+
+// all retorna los registros de la tabla, nil si la tabla no existe
+func (t *memoryTable) all() []map[string]any {
+	if t == nil {
+		return nil
+	}
+	return t.rows
+}
+
+// snapshot retorna una copia de las tablas para revertir una transaccion
+func (s *memoryStore) snapshot() map[string]*memoryTable {
+	s.RLock()
+	defer s.RUnlock()
+	tables := make(map[string]*memoryTable, len(s.tables))
+	for name, t := range s.tables {
+		rows := make([]map[string]any, len(t.rows))
+		for i, row := range t.rows {
+			rows[i] = maps.Clone(row)
+		}
+		tables[name] = &memoryTable{rows: rows, lastID: t.lastID}
+	}
+	return tables
+}
+
+// restore reemplaza las tablas por la copia de snapshot
+func (s *memoryStore) restore(tables map[string]*memoryTable) {
+	s.Lock()
+	defer s.Unlock()
+	s.tables = tables
+}
+
+// transactionMemory ejecuta la transaccion en memoria, si fn falla se restauran las tablas como estaban al iniciar
+// no aisla las transacciones concurrentes, al revertir tambien se pierden las escrituras hechas fuera de la transaccion
+func transactionMemory(ctx context.Context, conn *connection, fn func(*Tx) error) (err error) {
+	snapshot := conn.memory.snapshot()
+	tx := &Tx{conn: conn, written: &sync.Map{}}
+	tx.ctx = context.WithValue(ctx, txKey{}, tx)
+
+	// si fn entra en panico se restauran las tablas y se propaga el panico
+	defer func() {
+		if p := recover(); p != nil {
+			conn.memory.restore(snapshot)
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		conn.memory.restore(snapshot)
+		return err
+	}
+	flushWritten(tx.written)
+	return nil
+}
+
+// memorySavepoint ejecuta la transaccion anidada, si fn falla se restauran las tablas como estaban antes de fn
+func (tx *Tx) memorySavepoint(fn func(tx *Tx) error) error {
+	snapshot := tx.conn.memory.snapshot()
+	defer func() {
+		if p := recover(); p != nil {
+			tx.conn.memory.restore(snapshot)
+			panic(p)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		tx.conn.memory.restore(snapshot)
+		return err
+	}
+	return nil
+}
+
+// memoryQuery retorna el nombre de la operacion para el log de consultas
+func (m *Model) memoryQuery(operation string) string {
+	return "memory:" + m.tableName + "." + operation
+}
+
+// memoryCheck retorna un error si la consulta usa algo que el driver memory no implementa
+func (m *Model) memoryCheck() error {
+	if len(m.joins) > 0 || m.isGrouped() || len(m.rawSelects) > 0 || len(m.havings) > 0 {
+		return fmt.Errorf("los joins, agregaciones y SelectRaw no son compatibles con el driver memory")
+	}
+	return nil
+}
+
+// memorySelect retorna copias de los registros que cumplen las condiciones con el orden, offset y limit de la consulta
+func (m *Model) memorySelect(wheres []where, paginate bool) ([]map[string]any, error) {
+	store := m.conn().memory
+	store.RLock()
+	rows := make([]map[string]any, 0)
+	for _, row := range store.tables[m.tableName].all() {
+		ok, err := memoryMatch(row, wheres)
+		if err != nil {
+			store.RUnlock()
+			return nil, err
+		}
+		if ok {
+			rows = append(rows, maps.Clone(row))
+		}
+	}
+	store.RUnlock()
+
+	if len(m.orders) > 0 {
+		slices.SortStableFunc(rows, func(a, b map[string]any) int {
+			for _, o := range m.orders {
+				column := memoryColumn(o.column)
+				c := memoryCompareNull(a[column], b[column])
+				if o.direction == "DESC" {
+					c = -c
+				}
+				if c != 0 {
+					return c
+				}
+			}
+			return 0
+		})
+	}
+	if paginate {
+		rows = rows[min(m.offsetValue, len(rows)):]
+		if m.limitValue > 0 {
+			rows = rows[:min(m.limitValue, len(rows))]
+		}
+	}
+
+	if len(m.selectedColumns) > 0 && !slices.Contains(m.selectedColumns, "*") {
+		for i, row := range rows {
+			selected := make(map[string]any, len(m.selectedColumns))
+			for _, column := range m.selectedColumns {
+				column = memoryColumn(column)
+				selected[column] = row[column]
+			}
+			rows[i] = selected
+		}
+	}
+	return rows, nil
+}
+
+// getMemory ejecuta la consulta en memoria
+func (m *Model) getMemory() error {
+	if err := m.memoryCheck(); err != nil {
+		return err
+	}
+	ctx, cancel := m.context()
+	defer cancel()
+
+	start := time.Now()
+	data, err := m.memorySelect(m.wheres, true)
+	m.record(ctx, start, m.memoryQuery("select"), nil, int64(len(data)), err)
+	if err != nil {
+		return err
+	}
+	m.Data = data
+	return nil
+}
+
+// findMemory busca el registro por la clave primaria en memoria
+func (m *Model) findMemory(id any) error {
+	wheres, err := m.scopeWheres()
+	if err != nil {
+		return err
+	}
+	wheres = append([]where{{boolean: "and", column: m.PrimaryKey(), operator: "=", value: id}}, wheres...)
+
+	ctx, cancel := m.context()
+	defer cancel()
+
+	start := time.Now()
+	data, err := m.memorySelect(wheres, false)
+	m.record(ctx, start, m.memoryQuery("find"), []any{id}, int64(len(data)), err)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("registro no encontrado")
+	}
+	m.Data = data[:1]
+	return nil
+}
+
+// countMemory cuenta los registros en memoria
+func (m *Model) countMemory() (int64, error) {
+	if err := m.memoryCheck(); err != nil {
+		return 0, err
+	}
+	ctx, cancel := m.context()
+	defer cancel()
+
+	start := time.Now()
+	data, err := m.memorySelect(m.wheres, false)
+	m.record(ctx, start, m.memoryQuery("count"), nil, 1, err)
+	return int64(len(data)), err
+}
+
+// cursorMemory recorre los resultados de la consulta en memoria
+func (m *Model) cursorMemory(yield func(map[string]any, error) bool) {
+	if err := m.memoryCheck(); err != nil {
+		yield(nil, err)
+		return
+	}
+	data, err := m.memorySelect(m.wheres, true)
+	if err != nil {
+		yield(nil, err)
+		return
+	}
+	for _, row := range data {
+		if !yield(row, nil) {
+			return
+		}
+	}
+}
+
+// createMemory inserta el registro en memoria y asigna la clave primaria generada
+func (m *Model) createMemory(values map[string]any) error {
+	ctx, cancel := m.context()
+	defer cancel()
+
+	store := m.conn().memory
+	store.Lock()
+	defer store.Unlock()
+
+	start := time.Now()
+	err := m.memoryInsert(store.table(m.tableName), values)
+	m.record(ctx, start, m.memoryQuery("insert"), nil, 1, err)
+	return err
+}
+
+// insertManyMemory inserta los registros en memoria
+// con ignore se omiten los duplicados y con upsert se actualizan los registros con los mismos valores en uniqueBy
+func (m *Model) insertManyMemory(rows []map[string]any, bulk bulkInsert) (int64, error) {
+	ctx, cancel := m.context()
+	defer cancel()
+
+	store := m.conn().memory
+	store.Lock()
+	defer store.Unlock()
+	t := store.table(m.tableName)
+
+	start := time.Now()
+	var total int64
+	for _, values := range rows {
+		if bulk.kind == "upsert" {
+			if i := memoryFind(t.rows, values, bulk.uniqueBy); i >= 0 {
+				updates := m.upsertColumns(sortedColumns(values), bulk)
+				for _, column := range updates {
+					t.rows[i][column] = values[column]
+				}
+				if lock := m.lockColumn(); lock != "" && len(updates) > 0 {
+					version, _ := toInt64(t.rows[i][lock])
+					t.rows[i][lock] = version + 1
+				}
+				total++
+				continue
+			}
+		}
+		if err := m.memoryInsert(t, values); err != nil {
+			if bulk.kind == "ignore" && strings.HasPrefix(err.Error(), "registro duplicado") {
+				continue
+			}
+			m.record(ctx, start, m.memoryQuery("insert"), nil, total, err)
+			return total, err
+		}
+		total++
+	}
+	m.record(ctx, start, m.memoryQuery("insert"), nil, total, nil)
+	return total, nil
+}
+
+// updateMemory actualiza el registro con la clave primaria id en memoria, match son condiciones adicionales
+func (m *Model) updateMemory(id any, values map[string]any, match map[string]any) error {
+	ctx, cancel := m.context()
+	defer cancel()
+
+	store := m.conn().memory
+	store.Lock()
+	defer store.Unlock()
+	t := store.table(m.tableName)
+
+	start := time.Now()
+	keys := map[string]any{m.PrimaryKey(): id}
+	maps.Copy(keys, match)
+	i := memoryFind(t.rows, keys, slices.Collect(maps.Keys(keys)))
+	if i < 0 {
+		m.record(ctx, start, m.memoryQuery("update"), []any{id}, 0, nil)
+		if m.lockColumn() != "" {
+			return m.staleError(id)
+		}
+		return nil
+	}
+
+	row := maps.Clone(t.rows[i])
+	maps.Copy(row, values)
+	// las columnas ON UPDATE CURRENT_TIMESTAMP de la migracion
+	if m.table != nil {
+		for _, col := range m.table.Columns {
+			if _, ok := values[col.Name]; !ok && col.OnUpdate != nil && strings.EqualFold(*col.OnUpdate, "CURRENT_TIMESTAMP") {
+				row[col.Name] = time.Now()
+			}
+		}
+	}
+	if err := m.memoryUnique(t, row, i); err != nil {
+		m.record(ctx, start, m.memoryQuery("update"), []any{id}, 0, err)
+		return err
+	}
+	t.rows[i] = row
+	m.record(ctx, start, m.memoryQuery("update"), []any{id}, 1, nil)
+	return nil
+}
+
+// deleteMemory elimina los registros en memoria
+func (m *Model) deleteMemory(ids []any) error {
+	ctx, cancel := m.context()
+	defer cancel()
+
+	wheres := []where{{boolean: "and", column: m.PrimaryKey(), operator: "in", value: ids}}
+	for column, value := range m.tenantMatch(nil) {
+		wheres = append(wheres, where{boolean: "and", column: column, operator: "=", value: value})
+	}
+
+	store := m.conn().memory
+	store.Lock()
+	defer store.Unlock()
+	t := store.table(m.tableName)
+
+	start := time.Now()
+	var err error
+	before := len(t.rows)
+	t.rows = slices.DeleteFunc(t.rows, func(row map[string]any) bool {
+		ok, matchErr := memoryMatch(row, wheres)
+		if matchErr != nil {
+			err = matchErr
+		}
+		return ok
+	})
+	m.record(ctx, start, m.memoryQuery("delete"), ids, int64(before-len(t.rows)), err)
+	return err
+}
+
+// memoryInsert agrega el registro a la tabla con los valores por defecto de la migracion
+// si no tiene clave primaria y es autoincremental se asigna en values como lo hace el insert de sql
+func (m *Model) memoryInsert(t *memoryTable, values map[string]any) error {
+	pk := m.PrimaryKey()
+	if id, ok := values[pk]; !ok || id == nil {
+		if col := m.memoryColumnDef(pk); col != nil && !col.AutoIncrement {
+			return fmt.Errorf("la clave primaria '%s' de '%s' es requerida", pk, m.tableName)
+		}
+		t.lastID++
+		values[pk] = t.lastID
+	} else if n, err := toInt64(id); err == nil && n > t.lastID {
+		t.lastID = n
+	}
+
+	row := make(map[string]any, len(values))
+	if m.table != nil {
+		for _, col := range m.table.Columns {
+			if col.Default != nil {
+				row[col.Name] = memoryDefault(*col.Default)
+			} else {
+				row[col.Name] = nil
+			}
+		}
+	}
+	maps.Copy(row, values)
+	if err := m.memoryUnique(t, row, -1); err != nil {
+		return err
+	}
+	t.rows = append(t.rows, row)
+	return nil
+}
+
+// memoryUnique retorna un error si otro registro tiene la misma clave primaria o los mismos valores en columnas unicas
+// skip es la posicion del registro que se esta actualizando, -1 al insertar
+func (m *Model) memoryUnique(t *memoryTable, row map[string]any, skip int) error {
+	uniques := [][]string{{m.PrimaryKey()}}
+	if m.table != nil {
+		for _, col := range m.table.Columns {
+			if col.Unique {
+				uniques = append(uniques, []string{col.Name})
+			}
+		}
+		for _, index := range m.table.Indexes {
+			if index.Unique {
+				uniques = append(uniques, index.Columns)
+			}
+		}
+	}
+	for _, columns := range uniques {
+		// como en sql los nulos no se consideran duplicados
+		if slices.ContainsFunc(columns, func(c string) bool { return row[c] == nil }) {
+			continue
+		}
+		if i := memoryFind(t.rows, row, columns); i >= 0 && i != skip {
+			return fmt.Errorf("registro duplicado en '%s' para (%s)", m.tableName, strings.Join(columns, ", "))
+		}
+	}
+	return nil
+}
+
+// memoryColumnDef retorna la columna de la migracion, nil si no hay migracion o no existe
+func (m *Model) memoryColumnDef(name string) *migration.Column {
+	if m.table == nil {
+		return nil
+	}
+	for i := range m.table.Columns {
+		if m.table.Columns[i].Name == name {
+			return &m.table.Columns[i]
+		}
+	}
+	return nil
+}
+
+// memoryFind retorna la posicion del primer registro con los mismos valores que values en las columnas, -1 si no hay
+func memoryFind(rows []map[string]any, values map[string]any, columns []string) int {
+	return slices.IndexFunc(rows, func(row map[string]any) bool {
+		for _, column := range columns {
+			if c, ok := memoryCompare(row[column], values[column]); !ok || c != 0 {
+				if row[column] == nil && values[column] == nil {
+					continue
+				}
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// memoryMatch indica si el registro cumple las condiciones, los OR separan grupos de condiciones unidas con AND
+func memoryMatch(row map[string]any, wheres []where) (bool, error) {
+	result, current := false, true
+	for i, w := range wheres {
+		if i > 0 && w.boolean == "or" {
+			result = result || current
+			current = true
+		}
+		ok, err := memoryCondition(row, w)
+		if err != nil {
+			return false, err
+		}
+		current = current && ok
+	}
+	return result || current, nil
+}
+
+// memoryCondition indica si el registro cumple la condicion, las comparaciones con nulos son falsas como en sql
+func memoryCondition(row map[string]any, w where) (bool, error) {
+	if w.group != nil {
+		return memoryMatch(row, w.group)
+	}
+	if _, ok := w.value.(subquery); ok {
+		return false, fmt.Errorf("las subconsultas no son compatibles con el driver memory")
+	}
+
+	value := row[memoryColumn(w.column)]
+	target := w.value
+	if ref, ok := target.(columnRef); ok {
+		target = row[memoryColumn(string(ref))]
+	}
+
+	switch w.operator {
+	case "null":
+		return value == nil, nil
+	case "not null":
+		return value != nil, nil
+	case "in", "not in":
+		if value == nil {
+			return false, nil
+		}
+		found := slices.ContainsFunc(toSlice(target), func(item any) bool {
+			c, ok := memoryCompare(value, item)
+			return ok && c == 0
+		})
+		return found == (w.operator == "in"), nil
+	case "like", "not like":
+		if value == nil {
+			return false, nil
+		}
+		re, err := regexp.Compile("(?is)" + likeToRegex(keyString(target)))
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(keyString(value)) == (w.operator == "like"), nil
+	}
+
+	c, ok := memoryCompare(value, target)
+	if !ok {
+		return false, nil
+	}
+	switch w.operator {
+	case "=":
+		return c == 0, nil
+	case "!=", "<>":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	case ">=":
+		return c >= 0, nil
+	}
+	return false, fmt.Errorf("operador '%s' no soportado", w.operator)
+}
+
+// memoryCompare compara dos valores de una columna, ok es false si alguno es nulo
+// las fechas se comparan como fechas, los numeros como numeros aunque uno venga como string y lo demas como texto
+func memoryCompare(a any, b any) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	_, aTime := a.(time.Time)
+	_, bTime := b.(time.Time)
+	if aTime || bTime {
+		ta, errA := toTime(a)
+		tb, errB := toTime(b)
+		if errA == nil && errB == nil {
+			return ta.Compare(tb), true
+		}
+	}
+	if isNumber(a) || isNumber(b) {
+		fa, errA := toFloat64(boolToNumber(a))
+		fb, errB := toFloat64(boolToNumber(b))
+		if errA == nil && errB == nil {
+			return cmp.Compare(fa, fb), true
+		}
+	}
+	return strings.Compare(keyString(a), keyString(b)), true
+}
+
+// memoryCompareNull compara dos valores para ordenar, los nulos van primero como en mysql
+func memoryCompareNull(a any, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	c, _ := memoryCompare(a, b)
+	return c
+}
+
+// isNumber indica si el valor es un numero o un booleano
+func isNumber(value any) bool {
+	switch value.(type) {
+	case bool, primitive.Decimal128:
+		return true
+	}
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// boolToNumber convierte los booleanos en 1 y 0 como los guarda mysql
+func boolToNumber(value any) any {
+	if b, ok := value.(bool); ok {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	return value
+}
+
+// memoryColumn retorna el nombre de la columna sin la tabla: users.name es name
+func memoryColumn(column string) string {
+	if i := strings.LastIndex(column, "."); i >= 0 {
+		return column[i+1:]
+	}
+	return column
+}
+
+// memoryDefault convierte el valor por defecto de la migracion en el valor que tendria el registro
+func memoryDefault(value string) any {
+	switch upper := strings.ToUpper(value); {
+	case upper == "CURRENT_TIMESTAMP":
+		return time.Now()
+	case upper == "NULL":
+		return nil
+	case upper == "TRUE", upper == "FALSE":
+		return upper == "TRUE"
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f
+	}
+	return strings.Trim(value, "'")
+}
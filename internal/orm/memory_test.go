@@ -0,0 +1,273 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/donbarrigon/new-project/internal/cache"
+	"github.com/donbarrigon/new-project/internal/database/migration"
+)
+
+// setupMemory registra las tablas de la prueba como schema y reemplaza la conexion default por una en memoria
+func setupMemory(t *testing.T, tables ...*migration.Table) {
+	t.Helper()
+	schema := migration.NewSchema("test")
+	if err := schema.AddTables(tables...); err != nil {
+		t.Fatal(err)
+	}
+	cache.NewSchema(schema)
+	ConnectMemory()
+	t.Cleanup(ResetMemory)
+}
+
+// postTable es la tabla posts de las pruebas, con version para el bloqueo optimista y soft deletes
+func postTable() *migration.Table {
+	return migration.NewTable("post",
+		migration.BigIncrements(),
+		migration.String("title"),
+		migration.String("slug", "unique"),
+		migration.Integer("votes", "default:0"),
+		migration.Boolean("published"),
+		migration.Json("meta"),
+		migration.Version(),
+		migration.CreatedAt(),
+		migration.UpdatedAt(),
+		migration.DeletedAt(),
+	)
+}
+
+// noteTable es la tabla notes de las pruebas, sin version ni soft deletes
+func noteTable() *migration.Table {
+	return migration.NewTable("note",
+		migration.BigIncrements(),
+		migration.String("body"),
+		migration.String("slug"),
+		migration.CreatedAt(),
+		migration.UpdatedAt(),
+	)
+}
+
+// newPost crea el modelo de la tabla posts de las pruebas
+func newPost() *Model {
+	m := &Model{}
+	m.Table("post")
+	m.Fillable("title", "slug", "votes", "published", "meta", "version")
+	m.SoftDeletes()
+	return m
+}
+
+// seedPosts crea los posts con los titulos y votos en orden, sus ids empiezan en 1
+func seedPosts(t *testing.T, votes ...int) {
+	t.Helper()
+	for i, v := range votes {
+		err := newPost().Create(map[string]any{"title": fmt.Sprintf("post %d", i+1), "slug": fmt.Sprintf("post-%d", i+1), "votes": v})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// ids retorna las claves primarias de los registros cargados en el modelo
+func ids(m *Model) []int64 {
+	result := make([]int64, len(m.Data))
+	for i, row := range m.Data {
+		result[i], _ = toInt64(row["id"])
+	}
+	return result
+}
+
+func TestMemoryQuery(t *testing.T) {
+	setupMemory(t, postTable())
+	seedPosts(t, 10, 30, 20, 50, 40)
+
+	tests := []struct {
+		name  string
+		query func(q *Model) *Model
+		want  []int64
+	}{
+		{name: "todos", query: func(q *Model) *Model { return q }, want: []int64{1, 2, 3, 4, 5}},
+		{name: "igual", query: func(q *Model) *Model { return q.Where("votes", 20) }, want: []int64{3}},
+		{name: "mayor", query: func(q *Model) *Model { return q.Where("votes", ">", 25) }, want: []int64{2, 4, 5}},
+		{name: "like", query: func(q *Model) *Model { return q.Where("slug", "like", "%-4") }, want: []int64{4}},
+		{name: "or", query: func(q *Model) *Model { return q.Where("votes", 10).OrWhere("votes", 50) }, want: []int64{1, 4}},
+		{name: "in", query: func(q *Model) *Model { return q.WhereIn("id", []int{2, 5}) }, want: []int64{2, 5}},
+		{name: "not in", query: func(q *Model) *Model { return q.WhereNotIn("id", []int{2, 5}) }, want: []int64{1, 3, 4}},
+		{name: "null", query: func(q *Model) *Model { return q.WhereNull("meta").Where("votes", "<=", 20) }, want: []int64{1, 3}},
+		{name: "grupo", query: func(q *Model) *Model {
+			return q.Where("votes", ">", 10).WhereGroup(func(g *Model) { g.Where("id", 2).OrWhere("id", 4) })
+		}, want: []int64{2, 4}},
+		{name: "orden", query: func(q *Model) *Model { return q.OrderBy("votes", "desc") }, want: []int64{4, 5, 2, 3, 1}},
+		{name: "orden limit offset", query: func(q *Model) *Model { return q.OrderBy("votes").Offset(1).Limit(2) }, want: []int64{3, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.query(newPost())
+			if err := q.Get(); err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(q); !slices.Equal(got, tt.want) {
+				t.Errorf("ids = %v, se esperaba %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("find", func(t *testing.T) {
+		q := newPost()
+		if err := q.Find(3); err != nil {
+			t.Fatal(err)
+		}
+		if q.Data[0]["title"] != "post 3" {
+			t.Errorf("title = %v, se esperaba post 3", q.Data[0]["title"])
+		}
+		if err := newPost().Find(99); err == nil {
+			t.Error("Find de un id que no existe no retorno error")
+		}
+	})
+
+	t.Run("count", func(t *testing.T) {
+		n, err := newPost().Where("votes", ">=", 30).Count()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 3 {
+			t.Errorf("count = %d, se esperaba 3", n)
+		}
+	})
+}
+
+func TestMemoryWrite(t *testing.T) {
+	setupMemory(t, postTable())
+
+	post := newPost()
+	if err := post.Create(map[string]any{"title": "hola", "slug": "hola"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		write func() error
+		attrs map[string]any
+		want  int
+	}{
+		{name: "create con defaults", write: func() error { return nil }, attrs: map[string]any{"id": 1, "votes": 0, "version": 1, "deleted_at": nil}, want: 1},
+		{name: "update", write: func() error { return post.Update(map[string]any{"title": "editado"}) }, attrs: map[string]any{"title": "editado", "version": 2}, want: 1},
+		{name: "soft delete", write: post.Delete, attrs: map[string]any{"id": 1, "deleted_at": nil}, want: 0},
+		{name: "restore", write: func() error {
+			trashed := newPost().OnlyTrashed()
+			if err := trashed.Get(); err != nil {
+				return err
+			}
+			return trashed.Restore()
+		}, attrs: map[string]any{"id": 1, "deleted_at": nil}, want: 1},
+		{name: "force delete", write: func() error {
+			q := newPost()
+			if err := q.Find(1); err != nil {
+				return err
+			}
+			return q.ForceDelete()
+		}, attrs: map[string]any{"id": 1}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.write(); err != nil {
+				t.Fatal(err)
+			}
+			n, err := MemoryCount("posts", tt.attrs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != tt.want {
+				t.Errorf("registros con %v = %d, se esperaba %d", tt.attrs, n, tt.want)
+			}
+		})
+	}
+
+	t.Run("soft delete oculta el registro", func(t *testing.T) {
+		post := newPost()
+		if err := post.Create(map[string]any{"title": "oculto", "slug": "oculto"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := post.Delete(); err != nil {
+			t.Fatal(err)
+		}
+		if n, _ := newPost().Count(); n != 0 {
+			t.Errorf("count = %d, el registro eliminado no se debe contar", n)
+		}
+		if n, _ := newPost().WithTrashed().Count(); n != 1 {
+			t.Errorf("count con WithTrashed = %d, se esperaba 1", n)
+		}
+	})
+
+	t.Run("unique", func(t *testing.T) {
+		err := newPost().Create(map[string]any{"title": "otro", "slug": "oculto"})
+		if err == nil {
+			t.Error("se creo un registro con un slug duplicado")
+		}
+	})
+}
+
+func TestMemoryTransaction(t *testing.T) {
+	setupMemory(t, postTable())
+	ctx := context.Background()
+	errRollback := errors.New("rollback")
+
+	tests := []struct {
+		name    string
+		fn      func(tx *Tx) error
+		wantErr error
+		want    []string
+	}{
+		{name: "commit", fn: func(tx *Tx) error {
+			return newPost().Tx(tx).Create(map[string]any{"title": "a", "slug": "a"})
+		}, want: []string{"a"}},
+		{name: "rollback", fn: func(tx *Tx) error {
+			if err := newPost().Tx(tx).Create(map[string]any{"title": "b", "slug": "b"}); err != nil {
+				return err
+			}
+			return errRollback
+		}, wantErr: errRollback, want: []string{"a"}},
+		{name: "savepoint", fn: func(tx *Tx) error {
+			if err := newPost().Tx(tx).Create(map[string]any{"title": "c", "slug": "c"}); err != nil {
+				return err
+			}
+			err := tx.Transaction(func(nested *Tx) error {
+				if err := newPost().Tx(nested).Create(map[string]any{"title": "d", "slug": "d"}); err != nil {
+					return err
+				}
+				return errRollback
+			})
+			if !errors.Is(err, errRollback) {
+				return fmt.Errorf("la transaccion anidada retorno %v", err)
+			}
+			return nil
+		}, want: []string{"a", "c"}},
+		{name: "contexto de la transaccion", fn: func(tx *Tx) error {
+			if err := newPost().WithContext(tx.Context()).Create(map[string]any{"title": "e", "slug": "e"}); err != nil {
+				return err
+			}
+			return errRollback
+		}, wantErr: errRollback, want: []string{"a", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Transaction(ctx, tt.fn)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, se esperaba %v", err, tt.wantErr)
+			}
+			q := newPost().OrderBy("id")
+			if err := q.Get(); err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, len(q.Data))
+			for i, row := range q.Data {
+				got[i] = fmt.Sprint(row["title"])
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("titulos = %v, se esperaba %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package orm
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/donbarrigon/new-project/internal/database/migration"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migrate crea las tablas que no existen en la conexion del contexto con sus indices
+// con un tenant en el contexto en los modos database y schema se crean en la base de datos del tenant
+// en modo column se agrega la columna del tenant a las tablas que no son centrales y no la tienen
+func Migrate(ctx context.Context, tables []migration.Table) error {
+	conn := contextConnection(ctx)
+	for _, table := range tables {
+		table := withTenantColumn(table)
+		var err error
+		switch conn.driver {
+		case "mongodb":
+			err = conn.migrateMongoDB(ctx, &table)
+		case "mysql", "postgresql":
+			err = conn.migrateSQL(ctx, &table)
+		case "memory":
+			// las tablas en memoria se crean al usarlas con las columnas del schema
+		default:
+			return conn.unsupported()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withTenantColumn retorna la tabla con la columna del tenant en tenancy por columna
+func withTenantColumn(table migration.Table) migration.Table {
+	t := tenancyConfig()
+	if t.mode != TenancyColumn || table.Central {
+		return table
+	}
+	if slices.ContainsFunc(table.Columns, func(c migration.Column) bool { return c.Name == t.column }) {
+		return table
+	}
+	column := migration.TenantID()
+	column.Name = t.column
+	table.Columns = append(slices.Clone(table.Columns), *column)
+	return table
+}
+
+// migrateSQL ejecuta las sentencias de creacion de la tabla
+func (c *connection) migrateSQL(ctx context.Context, table *migration.Table) error {
+	statements, err := table.CreateSQL(c.driver)
+	if err != nil {
+		return err
+	}
+	for _, query := range statements {
+		qctx, cancel := withTimeout(ctx, 0)
+		_, err := c.sql.ExecContext(qctx, query)
+		cancel()
+		if err != nil {
+			return dbError(fmt.Sprintf("error al crear la tabla '%s'", table.Name), err)
+		}
+	}
+	return nil
+}
+
+// migrateMongoDB crea la coleccion si no existe y sus indices, los indices que ya existen no se modifican
+func (c *connection) migrateMongoDB(ctx context.Context, table *migration.Table) error {
+	qctx, cancel := withTimeout(ctx, 0)
+	defer cancel()
+
+	db := c.mongo.Database(c.database)
+	names, err := db.ListCollectionNames(qctx, bson.M{"name": table.Name})
+	if err != nil {
+		return dbError(fmt.Sprintf("error al consultar la coleccion '%s'", table.Name), err)
+	}
+	if len(names) == 0 {
+		if err := db.CreateCollection(qctx, table.Name); err != nil {
+			return dbError(fmt.Sprintf("error al crear la coleccion '%s'", table.Name), err)
+		}
+	}
+
+	indexes := make([]mongo.IndexModel, 0, len(table.Indexes))
+	for _, col := range table.Columns {
+		if col.Index || col.Unique {
+			indexes = append(indexes, mongo.IndexModel{
+				Keys:    bson.D{{Key: col.Name, Value: 1}},
+				Options: options.Index().SetUnique(col.Unique),
+			})
+		}
+	}
+	for _, index := range table.Indexes {
+		keys := bson.D{}
+		for _, column := range index.Columns {
+			keys = append(keys, bson.E{Key: column, Value: 1})
+		}
+		opts := options.Index().SetUnique(index.Unique)
+		if index.Name != "" {
+			opts.SetName(index.Name)
+		}
+		indexes = append(indexes, mongo.IndexModel{Keys: keys, Options: opts})
+	}
+	if len(indexes) == 0 {
+		return nil
+	}
+	if _, err := db.Collection(table.Name).Indexes().CreateMany(qctx, indexes); err != nil {
+		return dbError(fmt.Sprintf("error al crear los indices de '%s'", table.Name), err)
+	}
+	return nil
+}
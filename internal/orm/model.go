@@ -1,7 +1,9 @@
 package orm
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/donbarrigon/new-project/internal/cache"
 	"github.com/donbarrigon/new-project/internal/database/migration"
@@ -23,6 +25,12 @@ type ModelInterface interface {
 	// Recibe una lista de nombres de atributos que están protegidos de ser asignados masivamente.
 	Guarded(fields ...string)
 
+	// Hidden establece los atributos que no se incluyen al convertir el modelo a json.
+	Hidden(fields ...string)
+
+	// Visible establece los unicos atributos que se incluyen al convertir el modelo a json.
+	Visible(fields ...string)
+
 	// BeforeSave se ejecuta antes de crear o actualizar el modelo en la base de datos.
 	BeforeSave(hook func() error) error
 
@@ -47,37 +55,103 @@ type ModelInterface interface {
 	// AfterUpdate se ejecuta despues de actualizar el modelo de la base de datos.
 	AfterUpdate(hook func() error) error
 
-	// Load loads a relationship (HasMany, BelongsTo, ManyToMany, etc.).
-	//Load(name ...string) any
+	// With indica las relaciones que se cargaran junto con la consulta (eager loading).
+	// las relaciones anidadas se separan con punto: With("roles.permissions")
+	With(names ...string) *Model
+
+	// Load carga las relaciones (HasMany, BelongsTo, BelongsToMany, etc.) sobre los registros que ya estan en Data.
+	Load(names ...string) error
 
 	// Find encuentra un modelo por el valor de primary key.
 	// Find(id any, columns ...string) error
+
+	// getModel retorna el Model base, permite a orm trabajar con los structs que lo embeben
+	getModel() *Model
 }
 
 // estructura base para modelos
 type Model struct {
-	tableName       string           // tableName es el nombre de la tabla
-	table           *migration.Table //estrutura de la base de datos para la migracion
-	hasMigration    bool             // Indica si el modelo tiene una migración asociada
-	fillable        []string         // Fillable establece los atributos que son asignables en masa (mass-assignment).
-	guarded         []string         // Guarded establece los atributos que no deben ser asignados de manera masiva.
-	Data            []map[string]any // variable donde se guarda los resultados de los query
-	selectedColumns []string         // selectedColumns almacena las columnas que se usaran para la consulta
+	tableName       string               // tableName es el nombre de la tabla
+	modelName       string               // modelName es el nombre del modelo en singular, se usa para inferir las claves foraneas
+	table           *migration.Table     //estrutura de la base de datos para la migracion
+	hasMigration    bool                 // Indica si el modelo tiene una migración asociada
+	fillable        []string             // Fillable establece los atributos que son asignables en masa (mass-assignment).
+	guarded         []string             // Guarded establece los atributos que no deben ser asignados de manera masiva.
+	versionColumn   string               // columna de la version para el bloqueo optimista
+	Data            []map[string]any     // variable donde se guarda los resultados de los query
+	selectedColumns []string             // selectedColumns almacena las columnas que se usaran para la consulta
+	relations       map[string]*Relation // relaciones definidas para el modelo
+	tx              *Tx                  // transaccion a la que esta vinculado el modelo
+	ctx             context.Context      // contexto de las consultas, se asigna con WithContext
+	timeout         time.Duration        // tiempo limite de las consultas, 0 usa DB_QUERY_TIMEOUT
+	rememberTTL     time.Duration        // tiempo que se guardan los resultados en cache, 0 no usa cache
+	connName        string               // conexion del modelo, vacio es la default
 	// variables que se usaran al construir la consulta
 
-	// where         string
-	// orderBy       string
-	// join          []Join
-	// limit int
+	wheres        []where          // condiciones del where
+	orders        []order          // orden de los resultados
+	limitValue    int              // cantidad maxima de registros, 0 es sin limite
+	offsetValue   int              // cantidad de registros a saltar
+	with          []string         // relaciones que se cargan con la consulta
+	groups        []string         // columnas del GROUP BY
+	havings       []where          // condiciones del HAVING
+	aggregates    []aggregate      // funciones de agregacion del select
+	rawSelects    []rawExpr        // expresiones de SelectRaw, solo sql
+	joins         []*Join          // joins de la consulta
+	scopes        *scopeSet        // scopes definidos en el modelo
+	attributes    *attributeSet    // casts, accessors y mutators de la tabla
+	makeVisible   []string         // columnas ocultas que se muestran en el json de esta instancia
+	makeHidden    []string         // columnas que se ocultan en el json de esta instancia
+	single        bool             // `m.Data` tiene un registro cargado con Find, First o Create y se serializa como objeto
+	withoutScopes []string         // global scopes que no se aplican a la consulta, * para todos
+	scopesApplied bool             // indica si ya se agregaron las condiciones de los global scopes
+	withoutTenant bool             // no se filtra por tenant en tenancy por columna, se asigna con WithoutTenant
+	original      []map[string]any // copia de los registros de Data como se cargaron, para saber que cambio
+	muted         bool             // indica si los eventos estan silenciados
+	err           error            // primer error ocurrido al construir la consulta, se retorna al ejecutarla
 }
 
 func (m *Model) Table(name string) {
-	m.tableName = formatter.ToTableName(name)
+	m.setTable(formatter.ToTableName(name))
+	m.modelName = formatter.ToSnakeCase(name)
+}
+
+// setTable asigna el nombre de la tabla tal cual, sin formatearlo
+// se usa internamente cuando ya se tiene el nombre de la tabla y no el del modelo
+func (m *Model) setTable(tableName string) {
+	m.tableName = tableName
+	m.modelName = formatter.Singularize(tableName)
 	// toma la estructura de la tabla segun la migracion
 	m.table = cache.GetTable(m.tableName)
-	if m.table != nil {
-		m.hasMigration = true
+	m.hasMigration = m.table != nil
+}
+
+// newModel crea un modelo base para la tabla, se usa para consultar las relaciones
+func newModel(tableName string) *Model {
+	m := &Model{}
+	m.setTable(tableName)
+	return m
+}
+
+func (m *Model) getModel() *Model {
+	return m
+}
+
+// GetTableName retorna el nombre de la tabla o coleccion del modelo
+func (m *Model) GetTableName() string {
+	return m.tableName
+}
+
+// PrimaryKey retorna el nombre de la clave primaria
+// en mongodb siempre es _id, en sql se toma de la migracion y si no hay se usa id
+func (m *Model) PrimaryKey() string {
+	if m.driver() == "mongodb" {
+		return "_id"
 	}
+	if m.table != nil && len(m.table.PrimaryKeys) > 0 {
+		return m.table.PrimaryKeys[0]
+	}
+	return "id"
 }
 
 func (m *Model) Fillable(fields ...string) {
@@ -166,18 +240,18 @@ func (m *Model) SetSelectedColumns(columns []string) error {
 	// Determinar las columnas a consultar
 	if len(columns) > 0 {
 		// Usar las columnas proporcionadas
-		m.selectedColumns = columns
 		// elimina la columna si no existe
-		if m.hasMigration {
-			for i, column := range columns {
-				if !m.HasColumn(column) {
-					columns = append(columns[:i], columns[i+1:]...)
-				}
+		m.selectedColumns = make([]string, 0, len(columns))
+		for _, column := range columns {
+			if m.columnError(column) != nil {
+				continue
 			}
+			m.selectedColumns = append(m.selectedColumns, m.fieldName(column))
 		}
 		if len(m.selectedColumns) == 0 {
 			return fmt.Errorf("no hay campos válidos para consultar")
 		}
+		return nil
 	}
 
 	// Usar las columnas de la migracion
@@ -298,3 +372,45 @@ func (m *Model) SetSelectedColumns(columns []string) error {
 // 		e.columns = append(e.columns, formatter.ToSnakeCase(field.Name))
 // 	}
 // }
+
+// isFillable indica si el atributo puede ser asignado de forma masiva
+// si hay fillable solo se permiten esos, los guarded nunca se permiten
+func (m *Model) isFillable(column string) bool {
+	for _, g := range m.guarded {
+		if g == column {
+			return false
+		}
+	}
+	if len(m.fillable) == 0 {
+		return true
+	}
+	for _, f := range m.fillable {
+		if f == column {
+			return true
+		}
+	}
+	return false
+}
+
+// fillData retorna los datos que se pueden guardar segun fillable, guarded y las columnas de la migracion
+// la columna del tenant en modo column no depende de fillable porque la asigna applyTenant
+func (m *Model) fillData(data map[string]any) (map[string]any, error) {
+	result := make(map[string]any, len(data))
+	tenant := m.tenantColumn()
+	for column, value := range data {
+		if !m.isFillable(column) && (tenant == "" || column != tenant) {
+			continue
+		}
+		if m.hasMigration && !m.HasColumn(column) {
+			continue
+		}
+		if !isIdentifier(column) {
+			return nil, fmt.Errorf("el nombre de columna '%s' no es válido", column)
+		}
+		result[column] = value
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no hay campos válidos para guardar")
+	}
+	return result, nil
+}
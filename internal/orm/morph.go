@@ -0,0 +1,268 @@
+package orm
+
+import (
+	"fmt"
+	"maps"
+	"sync"
+
+	"github.com/donbarrigon/new-project/lib/formatter"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// tipos de relaciones polimorficas soportadas
+const (
+	MorphToRelation     = "morph_to"
+	MorphOneRelation    = "morph_one"
+	MorphManyRelation   = "morph_many"
+	MorphToManyRelation = "morph_to_many"
+)
+
+// morphMap relaciona el valor guardado en la columna <name>_type con el nombre del modelo
+// MorphTo solo carga los tipos registrados, asi un valor de la base de datos no puede apuntar a cualquier tabla
+var morphMap = struct {
+	sync.RWMutex
+	aliases map[string]string // alias => tabla
+	tables  map[string]string // tabla => alias
+}{aliases: make(map[string]string), tables: make(map[string]string)}
+
+// MorphMap registra los alias que se guardan en las columnas <name>_type
+// orm.MorphMap(map[string]string{"user": "user", "App\\Models\\Post": "post"})
+// la key es el valor en la base de datos y el valor es el nombre del modelo
+// todos los modelos que se cargan con MorphTo deben estar registrados
+func MorphMap(aliases map[string]string) {
+	morphMap.Lock()
+	defer morphMap.Unlock()
+	for alias, model := range aliases {
+		table := formatter.ToTableName(model)
+		morphMap.aliases[alias] = table
+		morphMap.tables[table] = alias
+	}
+}
+
+// morphTable retorna la tabla a la que apunta el valor de la columna <name>_type
+// el valor viene de la base de datos, si no esta registrado con MorphMap es un error
+func morphTable(alias string) (string, error) {
+	morphMap.RLock()
+	defer morphMap.RUnlock()
+	if table, ok := morphMap.aliases[alias]; ok {
+		return table, nil
+	}
+	return "", fmt.Errorf("el tipo polimórfico '%s' no está registrado con MorphMap", alias)
+}
+
+// MorphType retorna el valor que se guarda en las columnas <name>_type para este modelo
+func (m *Model) MorphType() string {
+	morphMap.RLock()
+	defer morphMap.RUnlock()
+	if alias, ok := morphMap.tables[m.tableName]; ok {
+		return alias
+	}
+	return m.modelName
+}
+
+// MorphTo define la relacion polimorfica inversa, el modelo tiene las columnas <name>_type y <name>_id
+// ejemplo un comentario que pertenece a un post o a un video: MorphTo("commentable")
+// los tipos de <name>_type deben estar registrados con MorphMap
+func (m *Model) MorphTo(name string) *Relation {
+	return m.addRelation(&Relation{
+		Name:      name,
+		Type:      MorphToRelation,
+		MorphType: name + "_type",
+		MorphID:   name + "_id",
+	})
+}
+
+// MorphOne define una relacion polimorfica uno a uno, la tabla relacionada tiene las columnas <morphName>_type y <morphName>_id
+// MorphOne("image", "image", "imageable")
+func (m *Model) MorphOne(name string, related string, morphName string) *Relation {
+	return m.addRelation(&Relation{
+		Name:      name,
+		Type:      MorphOneRelation,
+		Related:   formatter.ToTableName(related),
+		MorphType: morphName + "_type",
+		MorphID:   morphName + "_id",
+	})
+}
+
+// MorphMany define una relacion polimorfica uno a muchos, la tabla relacionada tiene las columnas <morphName>_type y <morphName>_id
+// MorphMany("comments", "comment", "commentable")
+func (m *Model) MorphMany(name string, related string, morphName string) *Relation {
+	return m.addRelation(&Relation{
+		Name:      name,
+		Type:      MorphManyRelation,
+		Related:   formatter.ToTableName(related),
+		MorphType: morphName + "_type",
+		MorphID:   morphName + "_id",
+	})
+}
+
+// MorphToMany define una relacion polimorfica muchos a muchos usando una tabla pivote
+// la tabla pivote tiene las columnas <morphName>_type, <morphName>_id y la clave foranea de la tabla relacionada
+// si no se especifica la tabla pivote se usa el plural de morphName: MorphToMany("tags", "tag", "taggable", "") usa taggables
+func (m *Model) MorphToMany(name string, related string, morphName string, pivot string) *Relation {
+	r := &Relation{
+		Name:      name,
+		Type:      MorphToManyRelation,
+		Related:   formatter.ToTableName(related),
+		Pivot:     formatter.ToTableName(morphName),
+		MorphType: morphName + "_type",
+		MorphID:   morphName + "_id",
+	}
+	if pivot != "" {
+		r.Pivot = formatter.ToTableName(pivot)
+	}
+	return m.addRelation(r)
+}
+
+// resolveMorph resuelve las claves de las relaciones polimorficas
+func (r *Relation) resolveMorph(parent *Model) *Relation {
+	rr := *r
+	switch r.Type {
+	case MorphOneRelation, MorphManyRelation:
+		rr.LocalKey = firstNonEmpty(r.LocalKey, parent.PrimaryKey())
+		rr.MorphValue = parent.MorphType()
+
+	case MorphToManyRelation:
+		related := parent.newRelated(r.Related)
+		relatedColumn, relatedReference := foreignKeyTo(parent.driver(), r.Pivot, r.Related)
+		rr.LocalKey = firstNonEmpty(r.LocalKey, parent.PrimaryKey())
+		rr.RelatedPivotKey = firstNonEmpty(r.RelatedPivotKey, relatedColumn, related.modelName+"_id")
+		rr.RelatedKey = firstNonEmpty(r.RelatedKey, relatedReference, related.PrimaryKey())
+		rr.MorphValue = parent.MorphType()
+	}
+	return &rr
+}
+
+// isMorph indica si la relacion es polimorfica
+func (r *Relation) isMorph() bool {
+	switch r.Type {
+	case MorphToRelation, MorphOneRelation, MorphManyRelation, MorphToManyRelation:
+		return true
+	}
+	return false
+}
+
+// loadMorphTo carga una relacion MorphTo agrupando los registros por tipo
+// se hace una consulta por cada tipo con WHERE IN, tanto en sql como en mongodb
+// ya que en mongodb $lookup no permite que la coleccion cambie por documento
+func (m *Model) loadMorphTo(r *Relation, nested []string) error {
+	types := make([]string, 0)
+	ids := make(map[string][]map[string]any)
+	for _, row := range m.Data {
+		morphType := keyString(row[r.MorphType])
+		if morphType == "" {
+			continue
+		}
+		if _, ok := ids[morphType]; !ok {
+			types = append(types, morphType)
+		}
+		ids[morphType] = append(ids[morphType], row)
+	}
+
+	results := make(map[string]map[string][]map[string]any, len(types))
+	for _, morphType := range types {
+		table, err := morphTable(morphType)
+		if err != nil {
+			return fmt.Errorf("error al cargar la relación '%s': %w", r.Name, err)
+		}
+		related := m.newRelated(table).With(nested...)
+		pk := related.PrimaryKey()
+		if err := related.WhereIn(pk, pluck(ids[morphType], r.MorphID)).Get(); err != nil {
+			return fmt.Errorf("error al cargar la relación '%s' de tipo '%s': %w", r.Name, morphType, err)
+		}
+		results[morphType] = groupBy(related.Data, pk)
+	}
+
+	for _, row := range m.Data {
+		groups, ok := results[keyString(row[r.MorphType])]
+		if !ok {
+			row[r.Name] = nil
+			continue
+		}
+		row[r.Name] = firstOrNil(groups[keyString(row[r.MorphID])])
+	}
+	return nil
+}
+
+// loadMorphSQL carga las relaciones MorphOne, MorphMany y MorphToMany en sql
+func (m *Model) loadMorphSQL(r *Relation, nested []string) error {
+	switch r.Type {
+	case MorphOneRelation, MorphManyRelation:
+		related := m.newRelated(r.Related).With(nested...)
+		related.Where(r.MorphType, r.MorphValue).WhereIn(r.MorphID, pluck(m.Data, r.LocalKey))
+		if err := related.Get(); err != nil {
+			return err
+		}
+		groups := groupBy(related.Data, r.MorphID)
+		for _, row := range m.Data {
+			matches := groups[keyString(row[r.LocalKey])]
+			if r.Type == MorphOneRelation {
+				row[r.Name] = firstOrNil(matches)
+			} else {
+				row[r.Name] = nonNil(matches)
+			}
+		}
+
+	case MorphToManyRelation:
+		pivot := m.newRelated(r.Pivot)
+		pivot.Select(append([]string{r.MorphType, r.MorphID, r.RelatedPivotKey}, r.PivotColumns...)...)
+		pivot.Where(r.MorphType, r.MorphValue).WhereIn(r.MorphID, pluck(m.Data, r.LocalKey))
+		if err := pivot.Get(); err != nil {
+			return err
+		}
+		related := m.newRelated(r.Related).With(nested...)
+		if err := related.WhereIn(r.RelatedKey, pluck(pivot.Data, r.RelatedPivotKey)).Get(); err != nil {
+			return err
+		}
+		relatedGroups := groupBy(related.Data, r.RelatedKey)
+		pivotGroups := groupBy(pivot.Data, r.MorphID)
+		for _, row := range m.Data {
+			items := make([]map[string]any, 0)
+			for _, p := range pivotGroups[keyString(row[r.LocalKey])] {
+				for _, item := range relatedGroups[keyString(p[r.RelatedPivotKey])] {
+					item = maps.Clone(item)
+					item["pivot"] = p
+					items = append(items, item)
+				}
+			}
+			row[r.Name] = items
+		}
+
+	default:
+		return fmt.Errorf("tipo de relación '%s' no soportado", r.Type)
+	}
+	return nil
+}
+
+// morphLookupStages construye las etapas $lookup de MorphOne, MorphMany y MorphToMany
+// el tipo se filtra con un $match dentro del pipeline del $lookup
+func morphLookupStages(m *Model, r *Relation, nested []string) (bson.A, error) {
+	pipeline, err := nestedLookups(m, r.Related, nested)
+	if err != nil {
+		return nil, err
+	}
+
+	switch r.Type {
+	case MorphOneRelation, MorphManyRelation:
+		morphPipeline := append(bson.A{bson.M{"$match": bson.M{r.MorphType: r.MorphValue}}}, pipeline...)
+		stages := bson.A{lookup(r.Related, r.LocalKey, r.MorphID, r.Name, morphPipeline)}
+		if r.Type == MorphOneRelation {
+			stages = append(stages, firstElement(r.Name))
+		}
+		return stages, nil
+
+	case MorphToManyRelation:
+		pivot := bson.M{r.MorphType: "$" + r.MorphType, r.MorphID: "$" + r.MorphID, r.RelatedPivotKey: "$" + r.RelatedPivotKey}
+		for _, col := range r.PivotColumns {
+			pivot[col] = "$" + col
+		}
+		pivotPipeline := bson.A{
+			bson.M{"$match": bson.M{r.MorphType: r.MorphValue}},
+			lookup(r.Related, r.RelatedPivotKey, r.RelatedKey, "__related", pipeline),
+			bson.M{"$unwind": "$__related"},
+			bson.M{"$replaceRoot": bson.M{"newRoot": bson.M{"$mergeObjects": bson.A{"$__related", bson.M{"pivot": pivot}}}}},
+		}
+		return bson.A{lookup(r.Pivot, r.LocalKey, r.MorphID, r.Name, pivotPipeline)}, nil
+	}
+	return nil, fmt.Errorf("tipo de relación '%s' no soportado en $lookup", r.Type)
+}
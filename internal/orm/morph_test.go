@@ -0,0 +1,69 @@
+package orm
+
+import (
+	"testing"
+
+	"github.com/donbarrigon/new-project/internal/database/migration"
+)
+
+func TestMorphTo(t *testing.T) {
+	setupMemory(t, postTable(), noteTable(), migration.NewTable("morph_comment",
+		migration.BigIncrements(),
+		migration.String("body"),
+		migration.String("commentable_type"),
+		migration.UInt64("commentable_id"),
+	))
+	// notes tiene tabla en el schema pero no esta registrada, no se debe poder cargar
+	MorphMap(map[string]string{"morph_post": "post"})
+	t.Cleanup(func() {
+		morphMap.Lock()
+		delete(morphMap.aliases, "morph_post")
+		delete(morphMap.tables, "posts")
+		morphMap.Unlock()
+	})
+
+	newComment := func() *Model {
+		m := &Model{}
+		m.Table("morph_comment")
+		m.MorphTo("commentable")
+		return m
+	}
+
+	tests := []struct {
+		name      string
+		morphType string
+		wantErr   bool
+	}{
+		{name: "tipo registrado", morphType: "morph_post"},
+		{name: "tabla del schema sin registrar", morphType: "note", wantErr: true},
+		{name: "nombre de tabla sin alias", morphType: "post", wantErr: true},
+		{name: "tipo invalido", morphType: "posts;", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ResetMemory()
+			seedPosts(t, 10)
+			if err := newComment().Create(map[string]any{"body": "hola", "commentable_type": tt.morphType, "commentable_id": 1}); err != nil {
+				t.Fatal(err)
+			}
+			q := newComment().With("commentable")
+			err := q.Get()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, se esperaba error: %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			related, _ := q.Data[0]["commentable"].(map[string]any)
+			if related["title"] != "post 1" {
+				t.Errorf("commentable = %v, se esperaba el post 1", q.Data[0]["commentable"])
+			}
+		})
+	}
+
+	t.Run("morph type", func(t *testing.T) {
+		if got := newPost().MorphType(); got != "morph_post" {
+			t.Errorf("MorphType = %s, se esperaba morph_post", got)
+		}
+	})
+}
@@ -1,102 +1,36 @@
 package orm
 
 import (
-	"context"
-	"database/sql"
-	"fmt"
 	"log"
-	"os"
-	"time"
 
 	_ "github.com/go-sql-driver/mysql"
-	"github.com/joho/godotenv"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	_ "github.com/lib/pq"
 )
 
-// db es la instancia global de la base de datos
-var db *sql.DB
-
-// MongoDBClient representa la conexión a la base de datos
-var dbc *mongo.Client
-var databaseName string
-var dbDriver string
-
-// Connect establece una conexión con la base de datos
+// Connect abre las conexiones con las bases de datos configuradas en las variables de entorno
+// la conexion default usa DB_DRIVER, DB_HOST, DB_PORT, DB_USER, DB_PASSWORD, DB_NAME, DB_CHARSET, DB_COLLATION y MONGO_URI
+// las conexiones con nombre se listan en DB_CONNECTIONS=analytics,legacy y usan las mismas variables con su nombre:
+// DB_ANALYTICS_DRIVER, DB_ANALYTICS_HOST, DB_ANALYTICS_MONGO_URI, etc.
+// el pool se configura con DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS, DB_CONN_MAX_LIFETIME, DB_CONN_MAX_IDLE_TIME
+// y en mongodb con DB_MONGO_MAX_POOL_SIZE, DB_MONGO_MIN_POOL_SIZE y DB_MONGO_MAX_CONN_IDLE_TIME
+// en sql las replicas de lectura se listan en DB_READ_HOSTS y el primario en DB_WRITE_HOST, con DB_STICKY=true
+// las lecturas van al primario despues de una escritura en el mismo contexto (ver Sticky)
+// en mongodb las lecturas en replicas se configuran con readPreference en la uri
+// el log de consultas lentas usa DB_SLOW_QUERY_THRESHOLD y DB_SLOW_QUERY_EXPLAIN y la deteccion de N+1 DB_N_PLUS_ONE_THRESHOLD
 func Connect() {
+	for _, name := range connectionNames() {
+		conn, err := openConnection(name)
+		if err != nil {
+			log.Fatalf("Error al conectar con la base de datos '%s': %v", name, err)
+		}
 
-	dbDriver = os.Getenv("DB_DRIVER")
-	if dbDriver == "" {
-		dbDriver = "mongodb"
-	}
-
-	if dbDriver == "mongodb" {
-		connectMongoDB()
-		return
-	}
-
-	dbHost := os.Getenv("DB_HOST")
-	dbPort := os.Getenv("DB_PORT")
-	dbUser := os.Getenv("DB_USER")
-	dbPassword := os.Getenv("DB_PASSWORD")
-	dbName := os.Getenv("DB_NAME")
-	dbCharset := os.Getenv("DB_CHARSET")
-	dbCollation := os.Getenv("DB_COLLATION")
-
-	// Construye la cadena de conexión
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=%s&collation=%s&parseTime=True&loc=Local",
-		dbUser, dbPassword, dbHost, dbPort, dbName, dbCharset, dbCollation)
-
-	// Abre la conexión con la base de datos
-	var err error
-	db, err = sql.Open("mysql", dsn)
-	if err != nil {
-		log.Fatalf("Error al abrir la conexión con la base de datos: %v", err)
-	}
-
-	// Verifica que la conexión sea válida
-	if err := db.Ping(); err != nil {
-		log.Fatalf("Error al verificar la conexión con la base de datos: %v", err)
-	}
-
-	log.Println("Conexión con la base de datos establecida.")
-}
+		connections.Lock()
+		connections.m[name] = conn
+		connections.Unlock()
 
-// ConnectDB inicializa la conexión con MongoDB
-func connectMongoDB() *mongo.Client {
-	// Cargar variables de entorno
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error cargando el archivo .env")
+		log.Printf("Conexión '%s' con la base de datos (%s) establecida.", name, conn.driver)
 	}
-
-	// Obtener URI y nombre de la base de datos desde .env
-	mongoURI := os.Getenv("MONGO_URI")
-
-	// Configurar opciones de conexión
-	clientOptions := options.Client().ApplyURI(mongoURI)
-
-	// Crear contexto con timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	// Conectar a MongoDB
-	client, err := mongo.Connect(ctx, clientOptions)
-	if err != nil {
-		log.Fatal("Error conectando a MongoDB:", err)
-	}
-
-	// Verificar la conexión
-	err = client.Ping(ctx, nil)
-	if err != nil {
-		log.Fatal("No se pudo conectar a MongoDB:", err)
-	}
-
-	dbc = client
-	databaseName = os.Getenv("DB_NAME")
-
-	fmt.Println("Conectado a MongoDB", dbc)
-	return client
+	configureQueryLog()
 }
 
 // Find busca un registro en la base de datos basado en el ID
@@ -0,0 +1,73 @@
+// Package ormtest tiene las funciones para probar los handlers con el driver memory del orm
+//
+//	func TestStore(t *testing.T) {
+//		ormtest.Setup(t)
+//		// ... llamar el handler
+//		ormtest.AssertDatabaseHas(t, "users", map[string]any{"email": "ana@example.com"})
+//	}
+package ormtest
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/donbarrigon/new-project/internal/database/migration/tables"
+	"github.com/donbarrigon/new-project/internal/orm"
+)
+
+// Setup registra el schema de las migraciones y reemplaza la conexion default por una en memoria
+// al terminar la prueba se eliminan los registros
+func Setup(t testing.TB) {
+	t.Helper()
+	tables.NewMigration()
+	orm.ConnectMemory()
+	t.Cleanup(orm.ResetMemory)
+}
+
+// AssertDatabaseHas falla la prueba si la tabla no tiene un registro con los valores de attrs
+func AssertDatabaseHas(t testing.TB, table string, attrs map[string]any) {
+	t.Helper()
+	count, err := orm.MemoryCount(table, attrs)
+	if err != nil {
+		t.Fatalf("error al consultar la tabla '%s': %v", table, err)
+	}
+	if count == 0 {
+		t.Errorf("la tabla '%s' no tiene un registro con %s", table, formatAttrs(attrs))
+	}
+}
+
+// AssertDatabaseMissing falla la prueba si la tabla tiene un registro con los valores de attrs
+func AssertDatabaseMissing(t testing.TB, table string, attrs map[string]any) {
+	t.Helper()
+	count, err := orm.MemoryCount(table, attrs)
+	if err != nil {
+		t.Fatalf("error al consultar la tabla '%s': %v", table, err)
+	}
+	if count > 0 {
+		t.Errorf("la tabla '%s' tiene %d registros con %s", table, count, formatAttrs(attrs))
+	}
+}
+
+// AssertDatabaseCount falla la prueba si la tabla no tiene exactamente n registros
+func AssertDatabaseCount(t testing.TB, table string, n int) {
+	t.Helper()
+	count, err := orm.MemoryCount(table, nil)
+	if err != nil {
+		t.Fatalf("error al consultar la tabla '%s': %v", table, err)
+	}
+	if count != n {
+		t.Errorf("la tabla '%s' tiene %d registros y se esperaban %d", table, count, n)
+	}
+}
+
+// formatAttrs retorna los valores ordenados por columna para los mensajes de error
+func formatAttrs(attrs map[string]any) string {
+	parts := make([]string, 0, len(attrs))
+	for _, column := range slices.Sorted(maps.Keys(attrs)) {
+		parts = append(parts, fmt.Sprintf("%s=%v", column, attrs[column]))
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
@@ -0,0 +1,80 @@
+package ormtest
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/donbarrigon/new-project/internal/orm"
+	"github.com/donbarrigon/new-project/internal/pkg/user"
+)
+
+// recorder guarda los mensajes de las aserciones en lugar de fallar la prueba
+type recorder struct {
+	testing.TB
+	messages []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.messages = append(r.messages, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...any) {
+	r.messages = append(r.messages, fmt.Sprintf(format, args...))
+}
+
+func TestAssertions(t *testing.T) {
+	Setup(t)
+	for _, name := range []string{"Ana", "Bob"} {
+		if err := user.NewModel().Create(map[string]any{"name": name, "email": name + "@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		assert func(t testing.TB)
+		want   []string
+	}{
+		{name: "has", assert: func(t testing.TB) {
+			AssertDatabaseHas(t, "users", map[string]any{"name": "Ana", "email": "Ana@example.com"})
+		}},
+		{name: "has falla", assert: func(t testing.TB) {
+			AssertDatabaseHas(t, "users", map[string]any{"name": "Eva", "deleted_at": nil})
+		}, want: []string{"la tabla 'users' no tiene un registro con {deleted_at=<nil>, name=Eva}"}},
+		{name: "missing", assert: func(t testing.TB) {
+			AssertDatabaseMissing(t, "users", map[string]any{"name": "Eva"})
+		}},
+		{name: "missing falla", assert: func(t testing.TB) {
+			AssertDatabaseMissing(t, "users", map[string]any{"deleted_at": nil})
+		}, want: []string{"la tabla 'users' tiene 2 registros con {deleted_at=<nil>}"}},
+		{name: "count", assert: func(t testing.TB) {
+			AssertDatabaseCount(t, "users", 2)
+			AssertDatabaseCount(t, "posts", 0)
+		}},
+		{name: "count falla", assert: func(t testing.TB) {
+			AssertDatabaseCount(t, "users", 3)
+		}, want: []string{"la tabla 'users' tiene 2 registros y se esperaban 3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{TB: t}
+			tt.assert(r)
+			if !slices.Equal(r.messages, tt.want) {
+				t.Errorf("mensajes = %q, se esperaba %q", r.messages, tt.want)
+			}
+		})
+	}
+
+	t.Run("reset", func(t *testing.T) {
+		orm.ResetMemory()
+		r := &recorder{TB: t}
+		AssertDatabaseCount(r, "users", 0)
+		AssertDatabaseCount(r, "audits", 0)
+		if len(r.messages) > 0 {
+			t.Errorf("ResetMemory no elimino los registros: %q", r.messages)
+		}
+	})
+}
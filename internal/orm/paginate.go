@@ -0,0 +1,98 @@
+package orm
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// Pagination es el resultado de una consulta paginada
+type Pagination struct {
+	Data         any     `json:"data"`           // registros de la pagina sin las columnas ocultas, []map[string]any o []T en Repository
+	Total        int64   `json:"total"`          // total de registros de la consulta
+	PerPage      int     `json:"per_page"`       // registros por pagina
+	CurrentPage  int     `json:"current_page"`   // pagina actual
+	LastPage     int     `json:"last_page"`      // ultima pagina
+	From         int     `json:"from"`           // posicion del primer registro de la pagina, 0 si la pagina esta vacia
+	To           int     `json:"to"`             // posicion del ultimo registro de la pagina, 0 si la pagina esta vacia
+	Path         string  `json:"path"`           // url sin parametros, se llena con SetURL
+	FirstPageURL string  `json:"first_page_url"` // se llena con SetURL
+	LastPageURL  string  `json:"last_page_url"`  // se llena con SetURL
+	NextPageURL  *string `json:"next_page_url"`  // nil en la ultima pagina
+	PrevPageURL  *string `json:"prev_page_url"`  // nil en la primera pagina
+}
+
+// Paginate ejecuta la consulta por paginas, los registros de la pagina quedan en `m.Data`
+// page empieza en 1
+func (m *Model) Paginate(page int, perPage int) (*Pagination, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		return nil, fmt.Errorf("la cantidad de registros por página debe ser mayor a 0")
+	}
+
+	total, err := m.clone().Count()
+	if err != nil {
+		return nil, err
+	}
+
+	m.Limit(perPage).Offset((page - 1) * perPage)
+	if err := m.Get(); err != nil {
+		return nil, err
+	}
+
+	lastPage := int((total + int64(perPage) - 1) / int64(perPage))
+	if lastPage < 1 {
+		lastPage = 1
+	}
+
+	pagination := &Pagination{
+		Data:        m.ToMaps(),
+		Total:       total,
+		PerPage:     perPage,
+		CurrentPage: page,
+		LastPage:    lastPage,
+	}
+	if len(m.Data) > 0 {
+		pagination.From = (page-1)*perPage + 1
+		pagination.To = pagination.From + len(m.Data) - 1
+	}
+	return pagination, nil
+}
+
+// SetURL construye los links de la paginacion a partir de la url del request
+// se conservan los demas parametros de la url y solo se cambia page
+//
+//	pagination.SetURL(ctx.Request.URL)
+func (p *Pagination) SetURL(u *url.URL) *Pagination {
+	p.Path = pathURL(u)
+	p.FirstPageURL = withQuery(u, "page", "1")
+	p.LastPageURL = withQuery(u, "page", strconv.Itoa(p.LastPage))
+	p.NextPageURL, p.PrevPageURL = nil, nil
+	if p.CurrentPage < p.LastPage {
+		next := withQuery(u, "page", strconv.Itoa(p.CurrentPage+1))
+		p.NextPageURL = &next
+	}
+	if p.CurrentPage > 1 {
+		prev := withQuery(u, "page", strconv.Itoa(p.CurrentPage-1))
+		p.PrevPageURL = &prev
+	}
+	return p
+}
+
+// pathURL retorna la url sin parametros
+func pathURL(u *url.URL) string {
+	c := *u
+	c.RawQuery, c.Fragment = "", ""
+	return c.String()
+}
+
+// withQuery retorna la url con el parametro key cambiado por value
+func withQuery(u *url.URL, key string, value string) string {
+	c := *u
+	query := c.Query()
+	query.Set(key, value)
+	c.RawQuery, c.Fragment = query.Encode(), ""
+	return c.String()
+}
@@ -0,0 +1,118 @@
+package orm
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	setupMemory(t, postTable())
+	seedPosts(t, 1, 2, 3, 4, 5, 6, 7)
+
+	tests := []struct {
+		name     string
+		page     int
+		perPage  int
+		want     []int64
+		total    int64
+		lastPage int
+		from, to int
+	}{
+		{name: "primera", page: 1, perPage: 3, want: []int64{1, 2, 3}, total: 7, lastPage: 3, from: 1, to: 3},
+		{name: "ultima incompleta", page: 3, perPage: 3, want: []int64{7}, total: 7, lastPage: 3, from: 7, to: 7},
+		{name: "fuera de rango", page: 5, perPage: 3, want: []int64{}, total: 7, lastPage: 3},
+		{name: "una sola", page: 1, perPage: 10, want: []int64{1, 2, 3, 4, 5, 6, 7}, total: 7, lastPage: 1, from: 1, to: 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newPost().OrderBy("id")
+			p, err := q.Paginate(tt.page, tt.perPage)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(q); !slices.Equal(got, tt.want) {
+				t.Errorf("ids = %v, se esperaba %v", got, tt.want)
+			}
+			if p.Total != tt.total || p.LastPage != tt.lastPage || p.From != tt.from || p.To != tt.to {
+				t.Errorf("total %d, last %d, from %d, to %d; se esperaba %d, %d, %d, %d", p.Total, p.LastPage, p.From, p.To, tt.total, tt.lastPage, tt.from, tt.to)
+			}
+		})
+	}
+}
+
+func TestCursorPaginate(t *testing.T) {
+	setupMemory(t, postTable())
+	// votos repetidos para que la clave primaria desempate el orden
+	seedPosts(t, 30, 10, 20, 10, 30, 20, 10)
+
+	// las paginas por votos descendentes, la clave primaria desempata en la misma direccion
+	pages := [][]int64{{5, 1, 6}, {3, 7, 4}, {2}}
+
+	var cursors []string
+	cursor := ""
+	for i, want := range pages {
+		q := newPost().OrderBy("votes", "desc")
+		p, err := q.CursorPaginate(3, cursor)
+		if err != nil {
+			t.Fatalf("pagina %d: %v", i+1, err)
+		}
+		if got := ids(q); !slices.Equal(got, want) {
+			t.Fatalf("pagina %d: ids = %v, se esperaba %v", i+1, got, want)
+		}
+		if (p.NextCursor == nil) != (i == len(pages)-1) {
+			t.Fatalf("pagina %d: next_cursor = %v", i+1, p.NextCursor)
+		}
+		if (p.PrevCursor == nil) != (i == 0) {
+			t.Fatalf("pagina %d: prev_cursor = %v", i+1, p.PrevCursor)
+		}
+		if p.PrevCursor != nil {
+			cursors = append(cursors, *p.PrevCursor)
+		}
+		if p.NextCursor != nil {
+			cursor = *p.NextCursor
+		}
+	}
+
+	// los cursores hacia atras regresan a las paginas anteriores
+	for i, prev := range cursors {
+		q := newPost().OrderBy("votes", "desc")
+		if _, err := q.CursorPaginate(3, prev); err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(q); !slices.Equal(got, pages[i]) {
+			t.Errorf("pagina anterior %d: ids = %v, se esperaba %v", i+1, got, pages[i])
+		}
+	}
+
+	t.Run("cursor de otra consulta", func(t *testing.T) {
+		if _, err := newPost().OrderBy("title").CursorPaginate(3, cursor); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("error = %v, se esperaba ErrInvalidCursor con un cursor de otro orden", err)
+		}
+		if _, err := newPost().OrderBy("votes", "desc").CursorPaginate(3, cursor+"x"); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("error = %v, se esperaba ErrInvalidCursor con un cursor modificado", err)
+		}
+	})
+}
+
+func TestCursorPaginateOrWhere(t *testing.T) {
+	setupMemory(t, postTable())
+	seedPosts(t, 30, 10, 20, 10, 30, 20, 10)
+
+	// la condicion del cursor se une con AND a todo el OR: (votes = 10 OR votes = 30) AND id > ?
+	pages := [][]int64{{1, 2}, {4, 5}, {7}}
+	cursor := ""
+	for i, want := range pages {
+		q := newPost().Where("votes", 10).OrWhere("votes", 30).OrderBy("id")
+		p, err := q.CursorPaginate(2, cursor)
+		if err != nil {
+			t.Fatalf("pagina %d: %v", i+1, err)
+		}
+		if got := ids(q); !slices.Equal(got, want) {
+			t.Fatalf("pagina %d: ids = %v, se esperaba %v", i+1, got, want)
+		}
+		if p.NextCursor != nil {
+			cursor = *p.NextCursor
+		}
+	}
+}
@@ -0,0 +1,460 @@
+package orm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/donbarrigon/new-project/internal/cache"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Pipeline construye un pipeline de agregacion de mongodb sobre la coleccion del modelo
+// los campos se validan contra la migracion y despues de $project, $group y $facet contra los campos que dejan esas etapas
+// el primer error queda en el pipeline y se retorna al ejecutarlo, igual que en el modelo
+type Pipeline struct {
+	model       *Model
+	stages      mongo.Pipeline
+	fields      map[string]bool // campos que tienen los documentos en la etapa actual, nil si no se conocen
+	facetFields map[string]bool // campos que hay antes del $facet, de ellos parten los facets que se agregan a la etapa
+	err         error
+}
+
+// Accumulator es un acumulador de $group: AccSum("total", "price")
+type Accumulator struct {
+	alias    string
+	operator string
+	field    string
+}
+
+// AccSum suma el campo en cada grupo
+func AccSum(alias string, field string) Accumulator {
+	return Accumulator{alias, "$sum", field}
+}
+
+// AccAvg promedia el campo en cada grupo
+func AccAvg(alias string, field string) Accumulator {
+	return Accumulator{alias, "$avg", field}
+}
+
+// AccMin retorna el menor valor del campo en cada grupo
+func AccMin(alias string, field string) Accumulator {
+	return Accumulator{alias, "$min", field}
+}
+
+// AccMax retorna el mayor valor del campo en cada grupo
+func AccMax(alias string, field string) Accumulator {
+	return Accumulator{alias, "$max", field}
+}
+
+// AccFirst retorna el valor del campo del primer documento de cada grupo
+func AccFirst(alias string, field string) Accumulator {
+	return Accumulator{alias, "$first", field}
+}
+
+// AccLast retorna el valor del campo del ultimo documento de cada grupo
+func AccLast(alias string, field string) Accumulator {
+	return Accumulator{alias, "$last", field}
+}
+
+// AccPush retorna un arreglo con los valores del campo de cada grupo
+func AccPush(alias string, field string) Accumulator {
+	return Accumulator{alias, "$push", field}
+}
+
+// AccAddToSet retorna un arreglo con los valores distintos del campo de cada grupo
+func AccAddToSet(alias string, field string) Accumulator {
+	return Accumulator{alias, "$addToSet", field}
+}
+
+// AccCount cuenta los documentos de cada grupo
+func AccCount(alias string) Accumulator {
+	return Accumulator{alias, "$sum", ""}
+}
+
+// Pipeline crea un pipeline de agregacion sobre la coleccion del modelo, solo es compatible con mongodb
+// las condiciones del modelo, los global scopes y el tenant se agregan como el primer $match
+//
+//	orders := order.NewModel()
+//	err := orders.WithContext(ctx).Pipeline().
+//		Match("status", "paid").
+//		Group([]string{"customer_id"}, orm.AccSum("total", "amount"), orm.AccCount("orders")).
+//		Sort("total", "desc").
+//		Limit(10).
+//		Get(&report)
+func (m *Model) Pipeline() *Pipeline {
+	p := &Pipeline{model: m, err: m.err}
+	if m.hasMigration {
+		p.fields = map[string]bool{"_id": true}
+		for _, col := range m.table.Columns {
+			p.fields[col.Name] = true
+		}
+	}
+	return p
+}
+
+// Match agrega una etapa $match con la condicion, recibe los mismos argumentos que Where
+//
+//	Match("age", ">=", 18).Match("role", "in", []string{"admin", "editor"})
+func (p *Pipeline) Match(field string, args ...any) *Pipeline {
+	return p.MatchGroup(func(q *Model) { q.Where(field, args...) })
+}
+
+// MatchGroup agrega una etapa $match con las condiciones que agregue fn, se usa para condiciones con OR
+//
+//	MatchGroup(func(q *orm.Model) { q.Where("role", "admin").OrWhere("owner", true) })
+func (p *Pipeline) MatchGroup(fn func(q *Model)) *Pipeline {
+	if p.err != nil {
+		return p
+	}
+	q := &Model{tableName: p.model.tableName, connName: p.model.connName}
+	fn(q)
+	if q.err != nil {
+		p.err = q.err
+		return p
+	}
+	if !p.checkWheres(q.wheres) {
+		return p
+	}
+	if len(q.wheres) > 0 {
+		p.stages = append(p.stages, bson.D{{Key: "$match", Value: mongoConditions(q.wheres)}})
+	}
+	return p
+}
+
+// Project agrega una etapa $project que deja solo los campos, el _id se incluye siempre
+func (p *Pipeline) Project(fields ...string) *Pipeline {
+	if p.err != nil {
+		return p
+	}
+	project := bson.D{}
+	next := map[string]bool{"_id": true}
+	for _, field := range fields {
+		if !p.checkField(field) {
+			return p
+		}
+		project = append(project, bson.E{Key: field, Value: 1})
+		next[rootField(field)] = true
+	}
+	p.stages = append(p.stages, bson.D{{Key: "$project", Value: project}})
+	p.fields = next
+	return p
+}
+
+// Group agrega una etapa $group por los campos de by con los acumuladores
+// los campos del grupo quedan en el nivel principal del documento igual que en GroupBy, sin by se agrupa todo en un documento
+func (p *Pipeline) Group(by []string, accumulators ...Accumulator) *Pipeline {
+	if p.err != nil {
+		return p
+	}
+	var id any
+	if len(by) > 0 {
+		groupID := bson.D{}
+		for _, field := range by {
+			if !p.checkField(field) {
+				return p
+			}
+			groupID = append(groupID, bson.E{Key: strings.ReplaceAll(field, ".", "_"), Value: "$" + field})
+		}
+		id = groupID
+	}
+
+	group := bson.D{{Key: "_id", Value: id}}
+	project := bson.D{{Key: "_id", Value: 0}}
+	next := make(map[string]bool, len(by)+len(accumulators))
+	for _, field := range by {
+		key := strings.ReplaceAll(field, ".", "_")
+		project = append(project, bson.E{Key: key, Value: "$_id." + key})
+		next[key] = true
+	}
+	for _, acc := range accumulators {
+		if !isIdentifier(acc.alias) {
+			p.setError(fmt.Errorf("el alias '%s' no es válido", acc.alias))
+			return p
+		}
+		var value any = 1
+		if acc.field != "" {
+			if !p.checkField(acc.field) {
+				return p
+			}
+			value = "$" + acc.field
+		}
+		group = append(group, bson.E{Key: acc.alias, Value: bson.M{acc.operator: value}})
+		project = append(project, bson.E{Key: acc.alias, Value: 1})
+		next[acc.alias] = true
+	}
+
+	p.stages = append(p.stages,
+		bson.D{{Key: "$group", Value: group}},
+		bson.D{{Key: "$project", Value: project}},
+	)
+	p.fields = next
+	return p
+}
+
+// Sort agrega el campo a la etapa $sort, si la etapa anterior es un $sort se agrega a esa
+// direction es opcional asc o desc, por defecto asc
+func (p *Pipeline) Sort(field string, direction ...string) *Pipeline {
+	if !p.checkField(field) {
+		return p
+	}
+	dir := 1
+	if len(direction) > 0 && strings.ToUpper(direction[0]) == "DESC" {
+		dir = -1
+	}
+	p.mergeStage("$sort", bson.E{Key: field, Value: dir})
+	return p
+}
+
+// Limit agrega una etapa $limit
+func (p *Pipeline) Limit(limit int) *Pipeline {
+	if limit <= 0 {
+		p.setError(fmt.Errorf("el limite del pipeline debe ser mayor a cero"))
+		return p
+	}
+	p.stages = append(p.stages, bson.D{{Key: "$limit", Value: int64(limit)}})
+	return p
+}
+
+// Skip agrega una etapa $skip
+func (p *Pipeline) Skip(skip int) *Pipeline {
+	if skip < 0 {
+		p.setError(fmt.Errorf("el skip del pipeline no puede ser negativo"))
+		return p
+	}
+	p.stages = append(p.stages, bson.D{{Key: "$skip", Value: int64(skip)}})
+	return p
+}
+
+// Lookup agrega una etapa $lookup que carga en as los documentos de from donde foreignField es igual a localField
+// foreignField se valida contra la migracion de from si existe
+func (p *Pipeline) Lookup(from string, localField string, foreignField string, as string) *Pipeline {
+	if p.err != nil {
+		return p
+	}
+	if !p.checkField(localField) {
+		return p
+	}
+	if !isIdentifier(from) || !isIdentifier(as) {
+		p.setError(fmt.Errorf("la coleccion '%s' o el campo '%s' del lookup no son válidos", from, as))
+		return p
+	}
+	if t := cache.GetTable(from); t != nil && foreignField != "_id" && !tableHasColumn(t, rootField(foreignField)) {
+		p.setError(fmt.Errorf("la columna '%s' no existe en la tabla '%s'", foreignField, from))
+		return p
+	}
+	p.stages = append(p.stages, bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: from},
+		{Key: "localField", Value: localField},
+		{Key: "foreignField", Value: foreignField},
+		{Key: "as", Value: as},
+	}}})
+	if p.fields != nil {
+		p.fields[as] = true
+	}
+	return p
+}
+
+// Unwind agrega una etapa $unwind que crea un documento por cada elemento del arreglo field
+// con preserveEmpty se conservan los documentos con el arreglo vacio o nulo
+func (p *Pipeline) Unwind(field string, preserveEmpty ...bool) *Pipeline {
+	if !p.checkField(field) {
+		return p
+	}
+	unwind := bson.D{{Key: "path", Value: "$" + field}}
+	if len(preserveEmpty) > 0 && preserveEmpty[0] {
+		unwind = append(unwind, bson.E{Key: "preserveNullAndEmptyArrays", Value: true})
+	}
+	p.stages = append(p.stages, bson.D{{Key: "$unwind", Value: unwind}})
+	return p
+}
+
+// AddFields agrega el campo con la expresion de mongodb, si la etapa anterior es un $addFields se agrega a esa
+//
+//	AddFields("total", bson.M{"$multiply": bson.A{"$price", "$quantity"}})
+func (p *Pipeline) AddFields(field string, expression any) *Pipeline {
+	if p.err != nil {
+		return p
+	}
+	if !isIdentifier(rootField(field)) {
+		p.setError(fmt.Errorf("el nombre del campo '%s' no es válido", field))
+		return p
+	}
+	p.mergeStage("$addFields", bson.E{Key: field, Value: expression})
+	if p.fields != nil {
+		p.fields[rootField(field)] = true
+	}
+	return p
+}
+
+// Facet agrega un resultado de la etapa $facet construido por fn, si la etapa anterior es un $facet se agrega a esa
+// el documento resultante tiene un campo name con el arreglo de resultados de cada facet
+//
+//	Facet("by_status", func(f *orm.Pipeline) { f.Group([]string{"status"}, orm.AccCount("total")) }).
+//	Facet("latest", func(f *orm.Pipeline) { f.Sort("created_at", "desc").Limit(5) })
+func (p *Pipeline) Facet(name string, fn func(f *Pipeline)) *Pipeline {
+	if p.err != nil {
+		return p
+	}
+	if !isIdentifier(name) {
+		p.setError(fmt.Errorf("el nombre del facet '%s' no es válido", name))
+		return p
+	}
+
+	// cada facet parte de los documentos que hay antes del $facet
+	merge := p.lastStageIs("$facet")
+	if !merge {
+		p.facetFields = p.fields
+	}
+	f := &Pipeline{model: p.model, stages: mongo.Pipeline{}, fields: cloneFields(p.facetFields)}
+	fn(f)
+	if f.err != nil {
+		p.setError(f.err)
+		return p
+	}
+
+	p.mergeStage("$facet", bson.E{Key: name, Value: f.stages})
+	if !merge {
+		p.fields = map[string]bool{}
+	}
+	p.fields[name] = true
+	return p
+}
+
+// Stages retorna las etapas del pipeline sin el $match de las condiciones del modelo
+func (p *Pipeline) Stages() (mongo.Pipeline, error) {
+	return p.stages, p.err
+}
+
+// Get ejecuta el pipeline y guarda los documentos en `m.Data` del modelo
+// dest es opcional, si se pasa un puntero a un slice de structs o de maps los documentos tambien se copian ahi
+// los documentos no pasan por los casts del modelo porque las etapas pueden cambiar su forma
+func (p *Pipeline) Get(dest ...any) error {
+	if p.err != nil {
+		return p.err
+	}
+	m := p.model
+	switch m.driver() {
+	case "mongodb":
+	case "mysql", "postgresql":
+		return fmt.Errorf("el pipeline de agregación solo es compatible con mongodb")
+	default:
+		return m.conn().unsupported()
+	}
+	if err := m.applyScopes(); err != nil {
+		return err
+	}
+
+	pipeline := p.stages
+	if len(m.wheres) > 0 {
+		pipeline = append(mongo.Pipeline{{{Key: "$match", Value: m.mongoFilter()}}}, p.stages...)
+	}
+
+	data, err := remember(m, m.mongoQuery("aggregate"), []any{pipeline}, func() ([]map[string]any, error) {
+		ctx, cancel := m.context()
+		defer cancel()
+
+		start := time.Now()
+		cursor, err := m.collection().Aggregate(ctx, pipeline)
+		if err != nil {
+			m.record(ctx, start, m.mongoQuery("aggregate"), []any{pipeline}, 0, err)
+			return nil, dbError("error en la agregación de MongoDB", err)
+		}
+		defer cursor.Close(ctx)
+
+		data := make([]map[string]any, 0)
+		err = cursor.All(ctx, &data)
+		m.record(ctx, start, m.mongoQuery("aggregate"), []any{pipeline}, int64(len(data)), err)
+		if err != nil {
+			return nil, dbError("error al leer los documentos", err)
+		}
+		return data, nil
+	})
+	if err != nil {
+		return err
+	}
+	m.Data = data
+	m.single = false
+	m.syncOriginal()
+
+	if len(dest) > 0 {
+		return m.Hydrate(dest[0])
+	}
+	return nil
+}
+
+// checkField valida el nombre del campo y que exista en los documentos de la etapa actual si se conocen
+// los campos anidados se validan por su primer nivel: address.city se valida como address
+func (p *Pipeline) checkField(field string) bool {
+	if p.err != nil {
+		return false
+	}
+	root := rootField(field)
+	for _, part := range strings.Split(field, ".") {
+		if !isIdentifier(part) {
+			p.err = fmt.Errorf("el nombre del campo '%s' no es válido", field)
+			return false
+		}
+	}
+	if p.fields != nil && !p.fields[root] {
+		p.err = fmt.Errorf("el campo '%s' no existe en el pipeline de '%s'", field, p.model.tableName)
+		return false
+	}
+	return true
+}
+
+// checkWheres valida los campos de las condiciones y de sus grupos
+func (p *Pipeline) checkWheres(wheres []where) bool {
+	for _, w := range wheres {
+		if len(w.group) > 0 {
+			if !p.checkWheres(w.group) {
+				return false
+			}
+		} else if !p.checkField(w.column) {
+			return false
+		}
+	}
+	return true
+}
+
+// setError guarda el primer error del pipeline
+func (p *Pipeline) setError(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// lastStageIs indica si la ultima etapa del pipeline es el operador
+func (p *Pipeline) lastStageIs(operator string) bool {
+	return len(p.stages) > 0 && p.stages[len(p.stages)-1][0].Key == operator
+}
+
+// mergeStage agrega el elemento a la ultima etapa si es del operador, si no crea la etapa
+// se usa en $sort, $addFields y $facet donde varias llamadas seguidas forman una sola etapa
+func (p *Pipeline) mergeStage(operator string, elem bson.E) {
+	if p.lastStageIs(operator) {
+		last := p.stages[len(p.stages)-1]
+		last[0].Value = append(last[0].Value.(bson.D), elem)
+		return
+	}
+	p.stages = append(p.stages, bson.D{{Key: operator, Value: bson.D{elem}}})
+}
+
+// rootField retorna el primer nivel del campo: address.city es address
+func rootField(field string) string {
+	root, _, _ := strings.Cut(field, ".")
+	return root
+}
+
+// cloneFields copia los campos conocidos, nil si no se conocen
+func cloneFields(fields map[string]bool) map[string]bool {
+	if fields == nil {
+		return nil
+	}
+	clone := make(map[string]bool, len(fields))
+	for field := range fields {
+		clone[field] = true
+	}
+	return clone
+}
@@ -0,0 +1,91 @@
+package orm
+
+import (
+	"fmt"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestPipelineStages(t *testing.T) {
+	setupMemory(t, postTable(), noteTable())
+
+	tests := []struct {
+		name     string
+		pipeline func(p *Pipeline) *Pipeline
+		want     string
+		wantErr  string
+	}{
+		{name: "match sort limit skip", pipeline: func(p *Pipeline) *Pipeline {
+			return p.Match("votes", ">", 5).Sort("votes", "desc").Sort("title").Skip(10).Limit(5)
+		}, want: "[[{$match map[votes:map[$gt:5]]}] [{$sort [{votes -1} {title 1}]}] [{$skip 10}] [{$limit 5}]]"},
+		{name: "match group con or", pipeline: func(p *Pipeline) *Pipeline {
+			return p.MatchGroup(func(q *Model) { q.Where("votes", 1).OrWhere("published", true) })
+		}, want: "[[{$match map[$or:[map[$and:[map[votes:map[$eq:1]]]] map[$and:[map[published:map[$eq:true]]]]]]}]]"},
+		{name: "group deja los campos en el nivel principal", pipeline: func(p *Pipeline) *Pipeline {
+			return p.Group([]string{"published"}, AccSum("total", "votes"), AccCount("posts")).Sort("total", "desc")
+		}, want: "[[{$group [{_id [{published $published}]} {total map[$sum:$votes]} {posts map[$sum:1]}]}] " +
+			"[{$project [{_id 0} {published $_id.published} {total 1} {posts 1}]}] [{$sort [{total -1}]}]]"},
+		{name: "project", pipeline: func(p *Pipeline) *Pipeline {
+			return p.Project("title", "meta.tags").Sort("meta.tags")
+		}, want: "[[{$project [{title 1} {meta.tags 1}]}] [{$sort [{meta.tags 1}]}]]"},
+		{name: "lookup y unwind", pipeline: func(p *Pipeline) *Pipeline {
+			return p.Lookup("notes", "slug", "slug", "notes").Unwind("notes", true).Sort("notes.body")
+		}, want: "[[{$lookup [{from notes} {localField slug} {foreignField slug} {as notes}]}] " +
+			"[{$unwind [{path $notes} {preserveNullAndEmptyArrays true}]}] [{$sort [{notes.body 1}]}]]"},
+		{name: "add fields", pipeline: func(p *Pipeline) *Pipeline {
+			return p.AddFields("score", bson.M{"$multiply": bson.A{"$votes", 2}}).AddFields("rank", 1).Sort("score")
+		}, want: "[[{$addFields [{score map[$multiply:[$votes 2]]} {rank 1}]}] [{$sort [{score 1}]}]]"},
+		{name: "facet", pipeline: func(p *Pipeline) *Pipeline {
+			return p.Facet("by_published", func(f *Pipeline) { f.Group([]string{"published"}, AccCount("total")) }).
+				Facet("latest", func(f *Pipeline) { f.Sort("created_at", "desc").Limit(2) }).
+				Project("latest")
+		}, want: "[[{$facet [{by_published [[{$group [{_id [{published $published}]} {total map[$sum:1]}]}] " +
+			"[{$project [{_id 0} {published $_id.published} {total 1}]}]]} {latest [[{$sort [{created_at -1}]}] [{$limit 2}]]}]}] " +
+			"[{$project [{latest 1}]}]]"},
+		{name: "cada facet parte de los campos antes del facet", pipeline: func(p *Pipeline) *Pipeline {
+			return p.Facet("a", func(f *Pipeline) { f.Project("title") }).Facet("b", func(f *Pipeline) { f.Sort("votes") })
+		}, want: "[[{$facet [{a [[{$project [{title 1}]}]]} {b [[{$sort [{votes 1}]}]]}]}]]"},
+
+		{name: "campo que no existe", pipeline: func(p *Pipeline) *Pipeline { return p.Match("author", "ana") },
+			wantErr: "el campo 'author' no existe en el pipeline de 'posts'"},
+		{name: "campo eliminado por project", pipeline: func(p *Pipeline) *Pipeline { return p.Project("title").Sort("votes") },
+			wantErr: "el campo 'votes' no existe en el pipeline de 'posts'"},
+		{name: "campo eliminado por group", pipeline: func(p *Pipeline) *Pipeline {
+			return p.Group(nil, AccCount("total")).Match("votes", 1)
+		}, wantErr: "el campo 'votes' no existe en el pipeline de 'posts'"},
+		{name: "columna del lookup", pipeline: func(p *Pipeline) *Pipeline { return p.Lookup("notes", "slug", "post_id", "notes") },
+			wantErr: "la columna 'post_id' no existe en la tabla 'notes'"},
+		{name: "nombre invalido", pipeline: func(p *Pipeline) *Pipeline { return p.Sort("votes; drop") },
+			wantErr: "el nombre del campo 'votes; drop' no es válido"},
+		{name: "limite", pipeline: func(p *Pipeline) *Pipeline { return p.Limit(0) },
+			wantErr: "el limite del pipeline debe ser mayor a cero"},
+		{name: "se conserva el primer error", pipeline: func(p *Pipeline) *Pipeline { return p.Skip(-1).Match("author", "ana") },
+			wantErr: "el skip del pipeline no puede ser negativo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stages, err := tt.pipeline(newPost().Pipeline()).Stages()
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, se esperaba %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprint(stages); got != tt.want {
+				t.Errorf("etapas =\n%s\nse esperaba\n%s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("solo mongodb", func(t *testing.T) {
+		fakeConnection(t, "test_mysql", "mysql")
+		err := newPost().Connection("test_mysql").Pipeline().Match("votes", 1).Get()
+		if err == nil || err.Error() != "el pipeline de agregación solo es compatible con mongodb" {
+			t.Errorf("error = %v, se esperaba que mysql no soporte el pipeline", err)
+		}
+	})
+}
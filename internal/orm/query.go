@@ -0,0 +1,528 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/donbarrigon/new-project/internal/cache"
+	"github.com/donbarrigon/new-project/internal/database/migration"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// where representa una condicion de la consulta
+type where struct {
+	boolean  string  // and | or
+	column   string  // columna a comparar
+	operator string  // operador de comparacion
+	value    any     // valor a comparar, en los operadores in y not in es un []any
+	group    []where // condiciones agrupadas entre parentesis, si no es nil se ignoran column, operator y value
+}
+
+// order representa el orden de la consulta
+type order struct {
+	column    string
+	direction string // ASC | DESC
+}
+
+// operadores validos para el where
+var operators = map[string]string{
+	"=":        "$eq",
+	"!=":       "$ne",
+	"<>":       "$ne",
+	"<":        "$lt",
+	"<=":       "$lte",
+	">":        "$gt",
+	">=":       "$gte",
+	"like":     "$regex",
+	"not like": "$not",
+	"in":       "$in",
+	"not in":   "$nin",
+	"null":     "$eq",
+	"not null": "$ne",
+}
+
+// identifierRegex valida que los nombres de tablas y columnas no tengan nada raro que permita inyectar sql
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func isIdentifier(name string) bool {
+	return identifierRegex.MatchString(name)
+}
+
+// checkColumn valida la columna contra la migracion y si no hay migracion que sea un identificador valido
+// el error se guarda en el modelo y se retorna al ejecutar la consulta
+func (m *Model) checkColumn(column string) bool {
+	if m.err != nil {
+		return false
+	}
+	if err := m.columnError(column); err != nil {
+		m.err = err
+		return false
+	}
+	return true
+}
+
+// columnError valida la columna y retorna nil si es valida
+// acepta nombres calificados tabla.columna, la columna se valida contra la migracion de esa tabla si existe
+func (m *Model) columnError(column string) error {
+	table, name, qualified := strings.Cut(column, ".")
+	if !qualified {
+		table, name = m.tableName, column
+	}
+	if !isIdentifier(table) || (!isIdentifier(name) && !(qualified && name == "*")) {
+		return fmt.Errorf("el nombre de columna '%s' no es válido", column)
+	}
+	if name == "*" || name == "_id" {
+		return nil
+	}
+
+	if table == m.tableName {
+		if m.hasMigration && !m.HasColumn(name) && !m.isAlias(column) {
+			return fmt.Errorf("la columna '%s' no existe en la tabla '%s'", name, table)
+		}
+		return nil
+	}
+	if t := cache.GetTable(table); t != nil && !tableHasColumn(t, name) {
+		return fmt.Errorf("la columna '%s' no existe en la tabla '%s'", name, table)
+	}
+	return nil
+}
+
+// tableHasColumn indica si la tabla de la migracion tiene la columna
+func tableHasColumn(table *migration.Table, column string) bool {
+	for _, col := range table.Columns {
+		if col.Name == column {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldName retorna el nombre de la columna para la consulta
+// en mongodb los campos de la coleccion principal no llevan el nombre de la tabla
+func (m *Model) fieldName(column string) string {
+	if m.driver() == "mongodb" {
+		return strings.TrimPrefix(column, m.tableName+".")
+	}
+	return column
+}
+
+// Select establece las columnas que se van a consultar
+func (m *Model) Select(columns ...string) *Model {
+	if err := m.SetSelectedColumns(columns); err != nil && m.err == nil {
+		m.err = err
+	}
+	return m
+}
+
+// Where agrega una condicion a la consulta
+// Where("name", "Juan") es igual a Where("name", "=", "Juan")
+// Where("age", ">=", 18)
+func (m *Model) Where(column string, args ...any) *Model {
+	return m.addWhere("and", column, args...)
+}
+
+// OrWhere agrega una condicion unida con OR
+func (m *Model) OrWhere(column string, args ...any) *Model {
+	return m.addWhere("or", column, args...)
+}
+
+// WhereIn agrega una condicion IN, values debe ser un slice o un modelo para usar una subconsulta
+func (m *Model) WhereIn(column string, values any) *Model {
+	return m.addWhere("and", column, "in", values)
+}
+
+// WhereNotIn agrega una condicion NOT IN, values debe ser un slice o un modelo para usar una subconsulta
+func (m *Model) WhereNotIn(column string, values any) *Model {
+	return m.addWhere("and", column, "not in", values)
+}
+
+// WhereNull agrega una condicion IS NULL
+func (m *Model) WhereNull(column string) *Model {
+	return m.addWhere("and", column, "null", nil)
+}
+
+// WhereNotNull agrega una condicion IS NOT NULL
+func (m *Model) WhereNotNull(column string) *Model {
+	return m.addWhere("and", column, "not null", nil)
+}
+
+// WhereGroup agrupa entre parentesis las condiciones que agregue fn
+// Where("active", true).WhereGroup(func(q *Model) { q.Where("role", "admin").OrWhere("role", "editor") })
+// WHERE active = ? AND (role = ? OR role = ?)
+func (m *Model) WhereGroup(fn func(q *Model)) *Model {
+	return m.addGroup("and", fn)
+}
+
+// OrWhereGroup agrupa entre parentesis las condiciones que agregue fn y las une con OR
+func (m *Model) OrWhereGroup(fn func(q *Model)) *Model {
+	return m.addGroup("or", fn)
+}
+
+func (m *Model) addGroup(boolean string, fn func(q *Model)) *Model {
+	if m.err != nil {
+		return m
+	}
+	q := &Model{tableName: m.tableName, table: m.table, hasMigration: m.hasMigration}
+	fn(q)
+	if q.err != nil {
+		m.err = q.err
+		return m
+	}
+	if len(q.wheres) == 0 {
+		return m
+	}
+	m.wheres = append(m.wheres, where{boolean: boolean, group: q.wheres})
+	return m
+}
+
+// groupWheres agrupa entre parentesis las condiciones de la consulta
+// asi la condicion que se agregue despues se une con AND a todas y no solo a la ultima: (a OR b) AND id > ?
+func (m *Model) groupWheres() {
+	if len(m.wheres) > 1 {
+		m.wheres = []where{{boolean: "and", group: m.wheres}}
+	}
+}
+
+func (m *Model) addWhere(boolean string, column string, args ...any) *Model {
+	if !m.checkColumn(column) {
+		return m
+	}
+
+	var operator string
+	var value any
+	switch len(args) {
+	case 1:
+		operator, value = "=", args[0]
+	case 2:
+		op, ok := args[0].(string)
+		if !ok {
+			m.err = fmt.Errorf("el operador de la columna '%s' debe ser un string", column)
+			return m
+		}
+		operator, value = strings.ToLower(strings.TrimSpace(op)), args[1]
+	default:
+		m.err = fmt.Errorf("argumentos inválidos en el where de la columna '%s'", column)
+		return m
+	}
+
+	if _, ok := operators[operator]; !ok {
+		m.err = fmt.Errorf("operador '%s' no soportado", operator)
+		return m
+	}
+
+	if operator == "in" || operator == "not in" {
+		if sub, ok := value.(ModelInterface); ok {
+			// WhereIn("id", orders.Select("user_id")) => id IN (SELECT user_id FROM orders)
+			if value = m.subquery(sub); m.err != nil {
+				return m
+			}
+		} else {
+			value = toSlice(value)
+		}
+	}
+
+	m.wheres = append(m.wheres, where{
+		boolean:  boolean,
+		column:   m.fieldName(column),
+		operator: operator,
+		value:    value,
+	})
+	return m
+}
+
+// OrderBy ordena los resultados, direction es opcional por defecto ASC
+func (m *Model) OrderBy(column string, direction ...string) *Model {
+	if !m.checkColumn(column) {
+		return m
+	}
+	dir := "ASC"
+	if len(direction) > 0 && strings.ToUpper(direction[0]) == "DESC" {
+		dir = "DESC"
+	}
+	m.orders = append(m.orders, order{column: m.fieldName(column), direction: dir})
+	return m
+}
+
+// Limit establece la cantidad maxima de registros
+func (m *Model) Limit(limit int) *Model {
+	m.limitValue = limit
+	return m
+}
+
+// Offset establece la cantidad de registros a saltar
+func (m *Model) Offset(offset int) *Model {
+	m.offsetValue = offset
+	return m
+}
+
+// toSlice convierte cualquier slice o array en un []any
+// si no es un slice retorna un slice con el valor
+func toSlice(values any) []any {
+	if v, ok := values.([]any); ok {
+		return v
+	}
+	rv := reflect.ValueOf(values)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return []any{values}
+	}
+	// []byte es un valor no una lista
+	if rv.Type().Elem().Kind() == reflect.Uint8 {
+		return []any{values}
+	}
+	result := make([]any, rv.Len())
+	for i := range result {
+		result[i] = rv.Index(i).Interface()
+	}
+	return result
+}
+
+// selectColumnsSQL retorna las columnas para el SELECT, si no hay columnas selecionadas se usa *
+func (m *Model) selectColumnsSQL() string {
+	if len(m.selectedColumns) == 0 {
+		return "*"
+	}
+	return strings.Join(m.selectedColumns, ", ")
+}
+
+// compileSelect construye la consulta SELECT y sus parametros
+func (m *Model) compileSelect() (string, []any) {
+	query, args := m.compileSelectSQL()
+	return rebind(m.driver(), query), args
+}
+
+// compileSelectSQL construye la consulta SELECT con placeholders ?, se usa para anidarla en otra consulta
+func (m *Model) compileSelectSQL() (string, []any) {
+	var sb strings.Builder
+	columnsSQL, args := m.compileColumns()
+	sb.WriteString("SELECT ")
+	sb.WriteString(columnsSQL)
+	sb.WriteString(" FROM ")
+	fromSQL, fromArgs := m.compileFrom()
+	sb.WriteString(fromSQL)
+	args = append(args, fromArgs...)
+
+	whereSQL, whereArgs := m.compileWheres()
+	sb.WriteString(whereSQL)
+	args = append(args, whereArgs...)
+
+	groupSQL, groupArgs := m.compileGroups()
+	sb.WriteString(groupSQL)
+	args = append(args, groupArgs...)
+
+	if len(m.orders) > 0 {
+		orders := make([]string, len(m.orders))
+		for i, o := range m.orders {
+			orders[i] = o.column + " " + o.direction
+		}
+		sb.WriteString(" ORDER BY ")
+		sb.WriteString(strings.Join(orders, ", "))
+	}
+
+	if m.limitValue > 0 {
+		sb.WriteString(fmt.Sprintf(" LIMIT %d", m.limitValue))
+	}
+	if m.offsetValue > 0 {
+		sb.WriteString(fmt.Sprintf(" OFFSET %d", m.offsetValue))
+	}
+
+	return sb.String(), args
+}
+
+// compileWheres construye la clausula WHERE con sus parametros
+func (m *Model) compileWheres() (string, []any) {
+	if len(m.wheres) == 0 {
+		return "", nil
+	}
+	sql, args := compileConditions(m.wheres)
+	return " WHERE " + sql, args
+}
+
+// compileConditions construye las condiciones unidas con AND u OR, los grupos van entre parentesis
+func compileConditions(wheres []where) (string, []any) {
+	var sb strings.Builder
+	args := make([]any, 0, len(wheres))
+	for i, w := range wheres {
+		if i > 0 {
+			sb.WriteString(" " + strings.ToUpper(w.boolean) + " ")
+		}
+		if w.group != nil {
+			sql, groupArgs := compileConditions(w.group)
+			sb.WriteString("(" + sql + ")")
+			args = append(args, groupArgs...)
+			continue
+		}
+		switch w.operator {
+		case "null":
+			sb.WriteString(w.column + " IS NULL")
+		case "not null":
+			sb.WriteString(w.column + " IS NOT NULL")
+		case "exists", "not exists":
+			sub := w.value.(subquery)
+			sb.WriteString(fmt.Sprintf("%s (%s)", strings.ToUpper(w.operator), sub.sql))
+			args = append(args, sub.args...)
+		case "in", "not in":
+			if sub, ok := w.value.(subquery); ok {
+				sb.WriteString(fmt.Sprintf("%s %s (%s)", w.column, strings.ToUpper(w.operator), sub.sql))
+				args = append(args, sub.args...)
+				continue
+			}
+			values := w.value.([]any)
+			if len(values) == 0 {
+				// un IN vacio nunca coincide y un NOT IN vacio siempre coincide
+				if w.operator == "in" {
+					sb.WriteString("1 = 0")
+				} else {
+					sb.WriteString("1 = 1")
+				}
+				continue
+			}
+			placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+			sb.WriteString(fmt.Sprintf("%s %s (%s)", w.column, strings.ToUpper(w.operator), placeholders))
+			args = append(args, values...)
+		default:
+			if ref, ok := w.value.(columnRef); ok {
+				sb.WriteString(fmt.Sprintf("%s %s %s", w.column, w.operator, ref))
+				continue
+			}
+			sb.WriteString(fmt.Sprintf("%s %s ?", w.column, strings.ToUpper(w.operator)))
+			args = append(args, w.value)
+		}
+	}
+	return sb.String(), args
+}
+
+// rebind cambia los placeholders ? por $n cuando el driver es postgresql
+func rebind(driver string, query string) string {
+	if driver != "postgresql" {
+		return query
+	}
+	var sb strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			sb.WriteString(fmt.Sprintf("$%d", n))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// mongoFilter construye el filtro de mongodb a partir de los where
+// las condiciones unidas con AND se agrupan y los grupos se unen con $or
+func (m *Model) mongoFilter() bson.M {
+	return mongoConditions(m.wheres)
+}
+
+// mongoConditions convierte una lista de where en un filtro de mongodb
+func mongoConditions(wheres []where) bson.M {
+	if len(wheres) == 0 {
+		return bson.M{}
+	}
+
+	groups := make([]bson.A, 0, 1)
+	current := bson.A{}
+	for i, w := range wheres {
+		if i > 0 && w.boolean == "or" {
+			groups = append(groups, current)
+			current = bson.A{}
+		}
+		current = append(current, mongoCondition(w))
+	}
+	groups = append(groups, current)
+
+	if len(groups) == 1 {
+		if len(groups[0]) == 1 {
+			return groups[0][0].(bson.M)
+		}
+		return bson.M{"$and": groups[0]}
+	}
+
+	or := make(bson.A, len(groups))
+	for i, g := range groups {
+		or[i] = bson.M{"$and": g}
+	}
+	return bson.M{"$or": or}
+}
+
+// mongoCondition convierte un where en una condicion de mongodb
+func mongoCondition(w where) bson.M {
+	if w.group != nil {
+		return mongoConditions(w.group)
+	}
+	if ref, ok := w.value.(columnRef); ok {
+		return bson.M{"$expr": bson.M{operators[w.operator]: bson.A{"$" + w.column, "$" + string(ref)}}}
+	}
+	switch w.operator {
+	case "null", "not null":
+		return bson.M{w.column: bson.M{operators[w.operator]: nil}}
+	case "like":
+		return bson.M{w.column: bson.M{"$regex": likeToRegex(fmt.Sprint(w.value)), "$options": "i"}}
+	case "not like":
+		return bson.M{w.column: bson.M{"$not": bson.M{"$regex": likeToRegex(fmt.Sprint(w.value)), "$options": "i"}}}
+	default:
+		return bson.M{w.column: bson.M{operators[w.operator]: w.value}}
+	}
+}
+
+// likeToRegex convierte un patron LIKE de sql en una expresion regular
+func likeToRegex(pattern string) string {
+	pattern = regexp.QuoteMeta(pattern)
+	pattern = strings.ReplaceAll(pattern, "%", ".*")
+	pattern = strings.ReplaceAll(pattern, "_", ".")
+	return "^" + pattern + "$"
+}
+
+// mongoFindOptions construye las opciones de la consulta en mongodb
+func (m *Model) mongoFindOptions() *options.FindOptions {
+	opts := options.Find()
+	if len(m.selectedColumns) > 0 {
+		projection := make(bson.M, len(m.selectedColumns))
+		for _, col := range m.selectedColumns {
+			projection[col] = 1
+		}
+		opts.SetProjection(projection)
+	}
+	if len(m.orders) > 0 {
+		sort := make(bson.D, len(m.orders))
+		for i, o := range m.orders {
+			dir := 1
+			if o.direction == "DESC" {
+				dir = -1
+			}
+			sort[i] = bson.E{Key: o.column, Value: dir}
+		}
+		opts.SetSort(sort)
+	}
+	if m.limitValue > 0 {
+		opts.SetLimit(int64(m.limitValue))
+	}
+	if m.offsetValue > 0 {
+		opts.SetSkip(int64(m.offsetValue))
+	}
+	return opts
+}
+
+// clone retorna una copia del modelo con la misma configuracion y consulta pero sin resultados
+// se usa para ejecutar variantes de la consulta (count, paginas, chunks) sin modificar el modelo original
+func (m *Model) clone() *Model {
+	c := *m
+	c.Data, c.original, c.single = nil, nil, false
+	c.makeVisible = append([]string(nil), m.makeVisible...)
+	c.makeHidden = append([]string(nil), m.makeHidden...)
+	c.wheres = append([]where(nil), m.wheres...)
+	c.orders = append([]order(nil), m.orders...)
+	c.with = append([]string(nil), m.with...)
+	c.selectedColumns = append([]string(nil), m.selectedColumns...)
+	c.groups = append([]string(nil), m.groups...)
+	c.havings = append([]where(nil), m.havings...)
+	c.aggregates = append([]aggregate(nil), m.aggregates...)
+	c.rawSelects = append([]rawExpr(nil), m.rawSelects...)
+	c.joins = append([]*Join(nil), m.joins...)
+	c.withoutScopes = append([]string(nil), m.withoutScopes...)
+	return &c
+}
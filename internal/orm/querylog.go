@@ -0,0 +1,287 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// defaultNPlusOneThreshold es cuantas veces se puede repetir una consulta en un request antes de reportar un N+1
+const defaultNPlusOneThreshold = 10
+
+// QueryEvent describe una operacion ejecutada en la base de datos
+type QueryEvent struct {
+	Connection string        // nombre de la conexion
+	Driver     string        // mongodb, mysql o postgresql
+	Table      string        // tabla o coleccion, vacio en Raw y Exec
+	Query      string        // sql ejecutado o la operacion de mongodb: users.find
+	Bindings   []any         // parametros del sql o filtro, pipeline o documentos de mongodb
+	Duration   time.Duration // tiempo de la consulta
+	Rows       int64         // filas leidas o afectadas, -1 si no se conocen (Cursor)
+	Err        error         // error de la consulta
+}
+
+// QueryListener recibe cada consulta ejecutada por el orm
+// se ejecuta de forma sincrona despues de la consulta, no debe hacer trabajo pesado
+type QueryListener func(ctx context.Context, e QueryEvent)
+
+// listeners almacena los listeners de las consultas
+var listeners = struct {
+	sync.RWMutex
+	fns []QueryListener
+}{}
+
+// Listen registra un listener que recibe todas las consultas del orm, sql y mongodb
+//
+//	orm.Listen(func(ctx context.Context, e orm.QueryEvent) {
+//		log.Printf("[%s] %s %v (%s)", e.Connection, e.Query, e.Bindings, e.Duration)
+//	})
+func Listen(fn QueryListener) {
+	listeners.Lock()
+	defer listeners.Unlock()
+	listeners.fns = append(listeners.fns, fn)
+}
+
+// record envia la consulta a los listeners
+func (c *connection) record(ctx context.Context, e QueryEvent) {
+	listeners.RLock()
+	fns := listeners.fns
+	listeners.RUnlock()
+	if len(fns) == 0 {
+		return
+	}
+	e.Connection, e.Driver = c.name, c.driver
+	for _, fn := range fns {
+		fn(ctx, e)
+	}
+}
+
+// record envia la consulta del modelo a los listeners, start es cuando inicio la consulta
+func (m *Model) record(ctx context.Context, start time.Time, query string, bindings []any, rows int64, err error) {
+	m.conn().record(ctx, QueryEvent{
+		Table:    m.tableName,
+		Query:    query,
+		Bindings: bindings,
+		Duration: time.Since(start),
+		Rows:     rows,
+		Err:      err,
+	})
+}
+
+// rowsAffected retorna las filas afectadas por la sentencia, 0 si no se conocen
+func rowsAffected(result sql.Result) int64 {
+	if result == nil {
+		return 0
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// mongoQuery retorna la descripcion de una operacion de mongodb: users.find
+func (m *Model) mongoQuery(operation string) string {
+	return m.tableName + "." + operation
+}
+
+// LogSlowQueries registra un listener que escribe en el log las consultas que tardan mas que threshold
+// con explain en las consultas SELECT de sql tambien se escribe el plan de ejecucion
+// Connect lo registra si se define DB_SLOW_QUERY_THRESHOLD (500ms, 2s) y DB_SLOW_QUERY_EXPLAIN=true
+func LogSlowQueries(threshold time.Duration, explain bool) {
+	Listen(func(ctx context.Context, e QueryEvent) {
+		if e.Duration < threshold {
+			return
+		}
+		log.Printf("Consulta lenta en la conexión '%s' (%s, %d filas): %s %v", e.Connection, e.Duration, e.Rows, e.Query, e.Bindings)
+		if !explain || e.Err != nil || !isSelect(e.Query) {
+			return
+		}
+		plan, err := explainQuery(ctx, getConnection(e.Connection), e.Query, e.Bindings)
+		if err != nil {
+			log.Printf("Error al obtener el plan de la consulta: %v", err)
+			return
+		}
+		log.Printf("Plan de la consulta:\n%s", plan)
+	})
+}
+
+// isSelect indica si la consulta sql es un SELECT
+func isSelect(query string) bool {
+	query = strings.TrimSpace(query)
+	return len(query) > 6 && strings.EqualFold(query[:6], "select")
+}
+
+// explainQuery ejecuta EXPLAIN de la consulta y retorna el plan como texto, una fila por linea
+func explainQuery(ctx context.Context, conn *connection, query string, args []any) (string, error) {
+	if conn.sql == nil {
+		return "", fmt.Errorf("explain solo es compatible con sql")
+	}
+	// la consulta original pudo terminar por tiempo limite, el explain tiene su propio contexto
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+
+	rows, err := conn.reader(ctx).QueryContext(ctx, "EXPLAIN "+query, args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	data, err := scanRows(rows)
+	if err != nil {
+		return "", err
+	}
+	lines := make([]string, len(data))
+	for i, row := range data {
+		columns := sortedColumns(row)
+		parts := make([]string, len(columns))
+		for j, col := range columns {
+			value := row[col]
+			if b, ok := value.([]byte); ok {
+				value = string(b)
+			}
+			parts[j] = fmt.Sprintf("%s=%v", col, value)
+		}
+		lines[i] = strings.Join(parts, " ")
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// queryTrackerKey es la key del contexto con el conteo de consultas del request
+type queryTrackerKey struct{}
+
+// queryTracker cuenta cuantas veces se ejecuta cada forma de consulta en un request
+type queryTracker struct {
+	sync.Mutex
+	counts map[string]int
+}
+
+// TrackQueries retorna un contexto que cuenta las consultas ejecutadas con el para detectar N+1
+// se asigna una vez por peticion, el middleware TrackQueries lo hace para cada request
+func TrackQueries(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(queryTrackerKey{}).(*queryTracker); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, queryTrackerKey{}, &queryTracker{counts: make(map[string]int)})
+}
+
+// DetectNPlusOne registra un listener que escribe en el log cuando la misma forma de consulta
+// se ejecuta threshold veces en un contexto de TrackQueries, normalmente una relacion cargada dentro de un ciclo
+// la forma ignora los valores de los parametros, se reporta una vez por consulta y request
+// Connect lo registra si se define DB_N_PLUS_ONE_THRESHOLD, 0 usa el valor por defecto de 10
+func DetectNPlusOne(threshold int) {
+	if threshold <= 0 {
+		threshold = defaultNPlusOneThreshold
+	}
+	Listen(func(ctx context.Context, e QueryEvent) {
+		tracker, ok := ctx.Value(queryTrackerKey{}).(*queryTracker)
+		if !ok {
+			return
+		}
+		shape := queryShape(e)
+		tracker.Lock()
+		tracker.counts[shape]++
+		count := tracker.counts[shape]
+		tracker.Unlock()
+		if count == threshold {
+			log.Printf("Posible N+1 en la conexión '%s': la consulta se ejecutó %d veces en el mismo request, use With para cargar la relación: %s", e.Connection, count, shape)
+		}
+	})
+}
+
+var (
+	// placeholderList reconoce las listas de parametros de IN que cambian de tamaño segun los valores
+	placeholderList = regexp.MustCompile(`\?(\s*,\s*\?)+`)
+	// numberedPlaceholder reconoce los parametros de postgresql $1, $2
+	numberedPlaceholder = regexp.MustCompile(`\$\d+`)
+)
+
+// queryShape retorna la consulta sin los valores para comparar consultas iguales
+func queryShape(e QueryEvent) string {
+	if e.Driver != "mongodb" {
+		shape := numberedPlaceholder.ReplaceAllString(e.Query, "?")
+		return placeholderList.ReplaceAllString(shape, "?...")
+	}
+	parts := make([]string, len(e.Bindings))
+	for i, b := range e.Bindings {
+		parts[i] = bsonShape(b)
+	}
+	return e.Query + "(" + strings.Join(parts, ", ") + ")"
+}
+
+// bsonShape retorna la estructura de un filtro o pipeline de mongodb reemplazando los valores por ?
+func bsonShape(value any) string {
+	switch v := value.(type) {
+	case bson.M:
+		return mapShape(v)
+	case map[string]any:
+		return mapShape(v)
+	case bson.D:
+		parts := make([]string, len(v))
+		for i, e := range v {
+			parts[i] = e.Key + ": " + bsonShape(e.Value)
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	case bson.A:
+		return sliceShape(v)
+	case []any:
+		return sliceShape(v)
+	case mongo.Pipeline:
+		parts := make([]any, len(v))
+		for i, d := range v {
+			parts[i] = d
+		}
+		return sliceShape(parts)
+	}
+	return "?"
+}
+
+// mapShape retorna la estructura de un documento con las keys ordenadas
+func mapShape(m map[string]any) string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key + ": " + bsonShape(m[key])
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// sliceShape retorna la estructura de un arreglo, los arreglos de valores se reducen a ? sin importar su tamaño
+func sliceShape(values []any) string {
+	parts := make([]string, 0, len(values))
+	for _, value := range values {
+		shape := bsonShape(value)
+		if shape == "?" {
+			return "[?...]"
+		}
+		parts = append(parts, shape)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// configureQueryLog registra el log de consultas lentas y la deteccion de N+1 segun las variables de entorno
+func configureQueryLog() {
+	if threshold, err := time.ParseDuration(os.Getenv("DB_SLOW_QUERY_THRESHOLD")); err == nil && threshold > 0 {
+		explain, _ := strconv.ParseBool(os.Getenv("DB_SLOW_QUERY_EXPLAIN"))
+		LogSlowQueries(threshold, explain)
+	}
+	if value := os.Getenv("DB_N_PLUS_ONE_THRESHOLD"); value != "" {
+		threshold, _ := strconv.Atoi(value)
+		DetectNPlusOne(threshold)
+	}
+}
@@ -0,0 +1,159 @@
+package orm
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// listenTest registra los listeners de la prueba y los elimina al terminar
+func listenTest(t *testing.T, register func()) {
+	t.Helper()
+	listeners.Lock()
+	fns := listeners.fns
+	listeners.fns = nil
+	listeners.Unlock()
+	t.Cleanup(func() {
+		listeners.Lock()
+		listeners.fns = fns
+		listeners.Unlock()
+	})
+	register()
+}
+
+// captureLog retorna el buffer en el que se escribe el log durante la prueba
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	writer, flags := log.Writer(), log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(writer)
+		log.SetFlags(flags)
+	})
+	return &buf
+}
+
+func TestListen(t *testing.T) {
+	setupMemory(t, postTable())
+	var events []QueryEvent
+	listenTest(t, func() {
+		Listen(func(ctx context.Context, e QueryEvent) { events = append(events, e) })
+	})
+
+	seedPosts(t, 10, 20)
+	if err := newPost().Where("votes", ">", 5).Get(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		rows  int64
+	}{
+		{query: "memory:posts.insert", rows: 1},
+		{query: "memory:posts.insert", rows: 1},
+		{query: "memory:posts.select", rows: 2},
+	}
+	if len(events) != len(tests) {
+		t.Fatalf("eventos = %d, se esperaban %d: %v", len(events), len(tests), events)
+	}
+	for i, tt := range tests {
+		e := events[i]
+		if e.Query != tt.query || e.Rows != tt.rows || e.Table != "posts" || e.Connection != DefaultConnection || e.Driver != "memory" {
+			t.Errorf("evento %d = %+v, se esperaba %s con %d filas", i, e, tt.query, tt.rows)
+		}
+	}
+}
+
+func TestLogSlowQueries(t *testing.T) {
+	fakeConnection(t, "test_mongodb", "mongodb")
+	conn := getConnection("test_mongodb")
+
+	tests := []struct {
+		name    string
+		event   QueryEvent
+		explain bool
+		want    []string
+	}{
+		{name: "rapida", event: QueryEvent{Query: "SELECT * FROM posts", Duration: 10}},
+		{name: "lenta", event: QueryEvent{Query: "UPDATE posts SET votes = ?", Bindings: []any{1}, Duration: 200, Rows: 3},
+			want: []string{"Consulta lenta en la conexión 'test_mongodb' (200ns, 3 filas): UPDATE posts SET votes = ? [1]"}},
+		{name: "explain solo en SELECT", explain: true, event: QueryEvent{Query: "DELETE FROM posts", Duration: 200},
+			want: []string{"Consulta lenta en la conexión 'test_mongodb' (200ns, 0 filas): DELETE FROM posts []"}},
+		{name: "explain sin sql", explain: true, event: QueryEvent{Query: " select * from posts", Duration: 200},
+			want: []string{
+				"Consulta lenta en la conexión 'test_mongodb' (200ns, 0 filas):  select * from posts []",
+				"Error al obtener el plan de la consulta: explain solo es compatible con sql",
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := captureLog(t)
+			listenTest(t, func() { LogSlowQueries(100, tt.explain) })
+			conn.record(context.Background(), tt.event)
+
+			got := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if buf.Len() == 0 {
+				got = nil
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("log =\n%s\nse esperaba\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestDetectNPlusOne(t *testing.T) {
+	fakeConnection(t, "test_mysql", "mysql")
+	conn := getConnection("test_mysql")
+	buf := captureLog(t)
+	listenTest(t, func() { DetectNPlusOne(3) })
+
+	ctx := TrackQueries(context.Background())
+	for i := range 5 {
+		conn.record(ctx, QueryEvent{Query: "SELECT * FROM posts WHERE user_id = ?", Bindings: []any{i}})
+	}
+	conn.record(ctx, QueryEvent{Query: "SELECT * FROM users WHERE id = ?", Bindings: []any{1}})
+	// sin TrackQueries no se cuentan las consultas
+	for range 5 {
+		conn.record(context.Background(), QueryEvent{Query: "SELECT * FROM users WHERE id = ?"})
+	}
+
+	if n := strings.Count(buf.String(), "Posible N+1"); n != 1 {
+		t.Fatalf("se reportaron %d N+1, se esperaba 1:\n%s", n, buf.String())
+	}
+	if !strings.Contains(buf.String(), "se ejecutó 3 veces en el mismo request, use With para cargar la relación: SELECT * FROM posts WHERE user_id = ?") {
+		t.Errorf("log = %s", buf.String())
+	}
+}
+
+func TestQueryShape(t *testing.T) {
+	tests := []struct {
+		name  string
+		event QueryEvent
+		want  string
+	}{
+		{name: "mysql", event: QueryEvent{Driver: "mysql", Query: "SELECT * FROM posts WHERE id IN (?, ?, ?) AND votes > ?"},
+			want: "SELECT * FROM posts WHERE id IN (?...) AND votes > ?"},
+		{name: "postgresql", event: QueryEvent{Driver: "postgresql", Query: "SELECT * FROM posts WHERE id IN ($1,$2) AND votes > $3"},
+			want: "SELECT * FROM posts WHERE id IN (?...) AND votes > ?"},
+		{name: "mongodb", event: QueryEvent{Driver: "mongodb", Query: "posts.find",
+			Bindings: []any{bson.M{"votes": bson.M{"$gt": 5}, "_id": bson.M{"$in": bson.A{1, 2, 3}}}}},
+			want: "posts.find({_id: {$in: [?...]}, votes: {$gt: ?}})"},
+		{name: "pipeline de mongodb", event: QueryEvent{Driver: "mongodb", Query: "posts.aggregate",
+			Bindings: []any{bson.A{bson.D{{Key: "$match", Value: bson.M{"slug": "a"}}}, bson.D{{Key: "$limit", Value: 10}}}}},
+			want: "posts.aggregate([{$match: {slug: ?}}, {$limit: ?}])"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := queryShape(tt.event); got != tt.want {
+				t.Errorf("forma =\n%s\nse esperaba\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,334 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Ident es un nombre de tabla o columna que se escribe en la consulta entre comillas en lugar de pasarse como parametro
+// acepta nombres calificados tabla.columna y tabla.*, cualquier otro caracter es un error
+//
+//	orm.Raw(ctx, "SELECT * FROM :table WHERE :column = :value", map[string]any{
+//		"table":  orm.Ident("users"),
+//		"column": orm.Ident(column),
+//		"value":  value,
+//	})
+type Ident string
+
+// quote retorna el identificador entre comillas segun el driver: `users` en mysql y "users" en postgresql
+func (i Ident) quote(driver string) (string, error) {
+	if i == "*" {
+		return "*", nil
+	}
+	q := "`"
+	if driver == "postgresql" {
+		q = `"`
+	}
+	parts := strings.Split(string(i), ".")
+	for n, part := range parts {
+		if part == "*" && n == len(parts)-1 && n > 0 {
+			continue
+		}
+		if !isIdentifier(part) {
+			return "", fmt.Errorf("el identificador '%s' no es válido", string(i))
+		}
+		parts[n] = q + part + q
+	}
+	return strings.Join(parts, "."), nil
+}
+
+// Raw ejecuta una consulta sql escrita a mano y retorna las filas
+// los parametros pueden ser posicionales con ? o con nombre con :name tomados de un map[string]any o de un struct
+// con parametros posicionales ?? escribe un ? en la consulta, por ejemplo para los operadores de jsonb de postgresql
+// los slices se expanden para usarlos en IN: WHERE id IN (:ids) => WHERE id IN (?, ?, ?)
+// los valores Ident se escriben en la consulta como identificadores entre comillas
+// si ctx tiene una transaccion la consulta se ejecuta dentro de ella, si no en la conexion de UseConnection o la default
+// fuera de una transaccion se ejecuta en una replica de lectura si la conexion las tiene
+// el tiempo limite es el de DB_QUERY_TIMEOUT
+//
+//	rows, err := orm.Raw(ctx, "SELECT * FROM users WHERE role = :role AND id IN (:ids)", map[string]any{
+//		"role": "admin",
+//		"ids":  []int{1, 2, 3},
+//	})
+func Raw(ctx context.Context, query string, args ...any) ([]map[string]any, error) {
+	conn := contextConnection(ctx)
+	exec, err := rawExecutor(ctx, conn, false)
+	if err != nil {
+		return nil, err
+	}
+	query, params, err := compileRaw(conn.driver, query, args)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := withTimeout(ctx, 0)
+	defer cancel()
+	start := time.Now()
+	rows, err := queryRows(ctx, exec, query, params)
+	conn.record(ctx, QueryEvent{Query: query, Bindings: params, Duration: time.Since(start), Rows: int64(len(rows)), Err: err})
+	return rows, err
+}
+
+// RawInto ejecuta la consulta como Raw y copia los resultados en dest, un puntero a un struct o a un slice de structs
+func RawInto(ctx context.Context, dest any, query string, args ...any) error {
+	rows, err := Raw(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return hydrate(rows, dest)
+}
+
+// Exec ejecuta una sentencia sql escrita a mano (INSERT, UPDATE, DELETE, DDL) con los mismos parametros que Raw
+// siempre se ejecuta en el primario
+func Exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	conn := contextConnection(ctx)
+	exec, err := rawExecutor(ctx, conn, true)
+	if err != nil {
+		return nil, err
+	}
+	query, params, err := compileRaw(conn.driver, query, args)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := withTimeout(ctx, 0)
+	defer cancel()
+	start := time.Now()
+	result, err := exec.ExecContext(ctx, query, params...)
+	conn.record(ctx, QueryEvent{Query: query, Bindings: params, Duration: time.Since(start), Rows: rowsAffected(result), Err: err})
+	if err != nil {
+		return nil, dbError("error al ejecutar la sentencia", err)
+	}
+	return result, nil
+}
+
+// rawExecutor retorna la transaccion del contexto o la conexion
+// write indica si es una escritura, se ejecuta en el primario y las lecturas siguientes del contexto son sticky
+func rawExecutor(ctx context.Context, conn *connection, write bool) (sqlExecutor, error) {
+	if conn.err != nil {
+		return nil, conn.err
+	}
+	if conn.driver != "mysql" && conn.driver != "postgresql" {
+		return nil, fmt.Errorf("las consultas sql no son compatibles con el driver '%s' de la conexión '%s'", conn.driver, conn.name)
+	}
+	if write {
+		conn.markWrite(ctx)
+	}
+	if tx, ok := ctx.Value(txKey{}).(*Tx); ok && tx.sqlTx != nil {
+		return tx.sqlTx, nil
+	}
+	if write {
+		return conn.sql, nil
+	}
+	return conn.reader(ctx), nil
+}
+
+// queryRows ejecuta la consulta y retorna las filas como maps
+func queryRows(ctx context.Context, exec sqlExecutor, query string, args []any) ([]map[string]any, error) {
+	rows, err := exec.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, dbError("error en la consulta", err)
+	}
+	defer rows.Close()
+	return scanRows(rows)
+}
+
+// compileRaw reemplaza los parametros de la consulta por placeholders del driver y retorna los valores en orden
+// si args es un solo map[string]any o struct se usan parametros con nombre, si no posicionales
+// los placeholders ($n en postgresql) se escriben en el mismo recorrido que salta los textos entre comillas,
+// asi los ? de los textos y los operadores ?, ?| y ?& de jsonb con parametros con nombre no se reemplazan
+// con parametros posicionales ?? escribe un ? en la consulta
+func compileRaw(driver string, query string, args []any) (string, []any, error) {
+	var named map[string]any
+	if len(args) == 1 {
+		var err error
+		if named, err = namedParams(args[0]); err != nil {
+			return "", nil, err
+		}
+	}
+
+	q := &rawQuery{driver: driver, params: make([]any, 0, len(args))}
+	runes := []rune(query)
+	next := 0
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\'' || r == '"' || r == '`':
+			// los textos y los identificadores entre comillas se copian tal cual
+			end := quotedEnd(runes, i)
+			q.sb.WriteString(string(runes[i : end+1]))
+			i = end
+
+		case r == ':' && named != nil:
+			// :: es un cast de postgresql
+			if i+1 < len(runes) && runes[i+1] == ':' {
+				q.sb.WriteString("::")
+				i++
+				continue
+			}
+			end := i + 1
+			for end < len(runes) && (runes[end] == '_' || unicode.IsLetter(runes[end]) || (end > i+1 && unicode.IsDigit(runes[end]))) {
+				end++
+			}
+			if end == i+1 {
+				q.sb.WriteRune(r)
+				continue
+			}
+			name := string(runes[i+1 : end])
+			value, ok := named[name]
+			if !ok {
+				return "", nil, fmt.Errorf("falta el parámetro ':%s'", name)
+			}
+			if err := q.bindRaw(value); err != nil {
+				return "", nil, err
+			}
+			i = end - 1
+
+		case r == '?' && named == nil:
+			if i+1 < len(runes) && runes[i+1] == '?' {
+				q.sb.WriteRune('?')
+				i++
+				continue
+			}
+			if next >= len(args) {
+				return "", nil, fmt.Errorf("la consulta tiene más parámetros que valores")
+			}
+			if err := q.bindRaw(args[next]); err != nil {
+				return "", nil, err
+			}
+			next++
+
+		default:
+			q.sb.WriteRune(r)
+		}
+	}
+
+	if named == nil && next != len(args) {
+		return "", nil, fmt.Errorf("la consulta tiene %d parámetros y se recibieron %d valores", next, len(args))
+	}
+	return q.sb.String(), q.params, nil
+}
+
+// quotedEnd retorna la posicion de la comilla que cierra el texto que empieza en i, o el final de la consulta
+func quotedEnd(runes []rune, i int) int {
+	end := i + 1
+	for end < len(runes) && runes[end] != runes[i] {
+		end++
+	}
+	if end == len(runes) {
+		end--
+	}
+	return end
+}
+
+// rawQuery es la consulta que se va compilando en compileRaw con sus parametros
+type rawQuery struct {
+	driver string
+	sb     strings.Builder
+	params []any
+}
+
+// bind agrega el valor a los parametros y escribe su placeholder, ? en mysql y $n en postgresql
+func (q *rawQuery) bind(value any) {
+	q.params = append(q.params, value)
+	if q.driver == "postgresql" {
+		q.sb.WriteString("$" + strconv.Itoa(len(q.params)))
+		return
+	}
+	q.sb.WriteRune('?')
+}
+
+// bindRaw escribe el placeholder del valor y lo agrega a los parametros
+// Ident y []Ident se escriben como identificadores, los slices se expanden en una lista de placeholders
+func (q *rawQuery) bindRaw(value any) error {
+	switch v := value.(type) {
+	case Ident:
+		quoted, err := v.quote(q.driver)
+		if err != nil {
+			return err
+		}
+		q.sb.WriteString(quoted)
+		return nil
+	case subquery:
+		// fragmento de sql ya compilado con placeholders ?, se numeran en orden con sus parametros
+		runes := []rune(v.sql)
+		next := 0
+		for i := 0; i < len(runes); i++ {
+			switch r := runes[i]; {
+			case r == '\'' || r == '"' || r == '`':
+				end := quotedEnd(runes, i)
+				q.sb.WriteString(string(runes[i : end+1]))
+				i = end
+			case r == '?' && next < len(v.args):
+				q.bind(v.args[next])
+				next++
+			default:
+				q.sb.WriteRune(r)
+			}
+		}
+		return nil
+	case []Ident:
+		quoted := make([]string, len(v))
+		for i, ident := range v {
+			s, err := ident.quote(q.driver)
+			if err != nil {
+				return err
+			}
+			quoted[i] = s
+		}
+		q.sb.WriteString(strings.Join(quoted, ", "))
+		return nil
+	}
+
+	rv := reflect.ValueOf(value)
+	if value != nil && (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && rv.Type().Elem().Kind() != reflect.Uint8 {
+		values := toSlice(value)
+		if len(values) == 0 {
+			// IN (NULL) nunca coincide
+			q.sb.WriteString("NULL")
+			return nil
+		}
+		for i, item := range values {
+			if i > 0 {
+				q.sb.WriteString(", ")
+			}
+			q.bind(item)
+		}
+		return nil
+	}
+
+	q.bind(value)
+	return nil
+}
+
+// namedParams convierte un map[string]any o un struct en los parametros con nombre
+// retorna nil si value es un valor normal y se debe usar como parametro posicional
+func namedParams(value any) (map[string]any, error) {
+	if params, ok := value.(map[string]any); ok {
+		return params, nil
+	}
+	if _, ok := value.(driver.Valuer); ok {
+		return nil, nil
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct || rv.Type() == timeType {
+		return nil, nil
+	}
+
+	params := make(map[string]any)
+	for column, index := range typeFields(rv.Type()) {
+		if field, ok := fieldByIndexSafe(rv, index); ok {
+			params[column] = field.Interface()
+		}
+	}
+	return params, nil
+}
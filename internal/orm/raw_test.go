@@ -0,0 +1,92 @@
+package orm
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCompileRaw(t *testing.T) {
+	tests := []struct {
+		name   string
+		driver string
+		query  string
+		args   []any
+		want   string
+		params []any
+	}{
+		{
+			name:   "posicionales mysql",
+			driver: "mysql",
+			query:  "SELECT * FROM users WHERE id IN (?) AND name = ?",
+			args:   []any{[]int{1, 2}, "ana"},
+			want:   "SELECT * FROM users WHERE id IN (?, ?) AND name = ?",
+			params: []any{1, 2, "ana"},
+		},
+		{
+			name:   "posicionales postgresql",
+			driver: "postgresql",
+			query:  "SELECT * FROM users WHERE id IN (?) AND name = ?",
+			args:   []any{[]int{1, 2}, "ana"},
+			want:   "SELECT * FROM users WHERE id IN ($1, $2) AND name = $3",
+			params: []any{1, 2, "ana"},
+		},
+		{
+			name:   "textos con ? en postgresql",
+			driver: "postgresql",
+			query:  "SELECT '¿?' AS q, \"a?\" FROM t WHERE x = ?",
+			args:   []any{1},
+			want:   "SELECT '¿?' AS q, \"a?\" FROM t WHERE x = $1",
+			params: []any{1},
+		},
+		{
+			name:   "operadores jsonb con nombre",
+			driver: "postgresql",
+			query:  "SELECT * FROM :table WHERE data ? 'tag' AND data ?| array['a'] AND id = :id AND n::int > 0",
+			args:   []any{map[string]any{"table": Ident("posts"), "id": 5}},
+			want:   `SELECT * FROM "posts" WHERE data ? 'tag' AND data ?| array['a'] AND id = $1 AND n::int > 0`,
+			params: []any{5},
+		},
+		{
+			name:   "?? posicional",
+			driver: "postgresql",
+			query:  "SELECT * FROM t WHERE data ?? 'tag' AND id = ?",
+			args:   []any{5},
+			want:   "SELECT * FROM t WHERE data ? 'tag' AND id = $1",
+			params: []any{5},
+		},
+		{
+			name:   "subconsulta",
+			driver: "postgresql",
+			query:  "SELECT * FROM t WHERE a = :a:rest",
+			args:   []any{map[string]any{"a": 1, "rest": subquery{sql: " AND b = ? AND c = '?'", args: []any{2}}}},
+			want:   "SELECT * FROM t WHERE a = $1 AND b = $2 AND c = '?'",
+			params: []any{1, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, params, err := compileRaw(tt.driver, tt.query, tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("consulta = %q, se esperaba %q", got, tt.want)
+			}
+			if !slices.Equal(params, tt.params) {
+				t.Errorf("parametros = %v, se esperaba %v", params, tt.params)
+			}
+		})
+	}
+
+	t.Run("errores", func(t *testing.T) {
+		if _, _, err := compileRaw("mysql", "SELECT ?, ?", []any{1}); err == nil {
+			t.Error("faltan valores y no retorno error")
+		}
+		if _, _, err := compileRaw("mysql", "SELECT :a", []any{map[string]any{}}); err == nil {
+			t.Error("falta un parametro con nombre y no retorno error")
+		}
+		if _, _, err := compileRaw("mysql", "SELECT * FROM :t", []any{map[string]any{"t": Ident("a;b")}}); err == nil {
+			t.Error("se acepto un identificador invalido")
+		}
+	})
+}
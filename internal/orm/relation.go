@@ -0,0 +1,244 @@
+package orm
+
+import (
+	"sync"
+
+	"github.com/donbarrigon/new-project/internal/cache"
+	"github.com/donbarrigon/new-project/lib/formatter"
+)
+
+// tipos de relaciones soportadas
+const (
+	HasOneRelation         = "has_one"
+	HasManyRelation        = "has_many"
+	BelongsToRelation      = "belongs_to"
+	BelongsToManyRelation  = "belongs_to_many"
+	HasManyThroughRelation = "has_many_through"
+)
+
+// Relation define como se relaciona un modelo con otro
+// las claves que no se especifiquen se infieren de las claves foraneas de la migracion
+// y si no hay migracion se usan las convenciones de nombres (user_id, id)
+type Relation struct {
+	Name    string // nombre de la relacion, es la key donde se guardan los resultados en Data
+	Type    string // tipo de relacion
+	Related string // tabla relacionada
+
+	// HasOne y HasMany: ForeignKey es la columna de la tabla relacionada y LocalKey la del modelo
+	// BelongsTo: ForeignKey es la columna del modelo y OwnerKey la de la tabla relacionada
+	ForeignKey string
+	LocalKey   string
+	OwnerKey   string
+
+	// BelongsToMany
+	Pivot           string   // tabla pivote
+	ForeignPivotKey string   // columna de la tabla pivote que apunta al modelo
+	RelatedPivotKey string   // columna de la tabla pivote que apunta a la tabla relacionada
+	RelatedKey      string   // columna de la tabla relacionada referenciada por la tabla pivote
+	PivotColumns    []string // columnas extra de la tabla pivote que se agregan al resultado en "pivot"
+
+	// HasManyThrough
+	Through        string // tabla intermedia
+	FirstKey       string // columna de la tabla intermedia que apunta al modelo
+	SecondKey      string // columna de la tabla relacionada que apunta a la tabla intermedia
+	SecondLocalKey string // columna de la tabla intermedia referenciada por la tabla relacionada
+
+	// relaciones polimorficas
+	MorphType  string // columna <name>_type con el tipo del modelo
+	MorphID    string // columna <name>_id con el id del modelo
+	MorphValue string // valor de <name>_type para el modelo padre, se resuelve al cargar la relacion
+}
+
+// registry almacena las relaciones de los modelos registrados por nombre de tabla
+// se usa para cargar relaciones anidadas donde solo se conoce el nombre de la tabla
+var registry = struct {
+	sync.RWMutex
+	relations   map[string]map[string]*Relation
+	scopes      map[string]*scopeSet
+	attributes  map[string]*attributeSet
+	connections map[string]string
+}{
+	relations:   make(map[string]map[string]*Relation),
+	scopes:      make(map[string]*scopeSet),
+	attributes:  make(map[string]*attributeSet),
+	connections: make(map[string]string),
+}
+
+// Register registra los modelos para que sus relaciones puedan ser cargadas de forma anidada
+// y sus scopes, casts y conexion se apliquen cuando la tabla se consulta como relacion
+// se debe llamar al iniciar la aplicacion: orm.Register(user.NewModel(), role.NewModel())
+func Register(models ...ModelInterface) {
+	registry.Lock()
+	defer registry.Unlock()
+	for _, model := range models {
+		m := model.getModel()
+		if registry.relations[m.tableName] == nil {
+			registry.relations[m.tableName] = make(map[string]*Relation)
+		}
+		for name, r := range m.relations {
+			registry.relations[m.tableName][name] = r
+		}
+		if m.scopes != nil {
+			registry.scopes[m.tableName] = m.scopes
+		}
+		if m.attributes != nil {
+			registry.attributes[m.tableName] = m.attributes
+		}
+		if m.connName != "" {
+			registry.connections[m.tableName] = m.connName
+		}
+	}
+}
+
+// relation busca la relacion en el modelo y si no la encuentra la busca en los modelos registrados
+func (m *Model) relation(name string) (*Relation, bool) {
+	if r, ok := m.relations[name]; ok {
+		return r, true
+	}
+	registry.RLock()
+	defer registry.RUnlock()
+	r, ok := registry.relations[m.tableName][name]
+	return r, ok
+}
+
+func (m *Model) addRelation(r *Relation) *Relation {
+	if m.relations == nil {
+		m.relations = make(map[string]*Relation)
+	}
+	m.relations[r.Name] = r
+	return r
+}
+
+// HasOne define una relacion uno a uno donde la tabla relacionada tiene la clave foranea
+// keys es opcional: foreignKey, localKey
+// HasOne("profile", "profile") HasOne("profile", "profile", "user_id", "id")
+func (m *Model) HasOne(name string, related string, keys ...string) *Relation {
+	r := &Relation{Name: name, Type: HasOneRelation, Related: formatter.ToTableName(related)}
+	r.ForeignKey, r.LocalKey = optionalKey(keys, 0), optionalKey(keys, 1)
+	return m.addRelation(r)
+}
+
+// HasMany define una relacion uno a muchos donde la tabla relacionada tiene la clave foranea
+// keys es opcional: foreignKey, localKey
+func (m *Model) HasMany(name string, related string, keys ...string) *Relation {
+	r := &Relation{Name: name, Type: HasManyRelation, Related: formatter.ToTableName(related)}
+	r.ForeignKey, r.LocalKey = optionalKey(keys, 0), optionalKey(keys, 1)
+	return m.addRelation(r)
+}
+
+// BelongsTo define la relacion inversa de HasOne y HasMany, el modelo tiene la clave foranea
+// keys es opcional: foreignKey, ownerKey
+func (m *Model) BelongsTo(name string, related string, keys ...string) *Relation {
+	r := &Relation{Name: name, Type: BelongsToRelation, Related: formatter.ToTableName(related)}
+	r.ForeignKey, r.OwnerKey = optionalKey(keys, 0), optionalKey(keys, 1)
+	return m.addRelation(r)
+}
+
+// BelongsToMany define una relacion muchos a muchos usando una tabla pivote
+// si no se especifica la tabla pivote se usa el nombre del modelo y el relacionado: user + role = user_roles
+// keys es opcional: foreignPivotKey, relatedPivotKey
+func (m *Model) BelongsToMany(name string, related string, pivot string, keys ...string) *Relation {
+	r := &Relation{Name: name, Type: BelongsToManyRelation, Related: formatter.ToTableName(related)}
+	if pivot != "" {
+		r.Pivot = formatter.ToTableName(pivot)
+	}
+	r.ForeignPivotKey, r.RelatedPivotKey = optionalKey(keys, 0), optionalKey(keys, 1)
+	return m.addRelation(r)
+}
+
+// HasManyThrough define una relacion a traves de una tabla intermedia
+// ejemplo un pais tiene muchos posts a traves de los usuarios: HasManyThrough("posts", "post", "user")
+// keys es opcional: firstKey, secondKey
+func (m *Model) HasManyThrough(name string, related string, through string, keys ...string) *Relation {
+	r := &Relation{
+		Name:    name,
+		Type:    HasManyThroughRelation,
+		Related: formatter.ToTableName(related),
+		Through: formatter.ToTableName(through),
+	}
+	r.FirstKey, r.SecondKey = optionalKey(keys, 0), optionalKey(keys, 1)
+	return m.addRelation(r)
+}
+
+// WithPivot agrega columnas de la tabla pivote al resultado de una relacion BelongsToMany
+func (r *Relation) WithPivot(columns ...string) *Relation {
+	r.PivotColumns = append(r.PivotColumns, columns...)
+	return r
+}
+
+func optionalKey(keys []string, i int) string {
+	if len(keys) > i {
+		return keys[i]
+	}
+	return ""
+}
+
+// resolve retorna una copia de la relacion con todas las claves resueltas para el modelo padre
+func (r *Relation) resolve(parent *Model) *Relation {
+	if r.isMorph() {
+		return r.resolveMorph(parent)
+	}
+
+	rr := *r
+	related := parent.newRelated(r.Related)
+
+	switch r.Type {
+	case HasOneRelation, HasManyRelation:
+		column, reference := foreignKeyTo(parent.driver(), r.Related, parent.tableName)
+		rr.ForeignKey = firstNonEmpty(r.ForeignKey, column, parent.modelName+"_id")
+		rr.LocalKey = firstNonEmpty(r.LocalKey, reference, parent.PrimaryKey())
+
+	case BelongsToRelation:
+		column, reference := foreignKeyTo(parent.driver(), parent.tableName, r.Related)
+		rr.ForeignKey = firstNonEmpty(r.ForeignKey, column, related.modelName+"_id")
+		rr.OwnerKey = firstNonEmpty(r.OwnerKey, reference, related.PrimaryKey())
+
+	case BelongsToManyRelation:
+		rr.Pivot = firstNonEmpty(r.Pivot, formatter.ToTableName(parent.modelName+"_"+related.modelName))
+		foreignColumn, parentReference := foreignKeyTo(parent.driver(), rr.Pivot, parent.tableName)
+		relatedColumn, relatedReference := foreignKeyTo(parent.driver(), rr.Pivot, r.Related)
+		rr.ForeignPivotKey = firstNonEmpty(r.ForeignPivotKey, foreignColumn, parent.modelName+"_id")
+		rr.RelatedPivotKey = firstNonEmpty(r.RelatedPivotKey, relatedColumn, related.modelName+"_id")
+		rr.LocalKey = firstNonEmpty(r.LocalKey, parentReference, parent.PrimaryKey())
+		rr.RelatedKey = firstNonEmpty(r.RelatedKey, relatedReference, related.PrimaryKey())
+
+	case HasManyThroughRelation:
+		through := parent.newRelated(r.Through)
+		firstColumn, parentReference := foreignKeyTo(parent.driver(), r.Through, parent.tableName)
+		secondColumn, throughReference := foreignKeyTo(parent.driver(), r.Related, r.Through)
+		rr.FirstKey = firstNonEmpty(r.FirstKey, firstColumn, parent.modelName+"_id")
+		rr.SecondKey = firstNonEmpty(r.SecondKey, secondColumn, through.modelName+"_id")
+		rr.LocalKey = firstNonEmpty(r.LocalKey, parentReference, parent.PrimaryKey())
+		rr.SecondLocalKey = firstNonEmpty(r.SecondLocalKey, throughReference, through.PrimaryKey())
+	}
+
+	return &rr
+}
+
+// foreignKeyTo busca en la migracion de la tabla la clave foranea que apunta a references
+// retorna la columna y la columna referenciada, si no la encuentra retorna strings vacios
+func foreignKeyTo(driver string, table string, references string) (string, string) {
+	t := cache.GetTable(table)
+	if t == nil {
+		return "", ""
+	}
+	for _, col := range t.Columns {
+		if col.ForeignKey.Table == references {
+			// en mongodb la clave primaria siempre es _id
+			if driver == "mongodb" && col.ForeignKey.Reference == "id" {
+				return col.Name, "_id"
+			}
+			return col.Name, col.ForeignKey.Reference
+		}
+	}
+	return "", ""
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,141 @@
+package orm
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/donbarrigon/new-project/internal/database/migration"
+)
+
+// setupRelations crea escritores con perfil, libros, capitulos y etiquetas
+// ana tiene los libros 1 y 2, bob el libro 3 y eva ninguno
+func setupRelations(t *testing.T) {
+	t.Helper()
+	setupMemory(t,
+		migration.NewTable("writer", migration.BigIncrements(), migration.String("name")),
+		migration.NewTable("profile", migration.BigIncrements(), migration.UInt64("writer_id"), migration.String("bio")),
+		migration.NewTable("book", migration.BigIncrements(), migration.UInt64("writer_id"), migration.String("title")),
+		migration.NewTable("chapter", migration.BigIncrements(), migration.UInt64("book_id"), migration.String("title")),
+		migration.NewTable("tag", migration.BigIncrements(), migration.String("name")),
+		migration.NewTable("book_tag", migration.BigIncrements(), migration.UInt64("book_id"), migration.UInt64("tag_id"), migration.String("note", "nullable")),
+	)
+
+	book := &Model{}
+	book.Table("book")
+	book.BelongsTo("writer", "writer")
+	book.BelongsToMany("tags", "tag", "").WithPivot("note")
+	Register(book)
+
+	seed := map[string][]map[string]any{
+		"writer":   {{"name": "ana"}, {"name": "bob"}, {"name": "eva"}},
+		"profile":  {{"writer_id": 1, "bio": "bio de ana"}},
+		"book":     {{"writer_id": 1, "title": "uno"}, {"writer_id": 1, "title": "dos"}, {"writer_id": 2, "title": "tres"}},
+		"chapter":  {{"book_id": 1, "title": "1.1"}, {"book_id": 1, "title": "1.2"}, {"book_id": 3, "title": "3.1"}},
+		"tag":      {{"name": "drama"}, {"name": "humor"}},
+		"book_tag": {{"book_id": 1, "tag_id": 1, "note": "principal"}, {"book_id": 1, "tag_id": 2}, {"book_id": 3, "tag_id": 2}},
+	}
+	for _, table := range []string{"writer", "profile", "book", "chapter", "tag", "book_tag"} {
+		m := &Model{}
+		m.Table(table)
+		if _, err := m.InsertMany(seed[table]); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// newWriter crea el modelo de escritores con todas sus relaciones
+func newWriter() *Model {
+	m := &Model{}
+	m.Table("writer")
+	m.HasOne("profile", "profile")
+	m.HasMany("books", "book")
+	m.HasManyThrough("chapters", "chapter", "book")
+	return m
+}
+
+// related retorna el valor de la columna de cada registro de la relacion, uno solo si es HasOne o BelongsTo
+func related(value any, column string) []string {
+	switch v := value.(type) {
+	case map[string]any:
+		if v == nil {
+			return nil
+		}
+		return []string{fmt.Sprint(v[column])}
+	case []map[string]any:
+		result := make([]string, len(v))
+		for i, row := range v {
+			result[i] = fmt.Sprint(row[column])
+		}
+		return result
+	}
+	return nil
+}
+
+func TestEagerLoading(t *testing.T) {
+	setupRelations(t)
+
+	tests := []struct {
+		name     string
+		relation string
+		column   string
+		want     [][]string // por escritor
+	}{
+		{name: "has one", relation: "profile", column: "bio", want: [][]string{{"bio de ana"}, nil, nil}},
+		{name: "has many", relation: "books", column: "title", want: [][]string{{"uno", "dos"}, {"tres"}, {}}},
+		{name: "has many through", relation: "chapters", column: "title", want: [][]string{{"1.1", "1.2"}, {"3.1"}, {}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newWriter().With(tt.relation).OrderBy("id")
+			if err := q.Get(); err != nil {
+				t.Fatal(err)
+			}
+			for i, row := range q.Data {
+				if got := related(row[tt.relation], tt.column); !slices.Equal(got, tt.want[i]) {
+					t.Errorf("%s de %v = %v, se esperaba %v", tt.relation, row["name"], got, tt.want[i])
+				}
+			}
+		})
+	}
+
+	t.Run("belongs to y belongs to many anidados", func(t *testing.T) {
+		q := newWriter().With("books.tags", "books.writer").Where("name", "ana")
+		if err := q.Get(); err != nil {
+			t.Fatal(err)
+		}
+		books := q.Data[0]["books"].([]map[string]any)
+		if got := related(books[0]["tags"], "name"); !slices.Equal(got, []string{"drama", "humor"}) {
+			t.Errorf("tags del libro uno = %v", got)
+		}
+		if got := related(books[1]["tags"], "name"); len(got) != 0 {
+			t.Errorf("tags del libro dos = %v, se esperaba ninguno", got)
+		}
+		tags := books[0]["tags"].([]map[string]any)
+		if pivot, _ := tags[0]["pivot"].(map[string]any); pivot["note"] != "principal" {
+			t.Errorf("pivot = %v, se esperaba la nota principal", tags[0]["pivot"])
+		}
+		if got := related(books[0]["writer"], "name"); !slices.Equal(got, []string{"ana"}) {
+			t.Errorf("writer del libro uno = %v", got)
+		}
+	})
+
+	t.Run("load sobre registros cargados", func(t *testing.T) {
+		q := newWriter()
+		if err := q.Find(2); err != nil {
+			t.Fatal(err)
+		}
+		if err := q.Load("books"); err != nil {
+			t.Fatal(err)
+		}
+		if got := related(q.Data[0]["books"], "title"); !slices.Equal(got, []string{"tres"}) {
+			t.Errorf("books = %v, se esperaba [tres]", got)
+		}
+	})
+
+	t.Run("relacion no definida", func(t *testing.T) {
+		if err := newWriter().With("nada").Get(); err == nil {
+			t.Error("With de una relacion que no existe no retorno error")
+		}
+	})
+}
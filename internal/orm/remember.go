@@ -0,0 +1,93 @@
+package orm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"maps"
+	"time"
+
+	"github.com/donbarrigon/new-project/internal/cache"
+)
+
+// Remember guarda en cache el resultado de la consulta por ttl
+// la key es un hash de la consulta compilada y sus parametros, las entradas se etiquetan con la tabla y las tablas del join
+// crear, actualizar o eliminar registros de la tabla con el orm invalida sus consultas en cache
+// dentro de una transaccion no se usa la cache para no guardar datos sin confirmar
+//
+//	countries.Where("active", true).OrderBy("name").Remember(24 * time.Hour).Get()
+func (m *Model) Remember(ttl time.Duration) *Model {
+	m.rememberTTL = ttl
+	return m
+}
+
+// FlushCache elimina las consultas en cache de las tablas
+// se usa cuando se escribe en la tabla sin pasar por el modelo, por ejemplo con Exec
+func FlushCache(tables ...string) {
+	cache.Flush(tables...)
+}
+
+// remember retorna el resultado en cache de la consulta o ejecuta fn y lo guarda
+// si el modelo no usa Remember o esta en una transaccion solo ejecuta fn
+func remember[T any](m *Model, query any, bindings []any, fn func() (T, error)) (T, error) {
+	if m.rememberTTL <= 0 || m.tx != nil {
+		return fn()
+	}
+	key, ok := m.cacheKey(query, bindings)
+	if !ok {
+		return fn()
+	}
+	if value, ok := cache.Get(key); ok {
+		if result, ok := value.(T); ok {
+			return cloneCached(result), nil
+		}
+	}
+
+	result, err := fn()
+	if err != nil {
+		return result, err
+	}
+	cache.SetTagged(key, cloneCached(result), m.rememberTTL, m.cacheTags()...)
+	return result, nil
+}
+
+// cacheKey retorna la key de la consulta: conexion, tabla y el hash de la consulta con sus parametros
+// ok es false si los parametros no se pueden serializar
+func (m *Model) cacheKey(query any, bindings []any) (string, bool) {
+	data, err := json.Marshal([]any{query, bindings})
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(data)
+	return "orm:" + m.conn().name + ":" + m.tableName + ":" + hex.EncodeToString(sum[:]), true
+}
+
+// cacheTags retorna las etiquetas de la consulta, la tabla y las tablas del join
+func (m *Model) cacheTags() []string {
+	tags := []string{m.tableName}
+	for _, j := range m.joins {
+		tags = append(tags, j.table)
+	}
+	return tags
+}
+
+// flushCache invalida las consultas en cache de la tabla despues de una escritura
+// dentro de una transaccion tambien se invalidan al confirmarla porque las lecturas de otros contextos pudieron guardarse antes
+func (m *Model) flushCache() {
+	cache.Flush(m.tableName)
+	if m.tx != nil {
+		m.tx.written.Store(m.tableName, true)
+	}
+}
+
+// cloneCached copia los registros para que los cambios en `m.Data` no modifiquen la cache
+func cloneCached[T any](value T) T {
+	if rows, ok := any(value).([]map[string]any); ok {
+		clone := make([]map[string]any, len(rows))
+		for i, row := range rows {
+			clone[i] = maps.Clone(row)
+		}
+		return any(clone).(T)
+	}
+	return value
+}
@@ -0,0 +1,112 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/donbarrigon/new-project/internal/cache"
+)
+
+func TestRemember(t *testing.T) {
+	setupMemory(t, postTable(), noteTable())
+	cache.Init()
+	t.Cleanup(cache.Init)
+
+	// el driver memory no usa la cache, la consulta es fija y cuenta cuantas veces se ejecuta
+	// por eso los casos que no deben compartir la key usan otros parametros
+	executed := 0
+	get := func(m *Model, bindings ...any) ([]map[string]any, error) {
+		return remember(m, "SELECT * FROM posts WHERE votes > ?", bindings, func() ([]map[string]any, error) {
+			executed++
+			return []map[string]any{{"id": 1, "title": "post 1"}}, nil
+		})
+	}
+	remembered := func() *Model { return newPost().Remember(time.Minute) }
+
+	tests := []struct {
+		name string
+		run  func() error
+		want int // ejecuciones de la consulta
+	}{
+		{name: "sin Remember", run: func() error {
+			_, err := get(newPost(), 1)
+			return err
+		}, want: 1},
+		{name: "la primera lectura ejecuta la consulta", run: func() error {
+			_, err := get(remembered(), 1)
+			return err
+		}, want: 1},
+		{name: "la segunda lectura usa la cache", run: func() error {
+			_, err := get(remembered(), 1)
+			return err
+		}},
+		{name: "otros parametros", run: func() error {
+			_, err := get(remembered(), 2)
+			return err
+		}, want: 1},
+		{name: "los cambios en el resultado no modifican la cache", run: func() error {
+			rows, err := get(remembered(), 1)
+			if err != nil {
+				return err
+			}
+			rows[0]["title"] = "editado"
+			rows, err = get(remembered(), 1)
+			if err != nil {
+				return err
+			}
+			if rows[0]["title"] != "post 1" {
+				return errors.New("se modifico el registro en cache")
+			}
+			return nil
+		}},
+		{name: "crear un registro invalida la tabla", run: func() error {
+			if err := newPost().Create(map[string]any{"title": "nuevo", "slug": "nuevo"}); err != nil {
+				return err
+			}
+			_, err := get(remembered(), 1)
+			return err
+		}, want: 1},
+		{name: "FlushCache de la tabla del join", run: func() error {
+			if _, err := get(remembered().Join("notes", "notes.slug", "=", "posts.slug"), 3); err != nil {
+				return err
+			}
+			FlushCache("notes")
+			_, err := get(remembered().Join("notes", "notes.slug", "=", "posts.slug"), 3)
+			return err
+		}, want: 2},
+		{name: "dentro de una transaccion no se usa la cache", run: func() error {
+			return Transaction(context.Background(), func(tx *Tx) error {
+				_, err := get(remembered().Tx(tx), 1)
+				return err
+			})
+		}, want: 1},
+		{name: "confirmar la transaccion invalida lo leido durante ella", run: func() error {
+			err := Transaction(context.Background(), func(tx *Tx) error {
+				if err := newPost().Tx(tx).Create(map[string]any{"title": "tx", "slug": "tx"}); err != nil {
+					return err
+				}
+				// otro contexto guarda en cache los datos sin la escritura de la transaccion
+				_, err := get(remembered(), 1)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			_, err = get(remembered(), 1)
+			return err
+		}, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := executed
+			if err := tt.run(); err != nil {
+				t.Fatal(err)
+			}
+			if got := executed - before; got != tt.want {
+				t.Errorf("la consulta se ejecuto %d veces, se esperaba %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultReplicaCheckInterval es cada cuanto se verifica el estado de las replicas si no se define DB_REPLICA_CHECK_INTERVAL
+const defaultReplicaCheckInterval = 30 * time.Second
+
+// replica es una replica de lectura de una conexion sql
+type replica struct {
+	host    string
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// stickyKey es la key del contexto con las conexiones en las que se escribio durante la peticion
+type stickyKey struct{}
+
+// Sticky retorna un contexto que recuerda las escrituras hechas con el
+// en las conexiones con DB_STICKY=true las lecturas posteriores a una escritura van al primario
+// para que el usuario vea sus propios cambios aunque las replicas tengan retraso
+// se asigna una vez por peticion, el middleware Sticky lo hace para cada request
+func Sticky(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(stickyKey{}).(*sync.Map); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, stickyKey{}, &sync.Map{})
+}
+
+// markWrite registra en el contexto que se escribio en la conexion
+func (c *connection) markWrite(ctx context.Context) {
+	if written, ok := ctx.Value(stickyKey{}).(*sync.Map); ok {
+		written.Store(c.name, true)
+	}
+}
+
+// wrote indica si en el contexto ya se escribio en la conexion
+func (c *connection) wrote(ctx context.Context) bool {
+	written, ok := ctx.Value(stickyKey{}).(*sync.Map)
+	if !ok {
+		return false
+	}
+	_, ok = written.Load(c.name)
+	return ok
+}
+
+// reader retorna la conexion para una lectura
+// las replicas sanas se usan en round-robin, si no hay ninguna o la lectura es sticky se usa el primario
+func (c *connection) reader(ctx context.Context) *sql.DB {
+	if len(c.replicas) == 0 || (c.sticky && c.wrote(ctx)) {
+		return c.sql
+	}
+	start := c.next.Add(1)
+	for i := range c.replicas {
+		r := c.replicas[(start+uint64(i))%uint64(len(c.replicas))]
+		if r.healthy.Load() {
+			return r.db
+		}
+	}
+	return c.sql
+}
+
+// openReplicas abre las replicas de DB_READ_HOSTS=host:puerto,host:puerto e inicia su verificacion
+// una replica que no responde no impide abrir la conexion, queda marcada como caida hasta que responda
+func (c *connection) openReplicas() error {
+	for _, host := range strings.Split(c.env("READ_HOSTS"), ",") {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		db, err := c.openDB(splitHost(host, c.env("PORT")))
+		if err != nil {
+			return err
+		}
+		r := &replica{host: host, db: db}
+		r.check()
+		if !r.healthy.Load() {
+			log.Printf("La réplica '%s' de la conexión '%s' no responde.", host, c.name)
+		}
+		c.replicas = append(c.replicas, r)
+	}
+	if len(c.replicas) == 0 {
+		return nil
+	}
+
+	interval, ok := c.envDuration("REPLICA_CHECK_INTERVAL")
+	if !ok || interval <= 0 {
+		interval = defaultReplicaCheckInterval
+	}
+	c.done = make(chan struct{})
+	go c.checkReplicas(interval)
+	return nil
+}
+
+// checkReplicas verifica las replicas cada interval hasta que se cierra la conexion
+func (c *connection) checkReplicas(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			for _, r := range c.replicas {
+				healthy := r.healthy.Load()
+				r.check()
+				if healthy != r.healthy.Load() {
+					log.Printf("La réplica '%s' de la conexión '%s' cambió de estado, disponible: %t.", r.host, c.name, r.healthy.Load())
+				}
+			}
+		}
+	}
+}
+
+// check hace ping a la replica y actualiza su estado
+func (r *replica) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	r.healthy.Store(r.db.PingContext(ctx) == nil)
+}
+
+// splitHost separa host:puerto, si no tiene puerto se usa port
+func splitHost(host string, port string) (string, string) {
+	if i := strings.LastIndex(host, ":"); i > 0 {
+		return host[:i], host[i+1:]
+	}
+	return host, port
+}
@@ -0,0 +1,161 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+// openLazy retorna un pool de mysql sin conectarse, solo se usa para comparar a donde va cada lectura
+func openLazy(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("mysql", "test@tcp(127.0.0.1:1)/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// replicaConnection retorna una conexion con el primario y las replicas, healthy indica cuales estan sanas
+func replicaConnection(t *testing.T, sticky bool, healthy ...bool) *connection {
+	t.Helper()
+	c := &connection{name: "test_replicas", driver: "mysql", sql: openLazy(t), sticky: sticky}
+	for i, ok := range healthy {
+		r := &replica{host: string(rune('a' + i)), db: openLazy(t)}
+		r.healthy.Store(ok)
+		c.replicas = append(c.replicas, r)
+	}
+	return c
+}
+
+func TestReplicaReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		healthy []bool
+		want    []int // indice de la replica de cada lectura, -1 es el primario
+	}{
+		{name: "sin replicas", want: []int{-1, -1}},
+		{name: "round-robin", healthy: []bool{true, true, true}, want: []int{1, 2, 0, 1}},
+		{name: "omite las caidas", healthy: []bool{true, false, true}, want: []int{2, 2, 0, 2}},
+		{name: "todas caidas", healthy: []bool{false, false}, want: []int{-1, -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := replicaConnection(t, false, tt.healthy...)
+			for i, want := range tt.want {
+				expected := c.sql
+				if want >= 0 {
+					expected = c.replicas[want].db
+				}
+				if got := c.reader(context.Background()); got != expected {
+					t.Errorf("lectura %d no fue a %d", i, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStickyReads(t *testing.T) {
+	tests := []struct {
+		name    string
+		sticky  bool
+		ctx     func() context.Context
+		primary bool
+	}{
+		{name: "sin escrituras", sticky: true, ctx: func() context.Context { return Sticky(context.Background()) }},
+		{name: "despues de escribir", sticky: true, primary: true, ctx: func() context.Context {
+			ctx := Sticky(context.Background())
+			(&connection{name: "test_replicas"}).markWrite(ctx)
+			return ctx
+		}},
+		{name: "escritura en otra conexion", sticky: true, ctx: func() context.Context {
+			ctx := Sticky(context.Background())
+			(&connection{name: "analytics"}).markWrite(ctx)
+			return ctx
+		}},
+		{name: "Sticky anidado conserva las escrituras", sticky: true, primary: true, ctx: func() context.Context {
+			ctx := Sticky(context.Background())
+			(&connection{name: "test_replicas"}).markWrite(ctx)
+			return Sticky(ctx)
+		}},
+		{name: "conexion sin sticky", ctx: func() context.Context {
+			ctx := Sticky(context.Background())
+			(&connection{name: "test_replicas"}).markWrite(ctx)
+			return ctx
+		}},
+		{name: "contexto sin Sticky", sticky: true, ctx: func() context.Context {
+			ctx := context.Background()
+			(&connection{name: "test_replicas"}).markWrite(ctx)
+			return ctx
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := replicaConnection(t, tt.sticky, true)
+			if got := c.reader(tt.ctx()) == c.sql; got != tt.primary {
+				t.Errorf("lectura en el primario = %t, se esperaba %t", got, tt.primary)
+			}
+		})
+	}
+}
+
+func TestSplitHost(t *testing.T) {
+	tests := []struct {
+		host     string
+		wantHost string
+		wantPort string
+	}{
+		{host: "db1:3307", wantHost: "db1", wantPort: "3307"},
+		{host: "db1", wantHost: "db1", wantPort: "3306"},
+		{host: "10.0.0.2:3308", wantHost: "10.0.0.2", wantPort: "3308"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			host, port := splitHost(tt.host, "3306")
+			if host != tt.wantHost || port != tt.wantPort {
+				t.Errorf("splitHost = %s, %s, se esperaba %s, %s", host, port, tt.wantHost, tt.wantPort)
+			}
+		})
+	}
+}
+
+func TestRelatedConnection(t *testing.T) {
+	setupMemory(t, postTable(), noteTable())
+	fakeConnection(t, "analytics", "memory")
+	registry.Lock()
+	registry.connections["notes"] = "analytics"
+	registry.Unlock()
+	t.Cleanup(func() {
+		registry.Lock()
+		delete(registry.connections, "notes")
+		registry.Unlock()
+	})
+
+	Transaction(context.Background(), func(tx *Tx) error {
+		tests := []struct {
+			table  string
+			wantTx bool
+		}{
+			{table: "posts", wantTx: true},
+			{table: "notes"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.table, func(t *testing.T) {
+				related := newPost().Tx(tx).newRelated(tt.table)
+				if got := related.tx == tx; got != tt.wantTx {
+					t.Errorf("usa la transaccion = %t, se esperaba %t", got, tt.wantTx)
+				}
+			})
+		}
+
+		t.Run("WithContext en otra conexion", func(t *testing.T) {
+			m := &Model{}
+			m.Table("note")
+			if m.Connection("analytics").WithContext(tx.Context()).tx != nil {
+				t.Error("el modelo de otra conexion tomo la transaccion del contexto")
+			}
+		})
+		return nil
+	})
+}
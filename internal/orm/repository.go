@@ -0,0 +1,428 @@
+package orm
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"reflect"
+	"time"
+)
+
+// Repository da acceso tipado a una tabla, los resultados se retornan como T en lugar de maps
+// comparte el constructor de consultas con Model, cada consulta parte de una copia del modelo base
+//
+//	users := orm.NewRepository[User]()
+//	u, err := users.Find(1)
+//	admins, err := users.Where("role", "admin").OrderBy("name").Get()
+type Repository[T any] struct {
+	model *Model
+}
+
+// NewRepository crea un repositorio para T
+// la tabla se infiere del nombre del tipo: User => users
+// model es opcional, si se pasa se usa su configuracion (tabla, fillable, guarded, relaciones)
+func NewRepository[T any](model ...ModelInterface) *Repository[T] {
+	if len(model) > 0 {
+		return &Repository[T]{model: model[0].getModel().clone()}
+	}
+
+	m := &Model{}
+	m.Table(reflect.TypeOf((*T)(nil)).Elem().Name())
+	return &Repository[T]{model: m}
+}
+
+// query retorna una copia del modelo para construir una consulta nueva
+func (r *Repository[T]) query() *Model {
+	return r.model.clone()
+}
+
+// with retorna un repositorio nuevo con el modelo modificado por fn, el repositorio original no cambia
+func (r *Repository[T]) with(fn func(m *Model)) *Repository[T] {
+	m := r.query()
+	fn(m)
+	return &Repository[T]{model: m}
+}
+
+// Model retorna una copia del modelo base para usar funciones del constructor que el repositorio no expone
+func (r *Repository[T]) Model() *Model {
+	return r.query()
+}
+
+// Where agrega una condicion a la consulta
+func (r *Repository[T]) Where(column string, args ...any) *Repository[T] {
+	return r.with(func(m *Model) { m.Where(column, args...) })
+}
+
+// OrWhere agrega una condicion unida con OR
+func (r *Repository[T]) OrWhere(column string, args ...any) *Repository[T] {
+	return r.with(func(m *Model) { m.OrWhere(column, args...) })
+}
+
+// WhereIn agrega una condicion IN
+func (r *Repository[T]) WhereIn(column string, values any) *Repository[T] {
+	return r.with(func(m *Model) { m.WhereIn(column, values) })
+}
+
+// OrderBy ordena los resultados
+func (r *Repository[T]) OrderBy(column string, direction ...string) *Repository[T] {
+	return r.with(func(m *Model) { m.OrderBy(column, direction...) })
+}
+
+// Limit establece la cantidad maxima de registros
+func (r *Repository[T]) Limit(limit int) *Repository[T] {
+	return r.with(func(m *Model) { m.Limit(limit) })
+}
+
+// Offset establece la cantidad de registros a saltar
+func (r *Repository[T]) Offset(offset int) *Repository[T] {
+	return r.with(func(m *Model) { m.Offset(offset) })
+}
+
+// Scope aplica un scope local del modelo
+func (r *Repository[T]) Scope(name string, args ...any) *Repository[T] {
+	return r.with(func(m *Model) { m.Scope(name, args...) })
+}
+
+// WithoutGlobalScope quita los global scopes de la consulta
+func (r *Repository[T]) WithoutGlobalScope(names ...string) *Repository[T] {
+	return r.with(func(m *Model) { m.WithoutGlobalScope(names...) })
+}
+
+// WithTrashed incluye los registros eliminados con soft deletes
+func (r *Repository[T]) WithTrashed() *Repository[T] {
+	return r.with(func(m *Model) { m.WithTrashed() })
+}
+
+// OnlyTrashed consulta solo los registros eliminados con soft deletes
+func (r *Repository[T]) OnlyTrashed() *Repository[T] {
+	return r.with(func(m *Model) { m.OnlyTrashed() })
+}
+
+// With carga las relaciones, T debe tener los campos para recibirlas
+func (r *Repository[T]) With(names ...string) *Repository[T] {
+	return r.with(func(m *Model) { m.With(names...) })
+}
+
+// Tx vincula las consultas del repositorio a la transaccion
+func (r *Repository[T]) Tx(tx *Tx) *Repository[T] {
+	return r.with(func(m *Model) { m.Tx(tx) })
+}
+
+// Find busca un registro por su clave primaria
+func (r *Repository[T]) Find(id any) (*T, error) {
+	m := r.query()
+	if err := m.Find(id); err != nil {
+		return nil, err
+	}
+	item := new(T)
+	if err := m.Hydrate(item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// All retorna todos los registros de la tabla, ignora las condiciones de la consulta
+func (r *Repository[T]) All() ([]T, error) {
+	items := make([]T, 0)
+	if err := newModelFrom(r.model).Get(&items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Get ejecuta la consulta y retorna los registros
+func (r *Repository[T]) Get() ([]T, error) {
+	items := make([]T, 0)
+	if err := r.query().Get(&items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// First ejecuta la consulta y retorna el primer registro
+func (r *Repository[T]) First() (*T, error) {
+	item := new(T)
+	if err := r.query().First(item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// Count retorna la cantidad de registros que coinciden con la consulta
+func (r *Repository[T]) Count() (int64, error) {
+	return r.query().Count()
+}
+
+// Create inserta entity en la base de datos y le asigna la clave primaria generada
+func (r *Repository[T]) Create(entity *T) error {
+	m := r.query()
+	values, err := m.entityValues(entity, true)
+	if err != nil {
+		return err
+	}
+	if err := m.Create(values); err != nil {
+		return err
+	}
+	// se hidrata de nuevo para asignar la clave primaria
+	return hydrateStruct(map[string]any{m.PrimaryKey(): m.Data[0][m.PrimaryKey()]}, reflect.ValueOf(entity).Elem())
+}
+
+// InsertMany inserta las entidades en lotes, no se les asigna la clave primaria
+func (r *Repository[T]) InsertMany(entities []*T) (int64, error) {
+	m := r.query()
+	rows, err := m.entitiesValues(entities)
+	if err != nil {
+		return 0, err
+	}
+	return m.InsertMany(rows)
+}
+
+// Upsert inserta las entidades y actualiza updateColumns de las que ya existen segun uniqueBy
+func (r *Repository[T]) Upsert(entities []*T, uniqueBy []string, updateColumns []string) (int64, error) {
+	m := r.query()
+	rows, err := m.entitiesValues(entities)
+	if err != nil {
+		return 0, err
+	}
+	return m.Upsert(rows, uniqueBy, updateColumns)
+}
+
+// entitiesValues convierte las entidades en los maps que se guardan
+func (m *Model) entitiesValues(entities any) ([]map[string]any, error) {
+	v := reflect.ValueOf(entities)
+	rows := make([]map[string]any, v.Len())
+	for i := range rows {
+		values, err := m.entityValues(v.Index(i).Interface(), true)
+		if err != nil {
+			return nil, err
+		}
+		rows[i] = values
+	}
+	return rows, nil
+}
+
+// Update guarda los cambios de entity, se identifica por su clave primaria
+// el registro se carga antes de guardarlo para que los eventos y la auditoria tengan los valores anteriores
+// si la tabla usa bloqueo optimista y la version de entity no es la actual retorna ErrStaleModel
+func (r *Repository[T]) Update(entity *T) error {
+	m := r.query()
+	values, err := m.entityValues(entity, false)
+	if err != nil {
+		return err
+	}
+	pk := m.PrimaryKey()
+	id, ok := values[pk]
+	if !ok || id == nil {
+		return fmt.Errorf("el registro no tiene clave primaria '%s'", pk)
+	}
+	delete(values, pk)
+	if err := m.Find(id); err != nil {
+		return err
+	}
+	if err := m.Update(values); err != nil {
+		return err
+	}
+	// la nueva version del bloqueo optimista se copia en entity para poder volver a actualizarlo
+	if column := m.lockColumn(); column != "" {
+		return hydrateStruct(map[string]any{column: m.Data[0][column]}, reflect.ValueOf(entity).Elem())
+	}
+	return nil
+}
+
+// Delete elimina el registro con la clave primaria id, se carga antes para que los eventos tengan sus valores
+func (r *Repository[T]) Delete(id any) error {
+	m := r.query()
+	if err := m.Find(id); err != nil {
+		return err
+	}
+	return m.Delete()
+}
+
+// ForceDelete elimina el registro con la clave primaria id aunque la tabla use soft deletes
+func (r *Repository[T]) ForceDelete(id any) error {
+	m := r.query().WithTrashed()
+	if err := m.Find(id); err != nil {
+		return err
+	}
+	return m.ForceDelete()
+}
+
+// Restore quita la marca de eliminacion al registro con la clave primaria id
+func (r *Repository[T]) Restore(id any) error {
+	m := r.query().WithTrashed()
+	if err := m.Find(id); err != nil {
+		return err
+	}
+	return m.Restore()
+}
+
+// Paginate ejecuta la consulta por paginas, Data de la paginacion es un []T
+func (r *Repository[T]) Paginate(page int, perPage int) (*Pagination, error) {
+	m := r.query()
+	pagination, err := m.Paginate(page, perPage)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]T, 0)
+	if err := m.Hydrate(&items); err != nil {
+		return nil, err
+	}
+	pagination.Data = items
+	return pagination, nil
+}
+
+// CursorPaginate ejecuta la consulta por paginas usando un cursor, Data de la paginacion es un []T
+func (r *Repository[T]) CursorPaginate(perPage int, cursor string) (*CursorPagination, error) {
+	m := r.query()
+	pagination, err := m.CursorPaginate(perPage, cursor)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]T, 0)
+	if err := m.Hydrate(&items); err != nil {
+		return nil, err
+	}
+	pagination.Data = items
+	return pagination, nil
+}
+
+// Chunk recorre los resultados de la consulta en bloques de size registros
+func (r *Repository[T]) Chunk(size int, fn func(items []T) error) error {
+	return r.query().Chunk(size, func(rows []map[string]any) error {
+		items := make([]T, 0, len(rows))
+		if err := hydrate(rows, &items); err != nil {
+			return err
+		}
+		return fn(items)
+	})
+}
+
+// ChunkByID recorre los resultados en bloques de size registros usando la clave primaria en lugar de OFFSET
+func (r *Repository[T]) ChunkByID(size int, fn func(items []T) error) error {
+	return r.query().ChunkByID(size, func(rows []map[string]any) error {
+		items := make([]T, 0, len(rows))
+		if err := hydrate(rows, &items); err != nil {
+			return err
+		}
+		return fn(items)
+	})
+}
+
+// Cursor recorre los resultados de la consulta uno por uno sin cargarlos todos en memoria
+func (r *Repository[T]) Cursor() iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		for row, err := range r.query().Cursor() {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			item := new(T)
+			if err := hydrate([]map[string]any{row}, item); err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(item, nil) {
+				return
+			}
+		}
+	}
+}
+
+// WithContext asigna el contexto de las consultas del repositorio
+func (r *Repository[T]) WithContext(ctx context.Context) *Repository[T] {
+	return r.with(func(m *Model) { m.WithContext(ctx) })
+}
+
+// Timeout asigna el tiempo limite de las consultas del repositorio
+func (r *Repository[T]) Timeout(timeout time.Duration) *Repository[T] {
+	return r.with(func(m *Model) { m.Timeout(timeout) })
+}
+
+// newModelFrom retorna un modelo sin condiciones con la configuracion del modelo base
+func newModelFrom(m *Model) *Model {
+	c := m.clone()
+	c.wheres, c.orders, c.limitValue, c.offsetValue = nil, nil, 0, 0
+	return c
+}
+
+// entityValues convierte el struct en un map columna => valor para guardarlo
+// los campos de relaciones se omiten y los structs, maps y slices se guardan como json en sql
+// si skipEmptyKey es true se omite la clave primaria cuando esta vacia para que la genere la base de datos
+func (m *Model) entityValues(entity any, skipEmptyKey bool) (map[string]any, error) {
+	v := reflect.ValueOf(entity)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("se espera un puntero a un struct, se recibió %T", entity)
+	}
+	v = v.Elem()
+
+	pk := m.PrimaryKey()
+	values := make(map[string]any)
+	for column, index := range typeFields(v.Type()) {
+		if _, ok := m.relation(column); ok {
+			continue
+		}
+		field, ok := fieldByIndexSafe(v, index)
+		if !ok {
+			continue
+		}
+		if column == pk && skipEmptyKey && field.IsZero() {
+			continue
+		}
+		value, err := m.fieldValue(field)
+		if err != nil {
+			return nil, fmt.Errorf("error al convertir la columna '%s': %w", column, err)
+		}
+		values[column] = value
+	}
+	return values, nil
+}
+
+// fieldByIndexSafe retorna el campo sin crear los punteros en nil, false si algun puntero intermedio es nil
+func fieldByIndexSafe(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// fieldValue convierte el valor del campo a un valor que entienda el driver
+func (m *Model) fieldValue(field reflect.Value) (any, error) {
+	if valuer, ok := field.Interface().(driver.Valuer); ok {
+		return valuer.Value()
+	}
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return nil, nil
+		}
+		return m.fieldValue(field.Elem())
+	}
+
+	switch field.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		if field.Type() == timeType {
+			return field.Interface(), nil
+		}
+		// []byte se guarda tal cual
+		if field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Uint8 {
+			return field.Interface(), nil
+		}
+		// mongodb guarda los documentos tal cual
+		if m.driver() == "mongodb" {
+			return field.Interface(), nil
+		}
+		b, err := json.Marshal(field.Interface())
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	}
+	return field.Interface(), nil
+}
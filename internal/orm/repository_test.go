@@ -0,0 +1,97 @@
+package orm
+
+import (
+	"slices"
+	"testing"
+)
+
+type repositoryPost struct {
+	ID      int64  `json:"id"`
+	Title   string `json:"title"`
+	Slug    string `json:"slug"`
+	Votes   int    `json:"votes"`
+	Version int    `json:"version"`
+}
+
+func TestRepositoryWrite(t *testing.T) {
+	setupMemory(t, postTable())
+	seedPosts(t, 10, 20)
+
+	// los eventos reciben los valores anteriores del registro aunque el repositorio solo tenga la clave primaria
+	var original map[string]any
+	var dirty []string
+	for _, event := range []string{EventUpdating, EventDeleting, EventRestoring} {
+		On("posts", event, func(e *ModelEvent) error {
+			original = e.Original
+			dirty = slices.Sorted(func(yield func(string) bool) {
+				for column := range e.Dirty {
+					if !yield(column) {
+						return
+					}
+				}
+			})
+			return nil
+		})
+	}
+	t.Cleanup(func() {
+		observers.Lock()
+		delete(observers.handlers, "posts")
+		observers.Unlock()
+	})
+
+	posts := NewRepository[repositoryPost](newPost())
+	post, err := posts.Find(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := *post
+
+	tests := []struct {
+		name     string
+		write    func() error
+		wantErr  bool
+		original any // title de e.Original en el evento
+		dirty    []string
+		attrs    map[string]any
+		want     int
+	}{
+		{name: "update", write: func() error {
+			post.Title = "editado"
+			return posts.Update(post)
+		}, original: "post 1", dirty: []string{"title"}, attrs: map[string]any{"id": 1, "title": "editado", "version": 2}, want: 1},
+		{name: "update con version vieja", write: func() error {
+			stale.Title = "viejo"
+			return posts.Update(&stale)
+		}, wantErr: true, original: "editado", attrs: map[string]any{"id": 1, "title": "editado", "version": 2}, want: 1},
+		{name: "update de un registro que no existe", write: func() error {
+			return posts.Update(&repositoryPost{ID: 99, Title: "nada"})
+		}, wantErr: true, attrs: map[string]any{"title": "nada"}, want: 0},
+		{name: "delete", write: func() error { return posts.Delete(2) },
+			original: "post 2", dirty: []string{"deleted_at"}, attrs: map[string]any{"id": 2, "deleted_at": nil}, want: 0},
+		{name: "restore", write: func() error { return posts.Restore(2) },
+			original: "post 2", dirty: []string{"deleted_at"}, attrs: map[string]any{"id": 2, "deleted_at": nil}, want: 1},
+		{name: "force delete", write: func() error { return posts.ForceDelete(2) },
+			attrs: map[string]any{"id": 2}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original, dirty = nil, nil
+			if err := tt.write(); (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, se esperaba error: %v", err, tt.wantErr)
+			}
+			if tt.original != nil && original["title"] != tt.original {
+				t.Errorf("original[title] = %v, se esperaba %v", original["title"], tt.original)
+			}
+			if tt.dirty != nil && !slices.Equal(dirty, tt.dirty) {
+				t.Errorf("dirty = %v, se esperaba %v", dirty, tt.dirty)
+			}
+			if n, _ := MemoryCount("posts", tt.attrs); n != tt.want {
+				t.Errorf("registros con %v = %d, se esperaba %d", tt.attrs, n, tt.want)
+			}
+		})
+	}
+
+	if post.Version != 2 {
+		t.Errorf("version de la entidad = %d, se esperaba 2", post.Version)
+	}
+}
@@ -0,0 +1,142 @@
+package orm
+
+import (
+	"fmt"
+	"slices"
+)
+
+// ScopeFunc agrega condiciones a la consulta, args son los parametros que se pasan en Scope
+type ScopeFunc func(q *Model, args ...any)
+
+// globalScope es un scope que se aplica a todas las consultas de la tabla
+type globalScope struct {
+	name string
+	fn   func(q *Model)
+}
+
+// scopeSet son los scopes definidos para una tabla
+// se comparte entre las copias del modelo y se registra con Register para las relaciones
+type scopeSet struct {
+	local      map[string]ScopeFunc
+	global     []globalScope
+	softDelete string // columna de soft deletes, vacio si la tabla no usa soft deletes
+	central    bool   // la tabla no pertenece a los tenants
+}
+
+// AddScope define un scope local que se aplica con Scope, se usa en el constructor del modelo
+//
+//	model.AddScope("active", func(q *orm.Model, args ...any) { q.Where("active", true) })
+//	model.AddScope("role", func(q *orm.Model, args ...any) { q.Where("role", args[0]) })
+func (m *Model) AddScope(name string, fn ScopeFunc) {
+	m.scopeDefs(true).local[name] = fn
+}
+
+// AddGlobalScope define un scope que se aplica automaticamente a todas las consultas de la tabla
+// si ya existe un scope con el mismo nombre se reemplaza
+func (m *Model) AddGlobalScope(name string, fn func(q *Model)) {
+	s := m.scopeDefs(true)
+	for i, g := range s.global {
+		if g.name == name {
+			s.global[i].fn = fn
+			return
+		}
+	}
+	s.global = append(s.global, globalScope{name: name, fn: fn})
+}
+
+// Scope aplica el scope local a la consulta: users.Scope("active").Scope("role", "admin").Get()
+func (m *Model) Scope(name string, args ...any) *Model {
+	if m.err != nil {
+		return m
+	}
+	s := m.scopeDefs(false)
+	if s == nil || s.local[name] == nil {
+		m.err = fmt.Errorf("el scope '%s' no está definido en '%s'", name, m.tableName)
+		return m
+	}
+	s.local[name](m, args...)
+	return m
+}
+
+// WithoutGlobalScope quita los global scopes de la consulta
+func (m *Model) WithoutGlobalScope(names ...string) *Model {
+	m.withoutScopes = append(m.withoutScopes, names...)
+	return m
+}
+
+// WithoutGlobalScopes quita todos los global scopes de la consulta
+func (m *Model) WithoutGlobalScopes() *Model {
+	m.withoutScopes = append(m.withoutScopes, "*")
+	return m
+}
+
+// scopeDefs retorna los scopes del modelo y si no tiene los registrados para la tabla
+// si create es true y el modelo no tiene scopes se crean
+func (m *Model) scopeDefs(create bool) *scopeSet {
+	if m.scopes != nil {
+		return m.scopes
+	}
+	if create {
+		m.scopes = &scopeSet{local: make(map[string]ScopeFunc)}
+		return m.scopes
+	}
+	registry.RLock()
+	defer registry.RUnlock()
+	return registry.scopes[m.tableName]
+}
+
+// activeGlobalScopes retorna los global scopes que no se quitaron con WithoutGlobalScope
+func (m *Model) activeGlobalScopes() []globalScope {
+	s := m.scopeDefs(false)
+	if s == nil || slices.Contains(m.withoutScopes, "*") {
+		return nil
+	}
+	scopes := make([]globalScope, 0, len(s.global))
+	for _, g := range s.global {
+		if !slices.Contains(m.withoutScopes, g.name) {
+			scopes = append(scopes, g)
+		}
+	}
+	return scopes
+}
+
+// scopeWheres retorna las condiciones de los global scopes, cada scope queda en un grupo entre parentesis
+// en tenancy por columna se agrega la condicion del tenant, no depende de WithoutGlobalScope
+func (m *Model) scopeWheres() ([]where, error) {
+	q := &Model{tableName: m.tableName, table: m.table, hasMigration: m.hasMigration}
+	for _, g := range m.activeGlobalScopes() {
+		q.addGroup("and", g.fn)
+	}
+	if q.err != nil {
+		return nil, q.err
+	}
+	tenant, err := m.tenantWhere()
+	if err != nil {
+		return nil, err
+	}
+	return append(q.wheres, tenant...), nil
+}
+
+// applyScopes agrega las condiciones de los global scopes a la consulta antes de ejecutarla
+// si la consulta tiene condiciones con OR se agrupan para que el scope aplique a todas
+func (m *Model) applyScopes() error {
+	if m.scopesApplied {
+		return nil
+	}
+	m.scopesApplied = true
+
+	wheres, err := m.scopeWheres()
+	if err != nil {
+		m.err = err
+		return err
+	}
+	if len(wheres) == 0 {
+		return nil
+	}
+
+	if slices.ContainsFunc(m.wheres, func(w where) bool { return w.boolean == "or" }) {
+		m.wheres = []where{{boolean: "and", group: m.wheres}}
+	}
+	m.wheres = append(m.wheres, wheres...)
+	return nil
+}
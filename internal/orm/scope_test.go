@@ -0,0 +1,53 @@
+package orm
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestScopes(t *testing.T) {
+	setupMemory(t, postTable())
+	seedPosts(t, 10, 30, 20, 50, 40)
+
+	// newScoped crea el modelo con un scope local con argumentos y un global scope
+	newScoped := func() *Model {
+		m := newPost()
+		m.AddScope("popular", func(q *Model, args ...any) { q.Where("votes", ">=", args[0]) })
+		m.AddGlobalScope("sin_primero", func(q *Model) { q.Where("id", "!=", 1) })
+		return m
+	}
+
+	tests := []struct {
+		name  string
+		query func(q *Model) *Model
+		want  []int64
+	}{
+		{name: "global", query: func(q *Model) *Model { return q }, want: []int64{2, 3, 4, 5}},
+		{name: "local con argumentos", query: func(q *Model) *Model { return q.Scope("popular", 40) }, want: []int64{4, 5}},
+		{name: "sin el global", query: func(q *Model) *Model { return q.WithoutGlobalScope("sin_primero").Scope("popular", 10) }, want: []int64{1, 2, 3, 4, 5}},
+		{name: "sin globales", query: func(q *Model) *Model { return q.WithoutGlobalScopes().Where("votes", "<", 25) }, want: []int64{1, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.query(newScoped()).OrderBy("id")
+			if err := q.Get(); err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(q); !slices.Equal(got, tt.want) {
+				t.Errorf("ids = %v, se esperaba %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("el global se aplica en find", func(t *testing.T) {
+		if err := newScoped().Find(1); err == nil {
+			t.Error("Find encontro un registro excluido por el global scope")
+		}
+	})
+
+	t.Run("scope no definido", func(t *testing.T) {
+		if err := newScoped().Scope("no_existe").Get(); err == nil {
+			t.Error("un scope que no existe no retorno error")
+		}
+	})
+}
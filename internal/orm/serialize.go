@@ -0,0 +1,154 @@
+package orm
+
+import (
+	"encoding/json"
+	"slices"
+)
+
+// Hidden establece las columnas que no se incluyen al convertir el modelo a json, por ejemplo el password
+// tambien se aplica a la tabla cuando se carga como relacion de otro modelo si el modelo se registro con Register
+func (m *Model) Hidden(fields ...string) {
+	m.attributeDefs(true).hidden = fields
+}
+
+// Visible establece las unicas columnas que se incluyen al convertir el modelo a json
+func (m *Model) Visible(fields ...string) {
+	m.attributeDefs(true).visible = fields
+}
+
+// Append agrega al json columnas calculadas con un Accessor
+// los accessors de estas columnas no se ejecutan al leer los registros, solo al convertirlos a json
+//
+//	model.Accessor("full_name", func(value any, row map[string]any) any {
+//		return fmt.Sprint(row["first_name"], " ", row["last_name"])
+//	})
+//	model.Append("full_name")
+func (m *Model) Append(fields ...string) {
+	a := m.attributeDefs(true)
+	a.appends = append(a.appends, fields...)
+}
+
+// MakeVisible muestra columnas ocultas solo en esta instancia del modelo: users.MakeVisible("email").Find(id)
+func (m *Model) MakeVisible(fields ...string) *Model {
+	m.makeHidden = slices.DeleteFunc(m.makeHidden, func(f string) bool { return slices.Contains(fields, f) })
+	m.makeVisible = append(m.makeVisible, fields...)
+	return m
+}
+
+// MakeHidden oculta columnas solo en esta instancia del modelo
+func (m *Model) MakeHidden(fields ...string) *Model {
+	m.makeVisible = slices.DeleteFunc(m.makeVisible, func(f string) bool { return slices.Contains(fields, f) })
+	m.makeHidden = append(m.makeHidden, fields...)
+	return m
+}
+
+// MarshalJSON convierte `m.Data` a json sin las columnas ocultas y con las columnas de Append
+// si el registro se cargo con Find, First o Create se retorna un objeto, si no un arreglo
+func (m *Model) MarshalJSON() ([]byte, error) {
+	if m.single && len(m.Data) == 1 {
+		return json.Marshal(m.ToMap(0))
+	}
+	return json.Marshal(m.ToMaps())
+}
+
+// ToMap retorna el registro i de `m.Data` como se convierte a json
+func (m *Model) ToMap(i int) map[string]any {
+	if i < 0 || i >= len(m.Data) {
+		return nil
+	}
+	return m.serializeRow(m.Data[i])
+}
+
+// ToMaps retorna los registros de `m.Data` como se convierten a json
+func (m *Model) ToMaps() []map[string]any {
+	rows := make([]map[string]any, len(m.Data))
+	for i, row := range m.Data {
+		rows[i] = m.serializeRow(row)
+	}
+	return rows
+}
+
+// serializeRow copia el registro sin las columnas ocultas, con las columnas de Append
+// y con las relaciones cargadas serializadas segun la configuracion de su tabla
+func (m *Model) serializeRow(row map[string]any) map[string]any {
+	a := m.attributeDefs(false)
+	result := make(map[string]any, len(row))
+	for column, value := range row {
+		if !m.isVisible(a, column) {
+			continue
+		}
+		if r, ok := m.relation(column); ok {
+			if related, ok := relatedSerializer(r, row); ok {
+				value = related.serializeValue(value)
+			} else {
+				value = nil
+			}
+		}
+		result[column] = value
+	}
+	if a == nil {
+		return result
+	}
+	for _, column := range a.appends {
+		if !m.isVisible(a, column) {
+			continue
+		}
+		if fn := a.accessors[column]; fn != nil {
+			result[column] = fn(row[column], row)
+		}
+	}
+	return result
+}
+
+// relatedSerializer retorna el modelo con la configuracion de la tabla de la relacion para serializarla
+// en MorphTo la tabla depende del tipo guardado en el registro, si el tipo no es valido la relacion se serializa vacia
+func relatedSerializer(r *Relation, row map[string]any) (*Model, bool) {
+	if r.Type != MorphToRelation {
+		return newModel(r.Related), true
+	}
+	table, err := morphTable(keyString(row[r.MorphType]))
+	if err != nil {
+		return nil, false
+	}
+	return newModel(table), true
+}
+
+// serializeValue serializa los registros de una relacion cargada, uno solo o un slice
+func (m *Model) serializeValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		return m.serializeRow(v)
+	case []map[string]any:
+		rows := make([]map[string]any, len(v))
+		for i, row := range v {
+			rows[i] = m.serializeRow(row)
+		}
+		return rows
+	case []any:
+		// las relaciones cargadas con $lookup en mongodb
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = m.serializeValue(item)
+		}
+		return items
+	}
+	return value
+}
+
+// isVisible indica si la columna se incluye en el json
+// MakeHidden y MakeVisible tienen prioridad sobre Visible y Hidden
+func (m *Model) isVisible(a *attributeSet, column string) bool {
+	if slices.Contains(m.makeHidden, column) {
+		return false
+	}
+	if slices.Contains(m.makeVisible, column) {
+		return true
+	}
+	if a == nil {
+		return true
+	}
+	if len(a.visible) > 0 && !slices.Contains(a.visible, column) {
+		return false
+	}
+	return !slices.Contains(a.hidden, column)
+}
@@ -0,0 +1,53 @@
+package orm
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSerializeMorphToHidden(t *testing.T) {
+	author := &Model{}
+	author.Table("serialize_author")
+	author.Hidden("password")
+	Register(author)
+	MorphMap(map[string]string{"serialize_author": "serialize_author"})
+
+	tests := []struct {
+		name      string
+		morphType string
+		want      []string
+		notWant   []string
+	}{
+		{name: "tipo registrado", morphType: "serialize_author", want: []string{`"name":"Ana"`}, notWant: []string{"secret"}},
+		{name: "tipo invalido", morphType: "no-existe;", want: []string{`"commentable":null`}, notWant: []string{"secret", "Ana"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comment := &Model{}
+			comment.Table("serialize_comment")
+			comment.MorphTo("commentable")
+			comment.Data = []map[string]any{{
+				"id":               1,
+				"commentable_type": tt.morphType,
+				"commentable_id":   1,
+				"commentable":      map[string]any{"id": 1, "name": "Ana", "password": "secret"},
+			}}
+
+			b, err := json.Marshal(comment)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(string(b), s) {
+					t.Errorf("el json %s no tiene %s", b, s)
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(string(b), s) {
+					t.Errorf("el json %s tiene %s", b, s)
+				}
+			}
+		})
+	}
+}
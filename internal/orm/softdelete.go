@@ -0,0 +1,74 @@
+package orm
+
+import (
+	"fmt"
+	"time"
+)
+
+// softDeleteScope es el nombre del global scope que oculta los registros eliminados
+const softDeleteScope = "soft_deletes"
+
+// SoftDeletes hace que Delete marque los registros con la fecha de eliminacion en lugar de borrarlos
+// las consultas ignoran los registros marcados, se incluyen con WithTrashed o OnlyTrashed
+// column es opcional por defecto deleted_at, la columna que crea migration.DeletedAt()
+func (m *Model) SoftDeletes(column ...string) {
+	col := "deleted_at"
+	if len(column) > 0 {
+		col = column[0]
+	}
+	m.scopeDefs(true).softDelete = col
+	m.AddGlobalScope(softDeleteScope, func(q *Model) { q.WhereNull(col) })
+}
+
+// softDeleteColumn retorna la columna de soft deletes, vacio si la tabla no usa soft deletes
+func (m *Model) softDeleteColumn() string {
+	if s := m.scopeDefs(false); s != nil {
+		return s.softDelete
+	}
+	return ""
+}
+
+// WithTrashed incluye los registros eliminados en la consulta
+func (m *Model) WithTrashed() *Model {
+	return m.WithoutGlobalScope(softDeleteScope)
+}
+
+// OnlyTrashed consulta solo los registros eliminados
+func (m *Model) OnlyTrashed() *Model {
+	column := m.softDeleteColumn()
+	if column == "" {
+		if m.err == nil {
+			m.err = fmt.Errorf("la tabla '%s' no usa soft deletes", m.tableName)
+		}
+		return m
+	}
+	return m.WithTrashed().WhereNotNull(column)
+}
+
+// Restore quita la marca de eliminacion a los registros cargados en `m.Data`
+// por cada registro se disparan los eventos restoring y restored
+func (m *Model) Restore() error {
+	column := m.softDeleteColumn()
+	if column == "" {
+		return fmt.Errorf("la tabla '%s' no usa soft deletes", m.tableName)
+	}
+	if len(m.Data) == 0 {
+		return fmt.Errorf("no hay registros cargados para restaurar")
+	}
+	return m.updateRows(map[string]any{column: nil}, []string{EventRestoring}, []string{EventRestored})
+}
+
+// ForceDelete elimina de la base de datos los registros cargados en `m.Data` aunque la tabla use soft deletes
+func (m *Model) ForceDelete() error {
+	return m.delete(true)
+}
+
+// softDelete marca los registros cargados en `m.Data` con la fecha de eliminacion
+// se disparan los eventos deleting y deleted igual que al eliminar
+func (m *Model) softDelete(column string) error {
+	if err := m.updateRows(map[string]any{column: time.Now()}, []string{EventDeleting}, []string{EventDeleted}); err != nil {
+		return err
+	}
+	m.Data = nil
+	return nil
+}
@@ -0,0 +1,145 @@
+package orm
+
+import (
+	"fmt"
+	"iter"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Cursor recorre los resultados de la consulta uno por uno sin cargarlos todos en memoria
+// el cursor de la base de datos se cierra al terminar el recorrido, al salir del for con break o al cancelar el contexto
+// las relaciones de With no se cargan, para eso se debe usar Chunk o ChunkByID
+// dentro de una transaccion de sql no se pueden ejecutar otras consultas de la transaccion mientras se recorre el cursor
+//
+//	for row, err := range users.Where("active", true).Cursor() {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func (m *Model) Cursor() iter.Seq2[map[string]any, error] {
+	return func(yield func(map[string]any, error) bool) {
+		if m.err != nil {
+			yield(nil, m.err)
+			return
+		}
+		if err := m.applyScopes(); err != nil {
+			yield(nil, err)
+			return
+		}
+		if len(m.with) > 0 {
+			yield(nil, fmt.Errorf("Cursor no carga relaciones, use Chunk o ChunkByID"))
+			return
+		}
+
+		// Usar un map para los drivers soportados
+		cursorFuncs := map[string]func(func(map[string]any, error) bool){
+			"mongodb":    m.cursorMongoDB,
+			"mysql":      m.cursorMySQL,
+			"postgresql": m.cursorMySQL,
+			"memory":     m.cursorMemory,
+		}
+
+		cursorFunc, ok := cursorFuncs[m.driver()]
+		if !ok {
+			yield(nil, m.conn().unsupported())
+			return
+		}
+		// los registros se entregan con los casts y accessors aplicados
+		cursorFunc(func(row map[string]any, err error) bool {
+			if err == nil {
+				if err = m.castRows([]map[string]any{row}); err != nil {
+					yield(nil, err)
+					return false
+				}
+			}
+			return yield(row, err)
+		})
+	}
+}
+
+// cursorMySQL recorre los resultados de la consulta en mysql o postgresql
+func (m *Model) cursorMySQL(yield func(map[string]any, error) bool) {
+	query, args := m.compileSelect()
+
+	ctx, cancel := m.streamContext()
+	defer cancel()
+
+	start := time.Now()
+	rows, err := m.sqlReader().QueryContext(ctx, query, args...)
+	// las filas se leen a medida que se recorren, solo se mide la ejecucion de la consulta
+	m.record(ctx, start, query, args, -1, err)
+	if err != nil {
+		yield(nil, dbError("error en la consulta", err))
+		return
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		yield(nil, dbError("error al leer las columnas", err))
+		return
+	}
+
+	for rows.Next() {
+		row, err := scanRow(rows, columns)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		if !yield(row, nil) {
+			return
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		yield(nil, dbError("error al recorrer los resultados", err))
+	}
+}
+
+// cursorMongoDB recorre los documentos de la consulta en mongodb
+func (m *Model) cursorMongoDB(yield func(map[string]any, error) bool) {
+	ctx, cancel := m.streamContext()
+	defer cancel()
+
+	collection := m.collection()
+
+	var cursor *mongo.Cursor
+	var err error
+	start := time.Now()
+	if m.usesPipeline() {
+		var pipeline mongo.Pipeline
+		if pipeline, err = m.mongoPipeline(); err != nil {
+			yield(nil, err)
+			return
+		}
+		cursor, err = collection.Aggregate(ctx, pipeline)
+		m.record(ctx, start, m.mongoQuery("aggregate"), []any{pipeline}, -1, err)
+	} else {
+		filter := m.mongoFilter()
+		cursor, err = collection.Find(ctx, filter, m.mongoFindOptions())
+		m.record(ctx, start, m.mongoQuery("find"), []any{filter}, -1, err)
+	}
+	if err != nil {
+		yield(nil, dbError("error en consulta MongoDB", err))
+		return
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		row := make(map[string]any)
+		if err := cursor.Decode(&row); err != nil {
+			yield(nil, dbError("error al leer el documento", err))
+			return
+		}
+		if !yield(row, nil) {
+			return
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		yield(nil, dbError("error al recorrer los documentos", err))
+	}
+}
@@ -0,0 +1,257 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"sync"
+)
+
+// modos de tenancy segun TENANCY_MODE
+const (
+	TenancyColumn   = "column"   // todos los tenants comparten las tablas y se separan por la columna TENANCY_COLUMN
+	TenancyDatabase = "database" // cada tenant tiene su base de datos TENANCY_PREFIX + tenant
+	TenancySchema   = "schema"   // cada tenant tiene su schema de postgresql TENANCY_PREFIX + tenant
+)
+
+// ErrNoTenant es el error de las consultas de tablas de tenant sin un tenant en el contexto
+var ErrNoTenant = errors.New("la consulta requiere un tenant y el contexto no tiene uno")
+
+// tenantKey es la key del contexto con el tenant
+type tenantKey struct{}
+
+// tenancy es la configuracion de tenancy de las variables de entorno
+type tenancy struct {
+	mode   string // vacio si no se usa tenancy
+	column string // columna del tenant en modo column
+	prefix string // prefijo de la base de datos o schema de cada tenant
+}
+
+// tenancyConfig lee la configuracion de tenancy una sola vez
+var tenancyConfig = sync.OnceValue(func() tenancy {
+	t := tenancy{mode: os.Getenv("TENANCY_MODE"), column: os.Getenv("TENANCY_COLUMN"), prefix: os.Getenv("TENANCY_PREFIX")}
+	if t.column == "" {
+		t.column = "tenant_id"
+	}
+	if t.prefix == "" {
+		t.prefix = "tenant_"
+	}
+	return t
+})
+
+// TenancyMode retorna el modo de tenancy de TENANCY_MODE, vacio si no se usa
+func TenancyMode() string {
+	return tenancyConfig().mode
+}
+
+// WithTenant retorna un contexto con el tenant, las consultas de las tablas de tenant hechas con el se limitan a ese tenant
+// el id solo puede tener letras, numeros y _ porque en los modos database y schema es parte del nombre de la base de datos
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// TenantID retorna el tenant del contexto
+func TenantID(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(tenantKey{}).(string)
+	return id, ok && id != ""
+}
+
+// Central marca la tabla como central, no pertenece a ningun tenant
+// en modo column no se filtra por tenant y en los modos database y schema se consulta en la conexion default
+// se usa en el constructor del modelo, todas las demas tablas son de tenant cuando hay tenancy
+func (m *Model) Central() {
+	m.scopeDefs(true).central = true
+}
+
+// WithoutTenant quita el filtro del tenant en modo column, por ejemplo en reportes de administracion
+// es la unica forma de consultar una tabla de tenant sin tenant, WithoutGlobalScopes no lo quita
+func (m *Model) WithoutTenant() *Model {
+	m.withoutTenant = true
+	return m
+}
+
+// isCentral indica si la tabla no pertenece a los tenants, por Central en el modelo o en la migracion
+func (m *Model) isCentral() bool {
+	if m.table != nil && m.table.Central {
+		return true
+	}
+	s := m.scopeDefs(false)
+	return s != nil && s.central
+}
+
+// tenantColumn retorna la columna del tenant si la tabla se separa por columna, vacio si no
+func (m *Model) tenantColumn() string {
+	if t := tenancyConfig(); t.mode == TenancyColumn && !m.isCentral() {
+		return t.column
+	}
+	return ""
+}
+
+// tenantWhere retorna la condicion del tenant de la consulta en modo column
+func (m *Model) tenantWhere() ([]where, error) {
+	column := m.tenantColumn()
+	if column == "" || m.withoutTenant {
+		return nil, nil
+	}
+	id, ok := TenantID(m.baseContext())
+	if !ok {
+		return nil, fmt.Errorf("tabla '%s': %w", m.tableName, ErrNoTenant)
+	}
+	q := &Model{tableName: m.tableName, table: m.table, hasMigration: m.hasMigration}
+	q.Where(column, id)
+	return q.wheres, q.err
+}
+
+// applyTenant asigna el tenant del contexto a los valores que se van a guardar en modo column
+// al crear se asigna la columna y al actualizar no se permite cambiarla, en ambos casos otro tenant es un error
+// con WithoutTenant no se asigna, el valor de la columna debe venir en values
+// al crear retorna una copia de values, se llama antes de fillData para que los eventos y los mutators vean el tenant
+func (m *Model) applyTenant(values map[string]any, create bool) (map[string]any, error) {
+	column := m.tenantColumn()
+	if column == "" || m.withoutTenant {
+		return values, nil
+	}
+	id, ok := TenantID(m.baseContext())
+	if !ok {
+		return nil, fmt.Errorf("tabla '%s': %w", m.tableName, ErrNoTenant)
+	}
+	if value, set := values[column]; set && fmt.Sprint(value) != id {
+		return nil, fmt.Errorf("no se puede guardar un registro de otro tenant en '%s'", m.tableName)
+	}
+	if create {
+		values = maps.Clone(values)
+		if values == nil {
+			values = make(map[string]any, 1)
+		}
+		values[column] = id
+	}
+	return values, nil
+}
+
+// tenantMatch agrega a match la condicion del tenant que se usa en el WHERE de UPDATE y DELETE en modo column
+// aunque los registros se cargaron con el filtro del tenant, asi tampoco se modifican registros de otro tenant puestos a mano en `m.Data`
+func (m *Model) tenantMatch(match map[string]any) map[string]any {
+	column := m.tenantColumn()
+	if column == "" || m.withoutTenant {
+		return match
+	}
+	id, ok := TenantID(m.baseContext())
+	if !ok {
+		return match
+	}
+	if match == nil {
+		match = make(map[string]any, 1)
+	}
+	match[column] = id
+	return match
+}
+
+// usesTenantConnection indica si el modelo se consulta en la conexion del tenant
+// solo las tablas de tenant de la conexion default cambian de conexion en los modos database y schema
+func (m *Model) usesTenantConnection() bool {
+	return tenantPerDatabase() && (m.connName == "" || m.connName == DefaultConnection) && !m.isCentral()
+}
+
+// tenantPerDatabase indica si cada tenant tiene su base de datos o schema
+func tenantPerDatabase() bool {
+	mode := tenancyConfig().mode
+	return mode == TenancyDatabase || mode == TenancySchema
+}
+
+// tenantConnection retorna la conexion del tenant del contexto, la abre la primera vez que se usa
+// si el contexto no tiene tenant retorna una conexion con ErrNoTenant para que la consulta falle
+func tenantConnection(ctx context.Context) *connection {
+	id, ok := TenantID(ctx)
+	if !ok {
+		return &connection{name: "tenant", err: ErrNoTenant}
+	}
+	name := "tenant:" + id
+
+	connections.RLock()
+	conn, ok := connections.m[name]
+	connections.RUnlock()
+	if ok {
+		return conn
+	}
+
+	// se abre sin el lock para no detener las consultas de los demas tenants mientras se conecta
+	conn, err := openTenantConnection(id)
+	if err != nil {
+		return &connection{name: name, err: err}
+	}
+	connections.Lock()
+	defer connections.Unlock()
+	if existing, ok := connections.m[name]; ok {
+		// otra consulta abrio la conexion al mismo tiempo
+		conn.close()
+		return existing
+	}
+	connections.m[name] = conn
+	return conn
+}
+
+// openTenantConnection abre la conexion del tenant con las variables de la conexion default
+// en modo database cambia la base de datos y en modo schema el search_path de postgresql
+func openTenantConnection(id string) (*connection, error) {
+	if !isIdentifier(id) {
+		return nil, fmt.Errorf("el tenant '%s' no es válido", id)
+	}
+	t := tenancyConfig()
+	conn := &connection{name: "tenant:" + id, config: DefaultConnection, driver: env(DefaultConnection, "DRIVER")}
+	if conn.driver == "" {
+		conn.driver = "mongodb"
+	}
+	if t.mode == TenancySchema {
+		if conn.driver != "postgresql" {
+			return nil, fmt.Errorf("tenancy por schema solo es compatible con postgresql")
+		}
+		conn.schema = t.prefix + id
+	} else {
+		conn.database = t.prefix + id
+	}
+	if err := conn.open(); err != nil {
+		return nil, fmt.Errorf("error al conectar con la base de datos del tenant '%s': %w", id, err)
+	}
+	return conn, nil
+}
+
+// CreateTenantDatabase crea la base de datos o el schema del tenant en la conexion default si no existe
+// en mongodb la base de datos se crea al escribir el primer documento
+func CreateTenantDatabase(ctx context.Context, id string) error {
+	if !isIdentifier(id) {
+		return fmt.Errorf("el tenant '%s' no es válido", id)
+	}
+	if !tenantPerDatabase() {
+		return nil
+	}
+	t := tenancyConfig()
+	conn := getConnection(DefaultConnection)
+	name := t.prefix + id
+
+	ctx, cancel := withTimeout(ctx, 0)
+	defer cancel()
+
+	var err error
+	switch {
+	case conn.driver == "mongodb":
+		return nil
+	case t.mode == TenancySchema:
+		_, err = conn.sql.ExecContext(ctx, "CREATE SCHEMA IF NOT EXISTS "+name)
+	case conn.driver == "mysql":
+		_, err = conn.sql.ExecContext(ctx, "CREATE DATABASE IF NOT EXISTS "+name)
+	case conn.driver == "postgresql":
+		// postgresql no tiene CREATE DATABASE IF NOT EXISTS
+		var exists bool
+		if err = conn.sql.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)", name).Scan(&exists); err == nil && !exists {
+			_, err = conn.sql.ExecContext(ctx, "CREATE DATABASE "+name)
+		}
+	default:
+		return conn.unsupported()
+	}
+	if err != nil {
+		return dbError(fmt.Sprintf("error al crear la base de datos del tenant '%s'", id), err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/donbarrigon/new-project/internal/database/migration"
+)
+
+// columnTenancy activa tenancy por columna durante la prueba
+func columnTenancy(t *testing.T) {
+	t.Helper()
+	config := tenancyConfig
+	tenancyConfig = func() tenancy { return tenancy{mode: TenancyColumn, column: "tenant_id", prefix: "tenant_"} }
+	t.Cleanup(func() { tenancyConfig = config })
+}
+
+func TestTenancyColumn(t *testing.T) {
+	columnTenancy(t)
+	setupMemory(t, migration.NewTable("tenant_note",
+		migration.BigIncrements(),
+		migration.String("body"),
+		migration.TenantID(),
+	))
+
+	// el mutator ve el tenant aunque tenant_id no sea fillable
+	newNote := func(ctx context.Context) *Model {
+		m := &Model{}
+		m.Table("tenant_note")
+		m.Fillable("body")
+		m.Mutator("body", func(value any, row map[string]any) (any, error) {
+			return fmt.Sprint(row["tenant_id"], ": ", value), nil
+		})
+		return m.WithContext(ctx)
+	}
+	background := context.Background()
+	a, b := WithTenant(background, "a"), WithTenant(background, "b")
+
+	writes := []struct {
+		name    string
+		ctx     context.Context
+		data    map[string]any
+		wantErr bool
+	}{
+		{name: "sin tenant", ctx: background, data: map[string]any{"body": "x"}, wantErr: true},
+		{name: "tenant a", ctx: a, data: map[string]any{"body": "uno"}},
+		{name: "tenant a con su columna", ctx: a, data: map[string]any{"body": "dos", "tenant_id": "a"}},
+		{name: "tenant b", ctx: b, data: map[string]any{"body": "tres"}},
+		{name: "otro tenant", ctx: b, data: map[string]any{"body": "cuatro", "tenant_id": "a"}, wantErr: true},
+	}
+	for _, tt := range writes {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newNote(tt.ctx).Create(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, se esperaba error: %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	for _, attrs := range []map[string]any{
+		{"tenant_id": "a", "body": "a: uno"},
+		{"tenant_id": "a", "body": "a: dos"},
+		{"tenant_id": "b", "body": "b: tres"},
+	} {
+		if n, _ := MemoryCount("tenant_notes", attrs); n != 1 {
+			t.Errorf("no hay un registro con %v", attrs)
+		}
+	}
+
+	reads := []struct {
+		name    string
+		query   *Model
+		want    int64
+		wantErr error
+	}{
+		{name: "tenant a", query: newNote(a), want: 2},
+		{name: "tenant b", query: newNote(b), want: 1},
+		{name: "sin tenant", query: newNote(background), wantErr: ErrNoTenant},
+		{name: "without tenant", query: newNote(background).WithoutTenant(), want: 3},
+	}
+	for _, tt := range reads {
+		t.Run("count "+tt.name, func(t *testing.T) {
+			n, err := tt.query.Count()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, se esperaba %v", err, tt.wantErr)
+			}
+			if n != tt.want {
+				t.Errorf("count = %d, se esperaba %d", n, tt.want)
+			}
+		})
+	}
+
+	t.Run("update de otro tenant", func(t *testing.T) {
+		note := newNote(a)
+		if err := note.Find(1); err != nil {
+			t.Fatal(err)
+		}
+		if err := note.Update(map[string]any{"body": "editado", "tenant_id": "b"}); err == nil {
+			t.Error("se cambio el tenant del registro")
+		}
+		if err := newNote(b).Find(1); err == nil {
+			t.Error("el tenant b encontro un registro del tenant a")
+		}
+	})
+}
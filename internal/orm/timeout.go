@@ -0,0 +1,71 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// defaultQueryTimeout es el tiempo limite de las consultas si DB_QUERY_TIMEOUT no esta definida
+const defaultQueryTimeout = 10 * time.Second
+
+// TimeoutError es el error de una consulta que supero su tiempo limite
+// se puede identificar con errors.As o con errors.Is(err, context.DeadlineExceeded)
+type TimeoutError struct {
+	Op  string // operacion que se estaba ejecutando
+	Err error  // error original del driver
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s: la consulta superó el tiempo límite: %v", e.Op, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// queryTimeout es el tiempo limite de las consultas, se toma de DB_QUERY_TIMEOUT con el formato de time.Duration (5s, 1m)
+// con 0 las consultas no tienen tiempo limite
+var queryTimeout = sync.OnceValue(func() time.Duration {
+	value := os.Getenv("DB_QUERY_TIMEOUT")
+	if value == "" {
+		return defaultQueryTimeout
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 {
+		log.Printf("DB_QUERY_TIMEOUT '%s' no es válido, se usa %s", value, defaultQueryTimeout)
+		return defaultQueryTimeout
+	}
+	return timeout
+})
+
+// Timeout asigna el tiempo limite de las consultas del modelo, reemplaza el de DB_QUERY_TIMEOUT
+func (m *Model) Timeout(timeout time.Duration) *Model {
+	m.timeout = timeout
+	return m
+}
+
+// withTimeout agrega el tiempo limite a ctx, si timeout es 0 se usa el de la configuracion
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout == 0 {
+		timeout = queryTimeout()
+	}
+	if timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// dbError agrega la operacion al error de la base de datos, si el error es por tiempo limite retorna un *TimeoutError
+func dbError(op string, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) || mongo.IsTimeout(err) {
+		return &TimeoutError{Op: op, Err: err}
+	}
+	return fmt.Errorf("%s: %w", op, err)
+}
@@ -0,0 +1,80 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	config := queryTimeout
+	queryTimeout = func() time.Duration { return 5 * time.Second }
+	t.Cleanup(func() { queryTimeout = config })
+
+	parent, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	tests := []struct {
+		name  string
+		model func() *Model
+		want  time.Duration // tiempo limite aproximado del contexto de la consulta
+	}{
+		{name: "configuracion", model: newPost, want: 5 * time.Second},
+		{name: "timeout del modelo", model: func() *Model { return newPost().Timeout(time.Minute) }, want: time.Minute},
+		{name: "el contexto con menos tiempo gana", model: func() *Model { return newPost().WithContext(parent).Timeout(time.Minute) }, want: time.Second},
+		{name: "los modelos relacionados heredan el timeout", model: func() *Model { return newPost().Timeout(time.Minute).newRelated("notes") }, want: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.model().context()
+			defer cancel()
+			deadline, ok := ctx.Deadline()
+			if !ok {
+				t.Fatal("el contexto no tiene tiempo limite")
+			}
+			if remaining := time.Until(deadline); remaining > tt.want || remaining < tt.want-time.Second {
+				t.Errorf("tiempo limite = %s, se esperaba %s", remaining, tt.want)
+			}
+		})
+	}
+
+	t.Run("sin tiempo limite", func(t *testing.T) {
+		queryTimeout = func() time.Duration { return 0 }
+		ctx, cancel := newPost().context()
+		defer cancel()
+		if _, ok := ctx.Deadline(); ok {
+			t.Error("con DB_QUERY_TIMEOUT=0 el contexto tiene tiempo limite")
+		}
+	})
+}
+
+func TestDBError(t *testing.T) {
+	errDriver := errors.New("conexion rechazada")
+
+	tests := []struct {
+		name        string
+		err         error
+		wantTimeout bool
+		want        string
+	}{
+		{name: "tiempo limite", err: context.DeadlineExceeded, wantTimeout: true,
+			want: "error al consultar: la consulta superó el tiempo límite: context deadline exceeded"},
+		{name: "otro error", err: errDriver, want: "error al consultar: conexion rechazada"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := dbError("error al consultar", tt.err)
+			var timeout *TimeoutError
+			if errors.As(err, &timeout) != tt.wantTimeout {
+				t.Errorf("errors.As(TimeoutError) = %v, se esperaba %v", !tt.wantTimeout, tt.wantTimeout)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Error("el error no envuelve el error del driver")
+			}
+			if err.Error() != tt.want {
+				t.Errorf("mensaje = %q, se esperaba %q", err.Error(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/donbarrigon/new-project/internal/cache"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Tx representa una transaccion en curso
+// en sql envuelve un *sql.Tx y las transacciones anidadas se manejan con SAVEPOINT
+// en mongodb usa una sesion y el contexto de la sesion se pasa a todas las operaciones
+type Tx struct {
+	ctx     context.Context // contexto de la transaccion, en mongodb contiene la sesion
+	conn    *connection     // conexion en la que se ejecuta la transaccion
+	sqlTx   *sql.Tx         // transaccion de sql, es nil en mongodb
+	session mongo.Session   // sesion de mongodb, es nil en sql
+	depth   int             // nivel de anidamiento, 0 es la transaccion principal
+	written *sync.Map       // tablas escritas, sus consultas en cache se invalidan al confirmar
+}
+
+// txKey es la key con la que se guarda la transaccion en el contexto
+type txKey struct{}
+
+// sqlExecutor es lo que tienen en comun *sql.DB y *sql.Tx
+// permite que los modelos ejecuten las consultas dentro o fuera de una transaccion
+type sqlExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Transaction ejecuta fn dentro de una transaccion
+// si fn retorna un error o entra en panico se hace rollback, si no se hace commit
+// si ctx ya tiene una transaccion se crea una transaccion anidada (SAVEPOINT en sql)
+// se usa la conexion default o la asignada a ctx con UseConnection
+//
+//	err := orm.Transaction(ctx, func(tx *orm.Tx) error {
+//		u := user.NewModel()
+//		u.Tx(tx)
+//		return u.Create(data)
+//	})
+func Transaction(ctx context.Context, fn func(tx *Tx) error) error {
+	if parent, ok := ctx.Value(txKey{}).(*Tx); ok {
+		return parent.Transaction(fn)
+	}
+
+	// Usar un map para los drivers soportados
+	transactionFuncs := map[string]func(context.Context, *connection, func(*Tx) error) error{
+		"mongodb":    transactionMongoDB,
+		"mysql":      transactionMySQL,
+		"postgresql": transactionMySQL,
+		"memory":     transactionMemory,
+	}
+
+	conn := contextConnection(ctx)
+	if transactionFunc, ok := transactionFuncs[conn.driver]; ok {
+		return transactionFunc(ctx, conn, fn)
+	}
+
+	return conn.unsupported()
+}
+
+// Transaction crea una transaccion anidada
+// en sql se usa un SAVEPOINT que se revierte si fn falla sin afectar la transaccion principal, en memory una copia de las tablas
+// mongodb no soporta savepoints, fn se ejecuta dentro de la misma transaccion
+func (tx *Tx) Transaction(fn func(tx *Tx) error) (err error) {
+	nested := &Tx{conn: tx.conn, sqlTx: tx.sqlTx, session: tx.session, depth: tx.depth + 1, written: tx.written}
+	nested.ctx = context.WithValue(tx.ctx, txKey{}, nested)
+
+	if tx.conn.memory != nil {
+		return nested.memorySavepoint(fn)
+	}
+	if tx.sqlTx == nil {
+		return fn(nested)
+	}
+
+	savepoint := fmt.Sprintf("sp_%d", nested.depth)
+	if _, err := tx.sqlTx.ExecContext(tx.ctx, "SAVEPOINT "+savepoint); err != nil {
+		return dbError("error al crear el savepoint", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.sqlTx.ExecContext(tx.ctx, "ROLLBACK TO SAVEPOINT "+savepoint)
+			panic(p)
+		}
+	}()
+
+	if err := fn(nested); err != nil {
+		if _, rbErr := tx.sqlTx.ExecContext(tx.ctx, "ROLLBACK TO SAVEPOINT "+savepoint); rbErr != nil {
+			return fmt.Errorf("error al revertir el savepoint: %v: %w", rbErr, err)
+		}
+		return err
+	}
+
+	if _, err := tx.sqlTx.ExecContext(tx.ctx, "RELEASE SAVEPOINT "+savepoint); err != nil {
+		return dbError("error al liberar el savepoint", err)
+	}
+	return nil
+}
+
+// Context retorna el contexto de la transaccion
+// los modelos con WithContext(tx.Context()), Raw, Exec y Transaction con este contexto hacen parte de la transaccion
+func (tx *Tx) Context() context.Context {
+	return tx.ctx
+}
+
+// transactionMySQL ejecuta la transaccion en mysql o postgresql
+func transactionMySQL(ctx context.Context, conn *connection, fn func(*Tx) error) (err error) {
+	sqlTx, err := conn.sql.BeginTx(ctx, nil)
+	if err != nil {
+		return dbError("error al iniciar la transacción", err)
+	}
+
+	tx := &Tx{conn: conn, sqlTx: sqlTx, written: &sync.Map{}}
+	tx.ctx = context.WithValue(ctx, txKey{}, tx)
+
+	// si fn entra en panico se revierte la transaccion y se propaga el panico
+	defer func() {
+		if p := recover(); p != nil {
+			sqlTx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if rbErr := sqlTx.Rollback(); rbErr != nil {
+			return fmt.Errorf("error al revertir la transacción: %v: %w", rbErr, err)
+		}
+		return err
+	}
+
+	if err := sqlTx.Commit(); err != nil {
+		return dbError("error al confirmar la transacción", err)
+	}
+	flushWritten(tx.written)
+	return nil
+}
+
+// transactionMongoDB ejecuta la transaccion en mongodb usando una sesion con WithTransaction
+// WithTransaction puede reintentar fn si hay errores transitorios, fn no debe tener efectos fuera de la base de datos
+func transactionMongoDB(ctx context.Context, conn *connection, fn func(*Tx) error) (err error) {
+	session, err := conn.mongo.StartSession()
+	if err != nil {
+		return dbError("error al iniciar la sesión de MongoDB", err)
+	}
+	defer session.EndSession(context.Background())
+
+	// si fn entra en panico se aborta la transaccion y se propaga el panico
+	defer func() {
+		if p := recover(); p != nil {
+			session.AbortTransaction(context.Background())
+			panic(p)
+		}
+	}()
+
+	// los reintentos de WithTransaction comparten las tablas escritas
+	written := &sync.Map{}
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (any, error) {
+		tx := &Tx{conn: conn, session: session, written: written}
+		tx.ctx = context.WithValue(sc, txKey{}, tx)
+		return nil, fn(tx)
+	})
+	if err == nil {
+		flushWritten(written)
+	}
+	return err
+}
+
+// flushWritten invalida las consultas en cache de las tablas escritas en una transaccion
+func flushWritten(written *sync.Map) {
+	written.Range(func(table, _ any) bool {
+		cache.Flush(table.(string))
+		return true
+	})
+}
+
+// Tx vincula el modelo a la transaccion, todas las consultas, relaciones y escrituras del modelo se ejecutan dentro de ella
+func (m *Model) Tx(tx *Tx) *Model {
+	m.tx = tx
+	return m
+}
+
+// sqlDB retorna la transaccion si el modelo esta vinculado a una, si no la conexion primaria
+// se usa para escribir, las lecturas siguientes del mismo contexto son sticky
+func (m *Model) sqlDB() sqlExecutor {
+	conn := m.conn()
+	conn.markWrite(m.baseContext())
+	if m.tx != nil && m.tx.sqlTx != nil {
+		return m.tx.sqlTx
+	}
+	return conn.sql
+}
+
+// sqlReader retorna la transaccion si el modelo esta vinculado a una, si no una replica de lectura
+func (m *Model) sqlReader() sqlExecutor {
+	if m.tx != nil && m.tx.sqlTx != nil {
+		return m.tx.sqlTx
+	}
+	return m.conn().reader(m.baseContext())
+}
+
+// WithContext asigna el contexto de las consultas del modelo
+// si el contexto se cancela se cancelan las consultas en curso y se cierran los cursores abiertos
+// si el contexto es el de una transaccion (tx.Context()) de la misma conexion del modelo, el modelo queda vinculado a ella como con Tx
+func (m *Model) WithContext(ctx context.Context) *Model {
+	m.ctx = ctx
+	if tx, ok := ctx.Value(txKey{}).(*Tx); ok && m.tx == nil && m.conn() == tx.conn {
+		m.tx = tx
+	}
+	return m
+}
+
+// context retorna el contexto para las operaciones del modelo con el tiempo limite de Timeout o DB_QUERY_TIMEOUT
+// dentro de una transaccion se usa el de la transaccion, luego el de WithContext y si no context.Background
+func (m *Model) context() (context.Context, context.CancelFunc) {
+	return withTimeout(m.baseContext(), m.timeout)
+}
+
+// streamContext retorna el contexto para recorrer cursores
+// solo tiene tiempo limite si se asigna con Timeout porque el recorrido puede tardar
+func (m *Model) streamContext() (context.Context, context.CancelFunc) {
+	if m.timeout > 0 {
+		return context.WithTimeout(m.baseContext(), m.timeout)
+	}
+	return context.WithCancel(m.baseContext())
+}
+
+// baseContext retorna el contexto de la transaccion, el de WithContext o context.Background
+func (m *Model) baseContext() context.Context {
+	if m.tx != nil {
+		return m.tx.ctx
+	}
+	if m.ctx != nil {
+		return m.ctx
+	}
+	return context.Background()
+}
+
+// newRelated crea un modelo para la tabla relacionada vinculado a la misma transaccion, contexto y conexion
+// si la tabla se registro con otra conexion se usa esa y no la transaccion, que es de la conexion de m
+func (m *Model) newRelated(tableName string) *Model {
+	related := newModel(tableName)
+	related.ctx = m.ctx
+	related.connName = m.connName
+	registry.RLock()
+	if name, ok := registry.connections[tableName]; ok {
+		related.connName = name
+	}
+	registry.RUnlock()
+	if connectionName(related.connName) == connectionName(m.connName) {
+		related.tx = m.tx
+	}
+	related.timeout = m.timeout
+	related.rememberTTL = m.rememberTTL
+	return related
+}
+
+// connectionName retorna el nombre de la conexion, vacio es la default
+func connectionName(name string) string {
+	if name == "" {
+		return DefaultConnection
+	}
+	return name
+}
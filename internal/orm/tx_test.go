@@ -0,0 +1,100 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestTransaction(t *testing.T) {
+	setupMemory(t, postTable())
+	ctx := context.Background()
+	errRollback := errors.New("rollback")
+
+	create := func(tx *Tx, slug string) error {
+		return newPost().Tx(tx).Create(map[string]any{"title": slug, "slug": slug})
+	}
+
+	tests := []struct {
+		name    string
+		fn      func(tx *Tx) error
+		wantErr error
+		want    map[string]int // registros por slug al terminar
+	}{
+		{name: "transaction con el contexto de otra es anidada", fn: func(tx *Tx) error {
+			if err := create(tx, "a"); err != nil {
+				return err
+			}
+			err := Transaction(tx.Context(), func(nested *Tx) error {
+				if nested.depth != 1 {
+					return errors.New("la transaccion no es anidada")
+				}
+				if err := create(nested, "b"); err != nil {
+					return err
+				}
+				return errRollback
+			})
+			if !errors.Is(err, errRollback) {
+				return err
+			}
+			return nil
+		}, want: map[string]int{"a": 1, "b": 0}},
+		{name: "el error de la anidada revierte todo si se retorna", fn: func(tx *Tx) error {
+			if err := create(tx, "c"); err != nil {
+				return err
+			}
+			return tx.Transaction(func(nested *Tx) error {
+				if err := create(nested, "d"); err != nil {
+					return err
+				}
+				return errRollback
+			})
+		}, wantErr: errRollback, want: map[string]int{"c": 0, "d": 0}},
+		{name: "los modelos relacionados usan la transaccion", fn: func(tx *Tx) error {
+			related := newPost().Tx(tx).newRelated("posts")
+			if related.tx != tx {
+				return errors.New("el modelo relacionado no tiene la transaccion")
+			}
+			if err := related.Create(map[string]any{"title": "e", "slug": "e"}); err != nil {
+				return err
+			}
+			return errRollback
+		}, wantErr: errRollback, want: map[string]int{"e": 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Transaction(ctx, tt.fn); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, se esperaba %v", err, tt.wantErr)
+			}
+			for slug, want := range tt.want {
+				if n, _ := MemoryCount("posts", map[string]any{"slug": slug}); n != want {
+					t.Errorf("registros con slug %s = %d, se esperaba %d", slug, n, want)
+				}
+			}
+		})
+	}
+
+	t.Run("panic revierte la transaccion", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("el panic no se propago")
+			}
+			if n, _ := MemoryCount("posts", map[string]any{"slug": "f"}); n != 0 {
+				t.Error("el registro creado antes del panic no se revirtio")
+			}
+		}()
+		Transaction(ctx, func(tx *Tx) error {
+			if err := create(tx, "f"); err != nil {
+				return err
+			}
+			panic("fallo")
+		})
+	})
+
+	t.Run("conexion sin driver", func(t *testing.T) {
+		err := Transaction(UseConnection(ctx, "no_existe"), func(tx *Tx) error { return nil })
+		if err == nil {
+			t.Error("se inicio una transaccion en una conexion que no existe")
+		}
+	})
+}
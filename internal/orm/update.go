@@ -0,0 +1,182 @@
+package orm
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Update actualiza los registros cargados en `m.Data` usando su clave primaria
+// solo se guardan los datos que permitan fillable y guarded, los registros en `m.Data` quedan actualizados
+// por cada registro se disparan los eventos saving, updating, updated y saved
+// si la tabla usa bloqueo optimista la version de data se usa como version esperada aunque no sea fillable
+func (m *Model) Update(data map[string]any) error {
+	if len(m.Data) == 0 {
+		return fmt.Errorf("no hay registros cargados para actualizar")
+	}
+
+	values, err := m.fillData(data)
+	if err != nil {
+		return err
+	}
+	if column := m.lockColumn(); column != "" {
+		if version, ok := data[column]; ok {
+			values[column] = version
+		}
+	}
+	return m.updateRows(values, []string{EventSaving, EventUpdating}, []string{EventUpdated, EventSaved})
+}
+
+// Save guarda en la base de datos los cambios hechos directamente en los registros de `m.Data`
+// solo se actualizan las columnas que cambiaron desde que se cargaron, no pasa por fillable y guarded
+func (m *Model) Save() error {
+	if len(m.Data) == 0 {
+		return fmt.Errorf("no hay registros cargados para guardar")
+	}
+	pk := m.PrimaryKey()
+	for i := range m.Data {
+		dirty := m.GetDirty(i)
+		delete(dirty, pk)
+		if len(dirty) == 0 {
+			continue
+		}
+		if err := m.updateRow(i, dirty, []string{EventSaving, EventUpdating}, []string{EventUpdated, EventSaved}); err != nil {
+			return err
+		}
+	}
+	m.syncOriginal()
+	return nil
+}
+
+// updateRows guarda values en los registros cargados en `m.Data` sin pasar por fillable y guarded
+// before y after son los eventos que se disparan por cada registro antes y despues de guardarlo
+func (m *Model) updateRows(values map[string]any, before []string, after []string) error {
+	for i := range m.Data {
+		if err := m.updateRow(i, maps.Clone(values), before, after); err != nil {
+			return err
+		}
+	}
+	m.syncOriginal()
+	return nil
+}
+
+// updateRow guarda values en el registro i de `m.Data` y dispara los eventos
+func (m *Model) updateRow(i int, values map[string]any, before []string, after []string) error {
+	// Usar un map para los drivers soportados
+	updateFuncs := map[string]func(any, map[string]any, map[string]any) error{
+		"mongodb":    m.updateMongoDB,
+		"mysql":      m.updateMySQL,
+		"postgresql": m.updateMySQL,
+		"memory":     m.updateMemory,
+	}
+
+	updateFunc, ok := updateFuncs[m.driver()]
+	if !ok {
+		return m.conn().unsupported()
+	}
+
+	pk := m.PrimaryKey()
+	row := m.Data[i]
+	id, ok := row[pk]
+	if !ok || id == nil {
+		return fmt.Errorf("el registro no tiene clave primaria '%s'", pk)
+	}
+	if _, err := m.applyTenant(values, false); err != nil {
+		return err
+	}
+
+	e := m.newEvent(m.originalRow(i), values)
+	if err := m.fire(e, before...); err != nil {
+		return err
+	}
+	match, err := m.lockVersion(row, e.Attributes)
+	if err != nil {
+		return err
+	}
+	match = m.tenantMatch(match)
+	values, err = m.castAttributes(e.Attributes)
+	if err != nil {
+		return err
+	}
+	if err := updateFunc(id, values, match); err != nil {
+		return err
+	}
+	m.flushCache()
+	// el registro queda con los valores como si se leyeran de la base de datos
+	if err := m.castValues(values); err != nil {
+		return err
+	}
+	maps.Copy(row, values)
+	m.applyAccessors(row, values)
+	return m.fire(e, after...)
+}
+
+// updateMySQL actualiza el registro en mysql o postgresql
+// match son condiciones extra del registro, si no se actualiza ninguna fila el registro esta desactualizado
+func (m *Model) updateMySQL(id any, values map[string]any, match map[string]any) error {
+	columns := sortedColumns(values)
+	sets := make([]string, len(columns))
+	args := make([]any, 0, len(columns)+len(match)+1)
+	for i, col := range columns {
+		sets[i] = col + " = ?"
+		args = append(args, values[col])
+	}
+	args = append(args, id)
+
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = ?", m.tableName, strings.Join(sets, ", "), m.PrimaryKey())
+	for _, col := range sortedColumns(match) {
+		if match[col] == nil {
+			query += " AND " + col + " IS NULL"
+			continue
+		}
+		query += " AND " + col + " = ?"
+		args = append(args, match[col])
+	}
+
+	ctx, cancel := m.context()
+	defer cancel()
+
+	query = rebind(m.driver(), query)
+	start := time.Now()
+	result, err := m.sqlDB().ExecContext(ctx, query, args...)
+	m.record(ctx, start, query, args, rowsAffected(result), err)
+	if err != nil {
+		return dbError("error al actualizar el registro", err)
+	}
+	if m.lockColumn() != "" {
+		if n, err := result.RowsAffected(); err == nil && n == 0 {
+			return m.staleError(id)
+		}
+	}
+	return nil
+}
+
+// updateMongoDB actualiza el documento en mongodb
+func (m *Model) updateMongoDB(id any, values map[string]any, match map[string]any) error {
+	ctx, cancel := m.context()
+	defer cancel()
+
+	filter := bson.M{"_id": id}
+	for col, value := range match {
+		filter[col] = value
+	}
+
+	collection := m.collection()
+	start := time.Now()
+	result, err := collection.UpdateOne(ctx, filter, bson.M{"$set": values})
+	var modified int64
+	if result != nil {
+		modified = result.ModifiedCount
+	}
+	m.record(ctx, start, m.mongoQuery("updateOne"), []any{filter, values}, modified, err)
+	if err != nil {
+		return dbError("error al actualizar el documento", err)
+	}
+	if m.lockColumn() != "" && result.MatchedCount == 0 {
+		return m.staleError(id)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/donbarrigon/new-project/internal/orm"
+)
+
+type Tenant struct {
+	orm.Model
+}
+
+// NewModel crea el modelo de los tenants, la tabla es central y esta en la conexion default
+func NewModel() *Tenant {
+	model := &Tenant{}
+	model.Table("tenant")
+	model.Central()
+	model.Fillable("id", "name")
+	return model
+}
+
+// Exists indica si el tenant esta registrado, la consulta queda en cache un minuto porque se hace en cada request
+func Exists(ctx context.Context, id string) (bool, error) {
+	n, err := NewModel().WithContext(ctx).Where("id", id).Remember(time.Minute).Count()
+	return n > 0, err
+}
+
+// IDs retorna los ids de todos los tenants registrados
+func IDs(ctx context.Context) ([]string, error) {
+	model := NewModel()
+	if err := model.WithContext(ctx).Select("id").OrderBy("id").Get(); err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(model.Data))
+	for _, row := range model.Data {
+		ids = append(ids, fmt.Sprint(row["id"]))
+	}
+	return ids, nil
+}
@@ -2,20 +2,44 @@ package user
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/donbarrigon/new-project/internal/controller"
+	"github.com/donbarrigon/new-project/internal/orm"
 )
 
 type Response struct {
 	Message string `json:"message"`
 }
 
+// IndexController lista los usuarios paginados
+// con ?cursor= se pagina por cursor, si no por numero de pagina con ?page=
 func IndexController(ctx *controller.Context) {
 
-	response := Response{
-		Message: "IndexController is ready",
+	users := NewModel()
+	users.WithContext(ctx.Context())
+	users.Select(users.PrimaryKey(), "name", "email", "created_at", "updated_at").OrderBy(users.PrimaryKey())
+
+	var response any
+	if _, ok := ctx.Request.URL.Query()["cursor"]; ok {
+		perPage, cursor := ctx.CursorParams()
+		pagination, err := users.CursorPaginate(perPage, cursor)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		response = pagination.SetURL(ctx.Request.URL)
+	} else {
+		page, perPage := ctx.PageParams()
+		pagination, err := users.Paginate(page, perPage)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		response = pagination.SetURL(ctx.Request.URL)
 	}
+
 	if err := json.NewEncoder(ctx.Writer).Encode(response); err != nil {
 		http.Error(ctx.Writer, err.Error(), http.StatusInternalServerError)
 	}
@@ -60,3 +84,38 @@ func DeleteController(ctx *controller.Context) {
 		http.Error(ctx.Writer, err.Error(), http.StatusInternalServerError)
 	}
 }
+
+// IsAdmin indica si el usuario autenticado es administrador, la asigna el paquete de autorizacion
+// sin ella cada usuario solo puede ver su propio historial
+var IsAdmin func(user *orm.Model) bool
+
+// HistoryController lista los cambios auditados del usuario ?id=
+// el historial tiene ips y valores anteriores, solo lo puede ver el mismo usuario o un administrador
+func HistoryController(ctx *controller.Context) {
+
+	id := ctx.Request.URL.Query().Get("id")
+	if id == "" {
+		http.Error(ctx.Writer, "el parametro id es requerido", http.StatusBadRequest)
+		return
+	}
+
+	if ctx.User == nil || len(ctx.User.Data) == 0 {
+		http.Error(ctx.Writer, "se requiere un usuario autenticado", http.StatusUnauthorized)
+		return
+	}
+	self := fmt.Sprint(ctx.User.Data[0][ctx.User.PrimaryKey()]) == id
+	if !self && (IsAdmin == nil || !IsAdmin(ctx.User)) {
+		http.Error(ctx.Writer, "no tiene permiso para ver el historial de este usuario", http.StatusForbidden)
+		return
+	}
+
+	history, err := NewModel().WithContext(ctx.Context()).History(id)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	if err := json.NewEncoder(ctx.Writer).Encode(history); err != nil {
+		http.Error(ctx.Writer, err.Error(), http.StatusInternalServerError)
+	}
+}
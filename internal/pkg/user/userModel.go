@@ -11,5 +11,8 @@ func NewModel() *User {
 	model.Table("user")
 	model.Fillable("name", "email", "phone")
 	model.Guarded("password")
+	model.Hidden("password")
+	model.SoftDeletes()
+	model.Auditable()
 	return model
 }
@@ -35,6 +35,12 @@ func PrivateRoutes() []Route {
 			Handler: UpdateController,
 			Name:    "user-update",
 		},
+		{
+			Path:    "/history",
+			Methods: AllowMethods(GET),
+			Handler: HistoryController,
+			Name:    "user-history",
+		},
 		{
 			Path:    "/delete",
 			Methods: AllowMethods(DELETE),
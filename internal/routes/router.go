@@ -13,8 +13,8 @@ var router = http.NewServeMux()
 func NewRouter() *http.ServeMux {
 
 	// rutas para pkg de usuario
-	HandleFuncs("/users", user.PublicRoutes())
-	HandleFuncs("/users", user.PrivateRoutes(), middleware.Logger, middleware.Request)
+	HandleFuncs("/users", user.PublicRoutes(), middleware.Tenant)
+	HandleFuncs("/users", user.PrivateRoutes(), middleware.Logger, middleware.Request, middleware.Tenant, middleware.Audit, middleware.Sticky, middleware.TrackQueries)
 
 	//rutas api standar
 	// HandleFuncs("/api/v1", ApiPublic)
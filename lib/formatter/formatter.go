@@ -185,6 +185,51 @@ func Pluralize(word string) string {
 	return word + "s"
 }
 
+// Singularize convierte una palabra en plural a singular, es el inverso de Pluralize.
+// se usa para obtener el nombre del modelo a partir del nombre de la tabla
+func Singularize(word string) string {
+	if word == "" {
+		return ""
+	}
+
+	// las palabras irregulares se buscan al reves en el mapa
+	for singular, plural := range IrregularPlurals {
+		if word == plural {
+			return singular
+		}
+	}
+	if _, exists := IrregularPlurals[word]; exists {
+		return word
+	}
+
+	// si la tabla es compuesta (user_roles) solo se singulariza la ultima palabra
+	if i := strings.LastIndex(word, "_"); i >= 0 {
+		return word[:i+1] + Singularize(word[i+1:])
+	}
+
+	if strings.HasSuffix(word, "ies") && len(word) > 3 {
+		return word[:len(word)-3] + "y"
+	}
+
+	if strings.HasSuffix(word, "ves") && len(word) > 3 {
+		return word[:len(word)-3] + "f"
+	}
+
+	if strings.HasSuffix(word, "sses") ||
+		strings.HasSuffix(word, "xes") ||
+		strings.HasSuffix(word, "zes") ||
+		strings.HasSuffix(word, "ches") ||
+		strings.HasSuffix(word, "shes") {
+		return word[:len(word)-2]
+	}
+
+	if strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") {
+		return word[:len(word)-1]
+	}
+
+	return word
+}
+
 // ToFloat64 convierte un valor a float64
 func ToFloat64[T int | int8 | int16 | int32 | int64 | uint | uint8 | uint16 | uint32 | uint64 | float32 | float64 | time.Time | string](value T) (float64, error) {
 	switch v := any(value).(type) {